@@ -0,0 +1,380 @@
+// accessibility.go checks the HTML descriptions of a LibGuides export for
+// accessibility (WCAG) issues.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	// 3rd Party Packages
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Accessibility issues reported by CheckAccessibility
+const (
+	IssueMissingAlt     = "missing alt"
+	IssueEmptyLink      = "empty link"
+	IssueAmbiguousLink  = "ambiguous link text"
+	IssueHeadingOrder   = "heading order"
+	IssueTableHeaders   = "table without headers"
+	IssueColorOnly      = "color only"
+	IssueDeprecatedTag  = "deprecated tag"
+	IssueUnparsableHTML = "unparsable HTML"
+)
+
+// wcagCriteria maps an issue to the WCAG 2.1 success criterion it fails
+var wcagCriteria = map[string]string{
+	IssueMissingAlt:     "1.1.1",
+	IssueEmptyLink:      "2.4.4",
+	IssueAmbiguousLink:  "2.4.4",
+	IssueHeadingOrder:   "1.3.1",
+	IssueTableHeaders:   "1.3.1",
+	IssueColorOnly:      "1.4.1",
+	IssueDeprecatedTag:  "1.3.1",
+	IssueUnparsableHTML: "4.1.1",
+}
+
+// ambiguousLinkText is link text which doesn't describe its target
+var ambiguousLinkText = map[string]bool{
+	"click":      true,
+	"click here": true,
+	"here":       true,
+	"link":       true,
+	"this link":  true,
+	"more":       true,
+	"more info":  true,
+	"read more":  true,
+	"learn more": true,
+	"this":       true,
+	"go":         true,
+}
+
+// deprecatedTags are presentational elements dropped from HTML
+var deprecatedTags = map[string]bool{
+	"basefont": true,
+	"big":      true,
+	"blink":    true,
+	"center":   true,
+	"font":     true,
+	"marquee":  true,
+	"strike":   true,
+	"tt":       true,
+}
+
+var (
+	// reStyleColor matches a color (not background-color) declaration
+	reStyleColor = regexp.MustCompile(`(?i)(^|[;\s])color\s*:`)
+	// reFileName matches alt text that is just an image file name
+	reFileName = regexp.MustCompile(`(?i)^[\w\-. ~]+\.(png|jpe?g|gif|bmp|svg|webp|tiff?)$`)
+)
+
+// AccessibilityIssue is a problem found in a description.
+type AccessibilityIssue struct {
+	// Issue is one of the Issue constants, e.g. "missing alt"
+	Issue string `json:"issue"`
+	// WCAG is the success criterion, e.g. "1.1.1"
+	WCAG string `json:"wcag"`
+	// Element is the start tag of the offending element
+	Element string `json:"element"`
+	// Detail explains the issue
+	Detail string `json:"detail"`
+}
+
+// attr returns the value of an element's attribute.
+func attr(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, name) {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// startTag renders an element's start tag, shortened for a report.
+func startTag(n *html.Node) string {
+	var sb strings.Builder
+	sb.WriteString("<" + n.Data)
+	for _, a := range n.Attr {
+		fmt.Fprintf(&sb, " %s=%q", a.Key, a.Val)
+	}
+	sb.WriteString(">")
+	return shorten(sb.String(), 80)
+}
+
+// nodeText returns the text of a node, including the alt text of images
+// and the labels of elements.
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			sb.WriteString(n.Data)
+		case html.ElementNode:
+			if label, ok := attr(n, "aria-label"); ok {
+				sb.WriteString(" " + label + " ")
+				return
+			}
+			if n.DataAtom == atom.Img {
+				alt, _ := attr(n, "alt")
+				sb.WriteString(" " + alt + " ")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// isElement reports if a node is an element with one of the atoms.
+func isElement(n *html.Node, atoms ...atom.Atom) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	for _, a := range atoms {
+		if n.DataAtom == a {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAncestor reports if a node is inside an element with one of the
+// atoms.
+func hasAncestor(n *html.Node, atoms ...atom.Atom) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if isElement(p, atoms...) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsElement reports if a node contains an element with one of the
+// atoms.
+func containsElement(n *html.Node, atoms ...atom.Atom) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if isElement(c, atoms...) || containsElement(c, atoms...) {
+			return true
+		}
+	}
+	return false
+}
+
+// headingLevel returns the level of a heading element or zero.
+func headingLevel(n *html.Node) int {
+	switch n.DataAtom {
+	case atom.H1:
+		return 1
+	case atom.H2:
+		return 2
+	case atom.H3:
+		return 3
+	case atom.H4:
+		return 4
+	case atom.H5:
+		return 5
+	case atom.H6:
+		return 6
+	}
+	return 0
+}
+
+// CheckAccessibility parses an HTML description and returns the
+// accessibility issues found: images without alt text, links without
+// text or with ambiguous text like "click here", skipped heading levels,
+// tables without header cells, text styled only by color and deprecated
+// presentational tags. LibGuides renders box titles as level two
+// headings so a description's headings are expected to start at level
+// three or above.
+func CheckAccessibility(description string) []*AccessibilityIssue {
+	issues := []*AccessibilityIssue{}
+	if strings.TrimSpace(description) == "" {
+		return issues
+	}
+	add := func(issue string, n *html.Node, format string, args ...interface{}) {
+		issues = append(issues, &AccessibilityIssue{
+			Issue:   issue,
+			WCAG:    wcagCriteria[issue],
+			Element: startTag(n),
+			Detail:  fmt.Sprintf(format, args...),
+		})
+	}
+	nodes, err := parseDescription(description)
+	if err != nil {
+		issues = append(issues, &AccessibilityIssue{
+			Issue:  IssueUnparsableHTML,
+			WCAG:   wcagCriteria[IssueUnparsableHTML],
+			Detail: err.Error(),
+		})
+		return issues
+	}
+	level := 2
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			tag := strings.ToLower(n.Data)
+			if deprecatedTags[tag] {
+				add(IssueDeprecatedTag, n, "<%s> is deprecated, use CSS or semantic markup", tag)
+			}
+			switch n.DataAtom {
+			case atom.Img:
+				if alt, ok := attr(n, "alt"); !ok {
+					add(IssueMissingAlt, n, "image has no alt attribute")
+				} else if reFileName.MatchString(strings.TrimSpace(alt)) {
+					add(IssueMissingAlt, n, "alt text %q is a file name", alt)
+				}
+			case atom.A:
+				if _, ok := attr(n, "href"); ok {
+					text := nodeText(n)
+					if text == "" {
+						add(IssueEmptyLink, n, "link has no text")
+					} else if ambiguousLinkText[strings.Trim(strings.ToLower(text), " .:!>»")] {
+						add(IssueAmbiguousLink, n, "link text %q doesn't describe the target", text)
+					}
+				}
+			case atom.Table:
+				if !containsElement(n, atom.Th) {
+					if role, _ := attr(n, "role"); role != "presentation" {
+						add(IssueTableHeaders, n, "table has no header cells")
+					}
+				}
+			}
+			if l := headingLevel(n); l > 0 {
+				if l > level+1 {
+					add(IssueHeadingOrder, n, "heading level %d follows level %d", l, level)
+				}
+				level = l
+			}
+			color := ""
+			if n.DataAtom == atom.Font {
+				color, _ = attr(n, "color")
+			}
+			if style, ok := attr(n, "style"); ok && reStyleColor.MatchString(style) {
+				color = style
+			}
+			emphasis := []atom.Atom{atom.Strong, atom.B, atom.Em, atom.I, atom.U, atom.Mark, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6}
+			if color != "" && nodeText(n) != "" && !isElement(n, emphasis...) &&
+				!hasAncestor(n, emphasis...) && !containsElement(n, emphasis...) {
+				add(IssueColorOnly, n, "text %q is distinguished only by color", shorten(nodeText(n), 40))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+	return issues
+}
+
+// shorten truncates a string to at most n runes.
+func shorten(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[0:n-3]) + "..."
+}
+
+// AccessibilityTable checks the descriptions of the guides, pages and
+// assets in a LibGuides object. Pages, boxes and assets are checked
+// according to the hidden policy, an asset mapped into several boxes
+// is checked once. The columns are "Issue", "WCAG", "Object Type",
+// "Guide Id", "Page Id", "Box Id", "Asset Id", "Name", "Element" and
+// "Detail".
+func AccessibilityTable(lg *LibGuides, caption string, opts *Options) *Table {
+	hidden := HiddenSkip
+	if opts != nil {
+		hidden = opts.Hidden
+	}
+	tbl := new(Table)
+	tbl.SetCaption(caption)
+	tbl.AppendHeadings("Issue", "WCAG", "Object Type", "Guide Id", "Page Id", "Box Id", "Asset Id", "Name", "Element", "Detail")
+	check := func(description string, objType string, guideId, pageId, boxId, assetId string, name string) {
+		for _, issue := range CheckAccessibility(description) {
+			tbl.AppendRow(issue.Issue, issue.WCAG, objType, guideId, pageId, boxId, assetId, name, issue.Element, issue.Detail)
+		}
+	}
+	checked := map[int]bool{}
+	for _, guide := range lg.Guides {
+		guideId := strInt(guide.Id)
+		check(guide.Description, "Guide", guideId, "", "", "", guide.Name)
+		for _, page := range guide.Pages {
+			pageHidden := page.Hidden != 0
+			pageId := strInt(page.Id)
+			if hidden.Allows(pageHidden) {
+				check(page.Description, "Page", guideId, pageId, "", "", page.Name)
+			}
+			for _, box := range page.Boxes {
+				if !hidden.Allows(pageHidden || box.Hidden != 0) {
+					continue
+				}
+				assets := append([]*Asset{}, box.Assets...)
+				for _, pane := range box.Panes {
+					assets = append(assets, pane.Assets...)
+				}
+				for _, asset := range assets {
+					if checked[asset.Id] {
+						continue
+					}
+					checked[asset.Id] = true
+					check(asset.Description, "Asset", guideId, pageId, strInt(box.Id), strInt(asset.Id), asset.Name)
+				}
+			}
+		}
+	}
+	return tbl
+}
+
+// AccessibilityReport reads a LibGuides export and writes the
+// accessibility issues found in its descriptions to destName.
+func AccessibilityReport(srcName string, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	lg, err := opts.ReadLibGuides(srcName)
+	if err != nil {
+		return err
+	}
+	tbl := AccessibilityTable(lg, fmt.Sprintf("Accessibility of %q", srcName), opts)
+	opts.Logf("found %d accessibility issues", len(tbl.Body.Rows))
+	return opts.WriteTable(tbl, destName)
+}
@@ -0,0 +1,118 @@
+// accessibility_test.go tests the accessibility checker.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"html"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestCheckAccessibility(t *testing.T) {
+	for _, test := range []struct {
+		src      string
+		expected string
+	}{
+		{``, ``},
+		{`<p>Plain <a href="https://example.edu">Example library</a></p><img src="a.png" alt="">`, ``},
+		{`<img src="a.png">`, `missing alt`},
+		{`<img src="a.png" alt="image001.png">`, `missing alt`},
+		{`<a href="https://example.edu"></a>`, `empty link`},
+		{`<a href="https://example.edu"><img src="logo.png" alt="Library home"></a>`, ``},
+		{`<a href="https://example.edu">Click here</a>`, `ambiguous link text`},
+		{`<a href="https://example.edu" aria-label="Library catalog">here</a>`, ``},
+		{`<h3>One</h3><h4>Two</h4><h3>Three</h3>`, ``},
+		{`<h3>One</h3><h5>Two</h5>`, `heading order`},
+		{`<h4>Start</h4>`, `heading order`},
+		{`<table><tr><td>1</td></tr></table>`, `table without headers`},
+		{`<table><tr><th>A</th></tr><tr><td>1</td></tr></table>`, ``},
+		{`<table role="presentation"><tr><td>1</td></tr></table>`, ``},
+		{`<span style="color: red">Required</span>`, `color only`},
+		{`<span style="background-color: red">Highlight</span>`, ``},
+		{`<strong style="color: red">Required</strong>`, ``},
+		{`<font color="red">Note</font>`, `deprecated tag;color only`},
+		{`<center>Welcome</center>`, `deprecated tag`},
+		{html.EscapeString(`<img src="a.png">`), `missing alt`},
+	} {
+		found := []string{}
+		for _, issue := range CheckAccessibility(test.src) {
+			found = append(found, issue.Issue)
+			if issue.WCAG == "" {
+				t.Errorf("expected a WCAG criterion for %q", issue.Issue)
+			}
+		}
+		if strings.Join(found, ";") != test.expected {
+			t.Errorf("expected %q for %s, got %q", test.expected, test.src, strings.Join(found, ";"))
+		}
+	}
+	issues := CheckAccessibility(`<p><img src="a.png"></p>`)
+	expectedInt(t, 1, len(issues))
+	expectedString(t, "1.1.1", issues[0].WCAG)
+	expectedString(t, `<img src="a.png">`, issues[0].Element)
+	// Long elements are shortened without splitting a character
+	issues = CheckAccessibility(`<img src="` + strings.Repeat("é", 100) + `.png">`)
+	expectedInt(t, 1, len(issues))
+	expectedString(t, `<img src="`+strings.Repeat("é", 67)+"...", issues[0].Element)
+}
+
+func TestAccessibilityTable(t *testing.T) {
+	shared := &Asset{Id: 1000, Name: "Logo", Description: `<img src="logo.png">`}
+	lg := &LibGuides{
+		Guides: []*Guide{
+			{Id: 1, Name: "Engineering", Pages: []*Page{
+				{Id: 10, Name: "Home", Description: `<a href="/more">more</a>`, Boxes: []*Box{
+					{Id: 100, Assets: []*Asset{shared}},
+					{Id: 101, Assets: []*Asset{shared}},
+				}},
+				{Id: 11, Name: "Hidden", Hidden: 1, Boxes: []*Box{
+					{Id: 102, Assets: []*Asset{{Id: 1001, Description: `<center>Hidden</center>`}}},
+				}},
+			}},
+		},
+	}
+	tbl := AccessibilityTable(lg, "accessibility", nil)
+	expectedInt(t, 2, len(tbl.Body.Rows))
+	expectedString(t, "ambiguous link text,2.4.4,Page,1,10,,,Home", joinRow(tbl.Body.Rows[0][0:8]))
+	expectedString(t, "missing alt,1.1.1,Asset,1,10,100,1000,Logo", joinRow(tbl.Body.Rows[1][0:8]))
+	tbl = AccessibilityTable(lg, "accessibility", &Options{Hidden: HiddenInclude})
+	expectedInt(t, 3, len(tbl.Body.Rows))
+}
+
+func TestAccessibilityReport(t *testing.T) {
+	srcName := path.Join("testinput", "LibGuides_export_XXXXX.xml")
+	if err := AccessibilityReport(srcName, path.Join("testout", "accessibility.csv"), nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,729 @@
+// api.go provides a client for the LibGuides v1.2 API.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAPIBase is the LibGuides v1.2 API for sites hosted in the
+	// US, other regions use e.g. https://lgapi-ca.libapps.com/1.2
+	DefaultAPIBase = "https://lgapi-us.libapps.com/1.2"
+	// DefaultAPIPerPage is the number of items asked for per request
+	DefaultAPIPerPage = 100
+	// DefaultAPIRetries is how many times a failed request is retried
+	DefaultAPIRetries = 5
+	// DefaultAPIBackoff is the wait before the first retry, it doubles
+	// with each retry up to maxAPIBackoff
+	DefaultAPIBackoff = time.Second
+
+	maxAPIBackoff = time.Minute
+)
+
+// APIError is a response from the API that isn't a success.
+type APIError struct {
+	StatusCode int
+	Method     string
+	Path       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// APIClient reads from (and writes to) the LibGuides v1.2 API. It
+// authenticates with OAuth client credentials, pages through lists and
+// retries requests which are rate limited (429 Too Many Requests,
+// honoring Retry-After), fail with a server error or a network error.
+// An expired token is renewed once per request.
+type APIClient struct {
+	// BaseURL is the API's base, e.g. DefaultAPIBase
+	BaseURL string
+	// ClientId and ClientSecret are the application's credentials
+	// from LibApps > API
+	ClientId     string
+	ClientSecret string
+	// HTTPClient makes the requests, nil means http.DefaultClient
+	HTTPClient *http.Client
+	// PerPage is the page size of lists, zero means DefaultAPIPerPage
+	PerPage int
+	// Retries is the number of retries, zero means DefaultAPIRetries
+	Retries int
+	// Backoff is the wait before the first retry, zero means
+	// DefaultAPIBackoff
+	Backoff time.Duration
+	// Logf logs requests and retries when set
+	Logf func(format string, args ...interface{})
+
+	// sleep waits between retries, replaced in tests
+	sleep   func(time.Duration)
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewAPIClient returns a client for the API at baseURL (DefaultAPIBase
+// when empty).
+func NewAPIClient(baseURL, clientId, clientSecret string) *APIClient {
+	if baseURL == "" {
+		baseURL = DefaultAPIBase
+	}
+	return &APIClient{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+	}
+}
+
+func (c *APIClient) logf(format string, args ...interface{}) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
+
+func (c *APIClient) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// apiToken is the response of the token endpoint.
+type apiToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+// accessToken returns a token, requesting a new one when there is
+// none or it is about to expire.
+func (c *APIClient) accessToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+	form := url.Values{}
+	form.Set("client_id", c.ClientId)
+	form.Set("client_secret", c.ClientSecret)
+	form.Set("grant_type", "client_credentials")
+	status, body, err := c.send(http.MethodPost, "/oauth/token", func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, c.BaseURL+"/oauth/token", strings.NewReader(form.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		return req, err
+	})
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK {
+		return "", &APIError{StatusCode: status, Method: http.MethodPost, Path: "/oauth/token", Message: apiMessage(body)}
+	}
+	token := new(apiToken)
+	if err := json.Unmarshal(body, token); err != nil {
+		return "", fmt.Errorf("/oauth/token: %s", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("/oauth/token: no access token")
+	}
+	c.token = token.AccessToken
+	// Renew a minute early so a token doesn't expire in flight
+	c.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return c.token, nil
+}
+
+// expireToken forgets a token the API has rejected.
+func (c *APIClient) expireToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+// apiMessage returns the error message of a response body.
+func apiMessage(body []byte) string {
+	msg := struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+		Message          string `json:"message"`
+	}{}
+	if json.Unmarshal(body, &msg) == nil {
+		for _, s := range []string{msg.ErrorDescription, msg.Message, msg.Error} {
+			if s != "" {
+				return s
+			}
+		}
+	}
+	return shorten(strings.TrimSpace(string(body)), 200)
+}
+
+// retryAfter returns the wait asked for by a Retry-After header in
+// seconds or as an HTTP date, or false if there is none.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	s := strings.TrimSpace(res.Header.Get("Retry-After"))
+	if s == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(s); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(s); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// send makes a request, retrying rate limited requests, server errors
+// and network errors. Returns the status and body of the response.
+func (c *APIClient) send(method, path string, newRequest func() (*http.Request, error)) (int, []byte, error) {
+	retries := c.Retries
+	if retries <= 0 {
+		retries = DefaultAPIRetries
+	}
+	backoff := c.Backoff
+	if backoff <= 0 {
+		backoff = DefaultAPIBackoff
+	}
+	sleep := c.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return 0, nil, err
+		}
+		c.logf("%s %s", method, req.URL)
+		res, err := c.httpClient().Do(req)
+		var body []byte
+		if err == nil {
+			body, err = ioutil.ReadAll(res.Body)
+			res.Body.Close()
+		}
+		wait := backoff << uint(attempt)
+		if wait > maxAPIBackoff || wait <= 0 {
+			wait = maxAPIBackoff
+		}
+		switch {
+		case err != nil:
+			if attempt >= retries {
+				return 0, nil, err
+			}
+			c.logf("%s, retrying in %s", err, wait)
+		case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+			if attempt >= retries {
+				return res.StatusCode, body, nil
+			}
+			if d, ok := retryAfter(res); ok {
+				wait = d
+			}
+			c.logf("%s %s: %d %s, retrying in %s", method, path, res.StatusCode, apiMessage(body), wait)
+		default:
+			return res.StatusCode, body, nil
+		}
+		sleep(wait)
+	}
+}
+
+// Do sends a request to path (relative to BaseURL) with a JSON body
+// when body isn't nil, decoding the JSON response into v when v isn't
+// nil. Requests are retried as described for APIClient.
+func (c *APIClient) Do(method, path string, query url.Values, body interface{}, v interface{}) error {
+	var src []byte
+	if body != nil {
+		var err error
+		if src, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	for renewed := false; ; renewed = true {
+		token, err := c.accessToken()
+		if err != nil {
+			return err
+		}
+		status, resBody, err := c.send(method, path, func() (*http.Request, error) {
+			var r io.Reader
+			if src != nil {
+				r = bytes.NewReader(src)
+			}
+			req, err := http.NewRequest(method, u, r)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("Accept", "application/json")
+			if src != nil {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			return req, nil
+		})
+		if err != nil {
+			return err
+		}
+		if status == http.StatusUnauthorized && !renewed {
+			// The token may have expired or been revoked
+			c.expireToken(token)
+			continue
+		}
+		if status < 200 || status > 299 {
+			return &APIError{StatusCode: status, Method: method, Path: path, Message: apiMessage(resBody)}
+		}
+		if v == nil || len(bytes.TrimSpace(resBody)) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(resBody, v); err != nil {
+			return fmt.Errorf("%s %s: %s", method, path, err)
+		}
+		return nil
+	}
+}
+
+// list pages through a list endpoint with page and per_page, calling
+// add for each item. A page shorter than per_page is the last, as is a
+// page repeating the ids of the previous one (a server ignoring page).
+func (c *APIClient) list(path string, query url.Values, add func(json.RawMessage) error) error {
+	perPage := c.PerPage
+	if perPage <= 0 {
+		perPage = DefaultAPIPerPage
+	}
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("per_page", strInt(perPage))
+	previous := ""
+	for page := 1; ; page++ {
+		q.Set("page", strInt(page))
+		items := []json.RawMessage{}
+		if err := c.Do(http.MethodGet, path, q, nil, &items); err != nil {
+			return err
+		}
+		ids := make([]string, len(items))
+		for i, item := range items {
+			obj := struct {
+				Id json.RawMessage `json:"id"`
+			}{}
+			if json.Unmarshal(item, &obj) != nil || len(obj.Id) == 0 {
+				obj.Id = item
+			}
+			ids[i] = string(obj.Id)
+		}
+		key := strings.Join(ids, ",")
+		if len(items) > 0 && key == previous {
+			c.logf("%s: page %d repeats page %d, paging is ignored", path, page, page-1)
+			return nil
+		}
+		previous = key
+		for _, item := range items {
+			if err := add(item); err != nil {
+				return fmt.Errorf("%s: %s", path, err)
+			}
+		}
+		if len(items) < perPage {
+			return nil
+		}
+	}
+}
+
+//
+// API responses, converted to the export's structs
+//
+
+// apiOwner is an account as embedded in guides and assets.
+type apiOwner struct {
+	Id        int    `json:"id"`
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Image     string `json:"image"`
+}
+
+func (o *apiOwner) owner() Owner {
+	if o == nil {
+		return Owner{}
+	}
+	return Owner{Id: o.Id, Email: o.Email, FirstName: o.FirstName, LastName: o.LastName, Image: o.Image}
+}
+
+// apiTag is a tag, the API calls its name text.
+type apiTag struct {
+	Id   int    `json:"id"`
+	Text string `json:"text"`
+}
+
+func (t *apiTag) tag() *Tag {
+	return &Tag{Id: t.Id, Name: t.Text}
+}
+
+type apiAsset struct {
+	Id          int       `json:"id"`
+	Name        string    `json:"name"`
+	TypeLabel   string    `json:"type_label"`
+	Description string    `json:"description"`
+	Url         string    `json:"url"`
+	OwnerId     int       `json:"owner_id"`
+	Owner       *apiOwner `json:"owner"`
+	MapId       string    `json:"map_id"`
+	Position    int       `json:"position"`
+	Pane        int       `json:"pane"`
+	Created     string    `json:"created"`
+	Updated     string    `json:"updated"`
+}
+
+func (a *apiAsset) asset() *Asset {
+	asset := &Asset{
+		Id: a.Id, Name: a.Name, Type: a.TypeLabel, Description: a.Description,
+		Url: a.Url, Owner: a.Owner.owner(), MapId: a.MapId, Position: a.Position,
+		Created: a.Created, Updated: a.Updated,
+	}
+	if asset.Owner.Id == 0 {
+		asset.Owner.Id = a.OwnerId
+	}
+	return asset
+}
+
+type apiBox struct {
+	Id        int         `json:"id"`
+	Name      string      `json:"name"`
+	TypeLabel string      `json:"type_label"`
+	MapId     string      `json:"map_id"`
+	Column    int         `json:"column"`
+	Position  int         `json:"position"`
+	Hidden    int         `json:"hidden"`
+	Created   string      `json:"created"`
+	Updated   string      `json:"updated"`
+	Assets    []*apiAsset `json:"assets"`
+}
+
+// box converts a box, assets with a pane number (counting from one)
+// are placed in the box's panes.
+func (b *apiBox) box() *Box {
+	box := &Box{
+		Id: b.Id, Name: b.Name, Type: b.TypeLabel, MapId: b.MapId,
+		Column: b.Column, Position: b.Position, Hidden: b.Hidden,
+		Created: b.Created, Updated: b.Updated, Assets: []*Asset{},
+	}
+	for _, a := range b.Assets {
+		if a.Pane <= 0 {
+			box.Assets = append(box.Assets, a.asset())
+			continue
+		}
+		for len(box.Panes) < a.Pane {
+			box.Panes = append(box.Panes, &Pane{Assets: []*Asset{}})
+		}
+		box.Panes[a.Pane-1].Assets = append(box.Panes[a.Pane-1].Assets, a.asset())
+	}
+	return box
+}
+
+type apiPage struct {
+	Id           int       `json:"id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	Url          string    `json:"url"`
+	FriendlyUrl  string    `json:"friendly_url"`
+	RedirectUrl  string    `json:"redirect_url"`
+	SourcePageId int       `json:"source_page_id"`
+	ParentPageId int       `json:"parent_page_id"`
+	Position     int       `json:"position"`
+	Hidden       int       `json:"hidden"`
+	Created      string    `json:"created"`
+	Updated      string    `json:"updated"`
+	Modified     string    `json:"modified"`
+	Boxes        []*apiBox `json:"boxes"`
+}
+
+func (p *apiPage) page() *Page {
+	page := &Page{
+		Id: p.Id, Name: p.Name, Description: p.Description, Url: p.Url,
+		Redirect: p.RedirectUrl, SourcePageId: p.SourcePageId, ParentPageId: p.ParentPageId,
+		Position: p.Position, Hidden: p.Hidden,
+		Created: p.Created, Updated: p.Updated, Modified: p.Modified, Boxes: []*Box{},
+	}
+	if p.FriendlyUrl != "" {
+		page.Url = p.FriendlyUrl
+	}
+	for _, b := range p.Boxes {
+		page.Boxes = append(page.Boxes, b.box())
+	}
+	return page
+}
+
+type apiGuide struct {
+	Id          int        `json:"id"`
+	TypeLabel   string     `json:"type_label"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Url         string     `json:"url"`
+	FriendlyUrl string     `json:"friendly_url"`
+	OwnerId     int        `json:"owner_id"`
+	Owner       *apiOwner  `json:"owner"`
+	GroupId     int        `json:"group_id"`
+	Group       *Group     `json:"group"`
+	RedirectUrl string     `json:"redirect_url"`
+	StatusLabel string     `json:"status_label"`
+	Created     string     `json:"created"`
+	Updated     string     `json:"updated"`
+	Modified    string     `json:"modified"`
+	Published   string     `json:"published"`
+	Subjects    []*Subject `json:"subjects"`
+	Tags        []*apiTag  `json:"tags"`
+	Pages       []*apiPage `json:"pages"`
+}
+
+func (g *apiGuide) guide() *Guide {
+	guide := &Guide{
+		Id: g.Id, Type: g.TypeLabel, Name: g.Name, Description: g.Description,
+		Url: g.Url, Owner: g.Owner.owner(), Redirect: g.RedirectUrl, Status: g.StatusLabel,
+		Created: g.Created, Updated: g.Updated, Modified: g.Modified, Published: g.Published,
+		Subjects: []*Subject{}, Tags: []*Tag{}, Pages: []*Page{},
+	}
+	if g.FriendlyUrl != "" {
+		guide.Url = g.FriendlyUrl
+	}
+	if guide.Owner.Id == 0 {
+		guide.Owner.Id = g.OwnerId
+	}
+	if g.Group != nil {
+		guide.Group = *g.Group
+	} else {
+		guide.Group.Id = g.GroupId
+	}
+	guide.Subjects = append(guide.Subjects, g.Subjects...)
+	for _, t := range g.Tags {
+		guide.Tags = append(guide.Tags, t.tag())
+	}
+	for _, p := range g.Pages {
+		guide.Pages = append(guide.Pages, p.page())
+	}
+	return guide
+}
+
+// apiSite is the site with its customer, the API names the site's id
+// "id" where the export's JSON has "jd".
+type apiSite struct {
+	Id       int       `json:"id"`
+	Type     string    `json:"type"`
+	Name     string    `json:"name"`
+	Domain   string    `json:"domain"`
+	Admin    string    `json:"admin"`
+	Created  string    `json:"created"`
+	Updated  string    `json:"updated"`
+	Customer *Customer `json:"customer"`
+}
+
+// apiGuideExpand asks for a guide's owner, group, subjects, tags and
+// pages with their boxes and assets.
+const apiGuideExpand = "owner,group,subjects,tags,pages.boxes.assets"
+
+// Site fetches the site and its customer.
+func (c *APIClient) Site() (*Site, *Customer, error) {
+	s := new(apiSite)
+	if err := c.Do(http.MethodGet, "/site", url.Values{"expand": {"customer"}}, nil, s); err != nil {
+		return nil, nil, err
+	}
+	site := &Site{
+		Id: s.Id, Type: s.Type, Name: s.Name, Domain: s.Domain, Admin: s.Admin,
+		Created: s.Created, Updated: s.Updated,
+	}
+	customer := s.Customer
+	if customer == nil {
+		customer = &Customer{}
+	}
+	return site, customer, nil
+}
+
+// Accounts fetches the site's accounts.
+func (c *APIClient) Accounts() ([]*Account, error) {
+	accounts := []*Account{}
+	err := c.list("/accounts", url.Values{"expand": {"profile"}}, func(raw json.RawMessage) error {
+		account := new(Account)
+		accounts = append(accounts, account)
+		return json.Unmarshal(raw, account)
+	})
+	return accounts, err
+}
+
+// Groups fetches the site's groups.
+func (c *APIClient) Groups() ([]*Group, error) {
+	groups := []*Group{}
+	err := c.list("/groups", nil, func(raw json.RawMessage) error {
+		group := new(Group)
+		groups = append(groups, group)
+		return json.Unmarshal(raw, group)
+	})
+	return groups, err
+}
+
+// Vendors fetches the site's vendors.
+func (c *APIClient) Vendors() ([]*Vendor, error) {
+	vendors := []*Vendor{}
+	err := c.list("/vendors", nil, func(raw json.RawMessage) error {
+		vendor := new(Vendor)
+		vendors = append(vendors, vendor)
+		return json.Unmarshal(raw, vendor)
+	})
+	return vendors, err
+}
+
+// Subjects fetches the site's subjects.
+func (c *APIClient) Subjects() ([]*Subject, error) {
+	subjects := []*Subject{}
+	err := c.list("/subjects", nil, func(raw json.RawMessage) error {
+		subject := new(Subject)
+		subjects = append(subjects, subject)
+		return json.Unmarshal(raw, subject)
+	})
+	return subjects, err
+}
+
+// Tags fetches the site's tags.
+func (c *APIClient) Tags() ([]*Tag, error) {
+	tags := []*Tag{}
+	err := c.list("/tags", nil, func(raw json.RawMessage) error {
+		t := new(apiTag)
+		if err := json.Unmarshal(raw, t); err != nil {
+			return err
+		}
+		tags = append(tags, t.tag())
+		return nil
+	})
+	return tags, err
+}
+
+// Guides fetches the guides with their owner, group, subjects, tags,
+// pages, boxes and assets.
+func (c *APIClient) Guides() ([]*Guide, error) {
+	guides := []*Guide{}
+	err := c.list("/guides", url.Values{"expand": {apiGuideExpand}}, func(raw json.RawMessage) error {
+		g := new(apiGuide)
+		if err := json.Unmarshal(raw, g); err != nil {
+			return err
+		}
+		guides = append(guides, g.guide())
+		return nil
+	})
+	return guides, err
+}
+
+// Guide fetches a guide with its owner, group, subjects, tags, pages,
+// boxes and assets.
+func (c *APIClient) Guide(id int) (*Guide, error) {
+	guides := []*apiGuide{}
+	if err := c.Do(http.MethodGet, fmt.Sprintf("/guides/%d", id), url.Values{"expand": {apiGuideExpand}}, nil, &guides); err != nil {
+		return nil, err
+	}
+	if len(guides) == 0 {
+		return nil, &APIError{StatusCode: http.StatusNotFound, Method: http.MethodGet, Path: fmt.Sprintf("/guides/%d", id), Message: "guide not found"}
+	}
+	return guides[0].guide(), nil
+}
+
+// Pages fetches the pages of a guide with their boxes and assets.
+func (c *APIClient) Pages(guideId int) ([]*Page, error) {
+	guide, err := c.Guide(guideId)
+	if err != nil {
+		return nil, err
+	}
+	return guide.Pages, nil
+}
+
+// Boxes fetches the boxes of a page of a guide with their assets.
+func (c *APIClient) Boxes(guideId, pageId int) ([]*Box, error) {
+	pages, err := c.Pages(guideId)
+	if err != nil {
+		return nil, err
+	}
+	for _, page := range pages {
+		if page.Id == pageId {
+			return page.Boxes, nil
+		}
+	}
+	return nil, &APIError{StatusCode: http.StatusNotFound, Method: http.MethodGet, Path: fmt.Sprintf("/guides/%d", guideId), Message: fmt.Sprintf("page %d not found", pageId)}
+}
+
+// Assets fetches the site's assets with their owners. Assets aren't
+// placed in boxes, use Guides for that.
+func (c *APIClient) Assets() ([]*Asset, error) {
+	assets := []*Asset{}
+	err := c.list("/assets", url.Values{"expand": {"owner"}}, func(raw json.RawMessage) error {
+		a := new(apiAsset)
+		if err := json.Unmarshal(raw, a); err != nil {
+			return err
+		}
+		assets = append(assets, a.asset())
+		return nil
+	})
+	return assets, err
+}
+
+// Asset fetches an asset with its owner.
+func (c *APIClient) Asset(id int) (*Asset, error) {
+	path := fmt.Sprintf("/assets/%d", id)
+	assets := []*apiAsset{}
+	if err := c.Do(http.MethodGet, path, url.Values{"expand": {"owner"}}, nil, &assets); err != nil {
+		return nil, err
+	}
+	if len(assets) == 0 {
+		return nil, &APIError{StatusCode: http.StatusNotFound, Method: http.MethodGet, Path: path, Message: "asset not found"}
+	}
+	return assets[0].asset(), nil
+}
+
+// UpdateAsset changes the fields of an asset, e.g. "url" or
+// "description", with a PUT to /assets/{id}.
+func (c *APIClient) UpdateAsset(id int, fields map[string]string) error {
+	return c.Do(http.MethodPut, fmt.Sprintf("/assets/%d", id), nil, fields, nil)
+}
@@ -0,0 +1,209 @@
+// api_test.go provides tests for api.go using the fake API in fakeapi.go
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestAPI returns a fake API serving testinput/api and a client for
+// it which records the waits between retries rather than sleeping.
+func newTestAPI(t *testing.T) (*FakeAPI, *APIClient, *[]time.Duration, func()) {
+	fake, err := NewFakeAPI(filepath.Join("testinput", "api"), "test-client", "test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(fake)
+	client := NewAPIClient(ts.URL+"/1.2/", "test-client", "test-secret")
+	waits := []time.Duration{}
+	client.sleep = func(d time.Duration) {
+		waits = append(waits, d)
+	}
+	return fake, client, &waits, ts.Close
+}
+
+func TestAPIClientGuides(t *testing.T) {
+	fake, client, _, done := newTestAPI(t)
+	defer done()
+	guides, err := client.Guides()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 3, len(guides))
+	if len(guides) != 3 {
+		t.FailNow()
+	}
+	guide := guides[0]
+	expectedInt(t, 512671, guide.Id)
+	expectedString(t, "Subject Guide", guide.Type)
+	expectedString(t, "Published", guide.Status)
+	expectedString(t, "https://libguides.example.edu/patents", guide.Url)
+	expectedString(t, "jdoe@example.edu", guide.Owner.Email)
+	expectedString(t, "Watery Engineering", guide.Group.Name)
+	expectedString(t, "Engineering", guide.Subjects[0].Name)
+	expectedString(t, "patents,standards", guide.Tags[0].Name+","+guide.Tags[1].Name)
+	expectedInt(t, 3, len(guide.Pages))
+	expectedInt(t, 3502868, guide.Pages[1].ParentPageId)
+	expectedInt(t, 1, guide.Pages[2].Hidden)
+	box := guide.Pages[0].Boxes[0]
+	expectedString(t, "Tabbed", box.Type)
+	expectedInt(t, 1, len(box.Assets))
+	expectedInt(t, 2, len(box.Panes))
+	expectedInt(t, 2, len(box.Panes[0].Assets))
+	expectedString(t, "Searching tips", box.Panes[1].Assets[0].Name)
+	expectedString(t, "Media/Widget", guide.Pages[1].Boxes[0].Assets[0].Type)
+	expectedInt(t, 0, guides[1].Group.Id)
+	expectedInt(t, 0, len(guides[2].Pages))
+	expectedInt(t, 1, fake.RequestCount("POST /oauth/token"))
+
+	boxes, err := client.Boxes(512671, 3502869)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, "Recorded lectures", boxes[0].Name)
+	if _, err := client.Guide(999); err == nil {
+		t.Errorf("expected an error for a missing guide")
+	} else if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a not found APIError, got %s", err)
+	}
+	if _, err := client.Boxes(512671, 1); err == nil {
+		t.Errorf("expected an error for a missing page")
+	}
+}
+
+func TestAPIClientLists(t *testing.T) {
+	fake, client, _, done := newTestAPI(t)
+	defer done()
+	client.PerPage = 2
+	accounts, err := client.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 3, len(accounts))
+	expectedString(t, "Engineering Librarian", accounts[0].Title)
+	expectedInt(t, 2, fake.RequestCount("GET /accounts"))
+
+	subjects, err := client.Subjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 3, len(subjects))
+	// A full last page needs one more request to find the end
+	tags, err := client.Tags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 4, len(tags))
+	expectedString(t, "databases", tags[0].Name)
+	expectedInt(t, 3, fake.RequestCount("GET /tags"))
+	assets, err := client.Assets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 6, len(assets))
+	expectedString(t, "ssmith@example.edu", assets[4].Owner.Email)
+
+	// A server ignoring paging answers every page with the full list
+	fake.IgnorePaging = true
+	tags, err = client.Tags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 4, len(tags))
+	expectedInt(t, 5, fake.RequestCount("GET /tags"))
+}
+
+func TestAPIClientRetries(t *testing.T) {
+	fake, client, waits, done := newTestAPI(t)
+	defer done()
+
+	// Rate limits are retried after Retry-After
+	fake.Throttle = 2
+	if _, err := client.Subjects(); err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 2, len(*waits))
+	if len(*waits) == 2 {
+		expectedInt(t, 0, int((*waits)[0]))
+	}
+
+	// Server errors back off exponentially until the retries run out
+	*waits = nil
+	client.Retries = 2
+	fake.Failures = 3
+	_, err := client.Tags()
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected a service unavailable APIError, got %v", err)
+	}
+	expectedInt(t, 2, len(*waits))
+	if len(*waits) == 2 {
+		expectedInt(t, int(DefaultAPIBackoff), int((*waits)[0]))
+		expectedInt(t, int(2*DefaultAPIBackoff), int((*waits)[1]))
+	}
+
+	// A revoked token is renewed
+	fake.RevokeTokens()
+	tokens := fake.RequestCount("POST /oauth/token")
+	if _, err := client.Tags(); err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, tokens+1, fake.RequestCount("POST /oauth/token"))
+
+	client = NewAPIClient(client.BaseURL, "test-client", "wrong")
+	_, err = client.Tags()
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected an unauthorized APIError, got %v", err)
+	} else {
+		expectedString(t, "The client credentials are invalid", apiErr.Message)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	res := &http.Response{Header: http.Header{}}
+	if _, ok := retryAfter(res); ok {
+		t.Errorf("expected no Retry-After")
+	}
+	res.Header.Set("Retry-After", "7")
+	d, ok := retryAfter(res)
+	expectedInt(t, int(7*time.Second), int(d))
+	res.Header.Set("Retry-After", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	d, ok = retryAfter(res)
+	if !ok || d != 0 {
+		t.Errorf("expected a past date to mean no wait, got %s %v", d, ok)
+	}
+}
@@ -0,0 +1,99 @@
+// assemble.go builds a LibGuides object, the same as an export's, from
+// the LibGuides v1.2 API.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+// AssembleLibGuides fetches the site, customer, accounts, groups,
+// subjects, tags, vendors and guides (with their pages, boxes and
+// assets) from the API and assembles them into a LibGuides object like
+// one read from an export. Owners and groups the API only gives by id
+// are filled in from the accounts and groups.
+func AssembleLibGuides(c *APIClient) (*LibGuides, error) {
+	var err error
+	lg := new(LibGuides)
+	if lg.Site, lg.Customer, err = c.Site(); err != nil {
+		return nil, err
+	}
+	if lg.Accounts, err = c.Accounts(); err != nil {
+		return nil, err
+	}
+	if lg.Groups, err = c.Groups(); err != nil {
+		return nil, err
+	}
+	if lg.Subjects, err = c.Subjects(); err != nil {
+		return nil, err
+	}
+	if lg.Tags, err = c.Tags(); err != nil {
+		return nil, err
+	}
+	if lg.Vendors, err = c.Vendors(); err != nil {
+		return nil, err
+	}
+	if lg.Guides, err = c.Guides(); err != nil {
+		return nil, err
+	}
+	c.logf("assembled %d guides from %s", len(lg.Guides), c.BaseURL)
+	accounts := map[int]*Account{}
+	for _, account := range lg.Accounts {
+		accounts[account.Id] = account
+	}
+	groups := map[int]*Group{}
+	for _, group := range lg.Groups {
+		groups[group.Id] = group
+	}
+	fillOwner := func(owner *Owner) {
+		if account, ok := accounts[owner.Id]; ok && owner.Email == "" {
+			owner.Email, owner.FirstName, owner.LastName, owner.Image = account.Email, account.FirstName, account.LastName, account.Image
+		}
+	}
+	for _, guide := range lg.Guides {
+		fillOwner(&guide.Owner)
+		if group, ok := groups[guide.Group.Id]; ok && guide.Group.Name == "" {
+			guide.Group = *group
+		}
+		for _, page := range guide.Pages {
+			for _, box := range page.Boxes {
+				for _, asset := range box.Assets {
+					fillOwner(&asset.Owner)
+				}
+				for _, pane := range box.Panes {
+					for _, asset := range pane.Assets {
+						fillOwner(&asset.Owner)
+					}
+				}
+			}
+		}
+	}
+	return lg, nil
+}
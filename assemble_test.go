@@ -0,0 +1,143 @@
+// assemble_test.go tests building LibGuides from the API in assemble.go.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAssembleLibGuides(t *testing.T) {
+	_, client, _, done := newTestAPI(t)
+	defer done()
+	lg, err := AssembleLibGuides(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, "libguides.example.edu", lg.Site.Domain)
+	expectedString(t, "Tiny Institute of Small Things", lg.Customer.Name)
+	expectedInt(t, 3, len(lg.Accounts))
+	expectedInt(t, 1, len(lg.Groups))
+	expectedInt(t, 3, len(lg.Subjects))
+	expectedInt(t, 4, len(lg.Tags))
+	expectedInt(t, 2, len(lg.Vendors))
+	expectedInt(t, 3, len(lg.Guides))
+	if len(lg.Guides) != 3 {
+		t.FailNow()
+	}
+	// Filled in from the accounts
+	asset := lg.Guides[1].Pages[0].Boxes[0].Assets[1]
+	expectedInt(t, 21001, asset.Id)
+	expectedString(t, "jdoe@example.edu", asset.Owner.Email)
+
+	// The same as the export of the same content
+	src, err := lg.ToXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fName := filepath.Join("testinput", "api", "LibGuides_export_api.xml")
+	expectedSrc, err := ioutil.ReadFile(fName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedBytes(t, expectedSrc, src)
+	exported, err := ReadLibGuides(fName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := LinkReportTable(lg, "links", nil)
+	expectedLinks := LinkReportTable(exported, "links", nil)
+	expectedInt(t, len(expectedLinks.Body.Rows), len(links.Body.Rows))
+	for i := 0; i < len(links.Body.Rows) && i < len(expectedLinks.Body.Rows); i++ {
+		expectedString(t, joinRow(expectedLinks.Body.Rows[i]), joinRow(links.Body.Rows[i]))
+	}
+}
+
+func TestSourceAPI(t *testing.T) {
+	fake, err := NewFakeAPI(filepath.Join("testinput", "api"), "test-client", "test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	opts := &Options{Source: SourceAPI, APIBase: ts.URL + "/1.2"}
+	if _, err := opts.ReadLibGuides(""); err == nil {
+		t.Errorf("expected an error without client credentials")
+	}
+	opts.ClientId, opts.ClientSecret = "test-client", "test-secret"
+	if err := opts.SetInputOutput([]string{"testout/api-links.json"}); err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, ts.URL+"/1.2", opts.Input)
+	if err := opts.SetInputOutput([]string{"export.xml", "links.json"}); err == nil {
+		t.Errorf("expected a source file to be refused with -source api")
+	}
+	if err := (&Options{Source: "ftp"}).Validate(); err == nil {
+		t.Errorf("expected an unknown source to be invalid")
+	}
+
+	os.Setenv("SPRINGYTOOLS_API_BASE", ts.URL+"/1.2")
+	os.Setenv("SPRINGYTOOLS_CLIENT_ID", "test-client")
+	os.Setenv("SPRINGYTOOLS_CLIENT_SECRET", "test-secret")
+	defer func() {
+		os.Unsetenv("SPRINGYTOOLS_API_BASE")
+		os.Unsetenv("SPRINGYTOOLS_CLIENT_ID")
+		os.Unsetenv("SPRINGYTOOLS_CLIENT_SECRET")
+	}()
+	destName := "testout/api-links.json"
+	if code := RunCommand("springytools", []string{"-source", "api", "-format", "json", "links", destName}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	src, err := ioutil.ReadFile(destName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl := new(Table)
+	if err := json.Unmarshal(src, tbl); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := ReadLibGuides(filepath.Join("testinput", "api", "LibGuides_export_api.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, len(LinkReportTable(exported, "links", nil).Body.Rows), len(tbl.Body.Rows))
+	if code := RunCommand("springytools", []string{"-source", "api", "sanitize", "testout/api-sanitized.xml"}); code == 0 {
+		t.Errorf("expected sanitize to fail with -source api")
+	}
+}
@@ -0,0 +1,431 @@
+// cleaner.go removes the Microsoft Office artifacts pasted into the rich text
+// descriptions of a LibGuides export.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	// 3rd Party Packages
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	// reNbspRun matches runs of white space holding a non-breaking space
+	reNbspRun = regexp.MustCompile("[ \t\u00a0]*\u00a0[ \t\u00a0]*")
+	// reOfficeComment matches the comments Word leaves behind, e.g.
+	// conditional comments and fragment markers
+	reOfficeComment = regexp.MustCompile(`(?i)^\s*(\[if |\[endif|<!\[endif|StartFragment|EndFragment)|mso-`)
+	// reOfficeStyle matches Office specific style declarations
+	reOfficeStyle = regexp.MustCompile(`(?i)^\s*mso-`)
+)
+
+// officeLinkRels are the rel values of the links Word adds to pasted HTML
+var officeLinkRels = map[string]bool{
+	"file-list":          true,
+	"edit-time-data":     true,
+	"themedata":          true,
+	"colorschememapping": true,
+	"ole-object-data":    true,
+}
+
+// emptyRemovable are elements removed when they have no content
+var emptyRemovable = map[atom.Atom]bool{
+	atom.P:      true,
+	atom.Div:    true,
+	atom.Span:   true,
+	atom.Font:   true,
+	atom.B:      true,
+	atom.Strong: true,
+	atom.I:      true,
+	atom.Em:     true,
+	atom.U:      true,
+	atom.H1:     true,
+	atom.H2:     true,
+	atom.H3:     true,
+	atom.H4:     true,
+	atom.H5:     true,
+	atom.H6:     true,
+}
+
+// inlineElements are the emptyRemovable elements found within text
+var inlineElements = map[atom.Atom]bool{
+	atom.Span:   true,
+	atom.Font:   true,
+	atom.B:      true,
+	atom.Strong: true,
+	atom.I:      true,
+	atom.Em:     true,
+	atom.U:      true,
+}
+
+// isLocalFile reports if a URL points at a local file.
+func isLocalFile(u string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(u)), "file:")
+}
+
+// htmlCleaner counts the changes made while cleaning a description.
+type htmlCleaner struct {
+	changes int
+}
+
+// remove detaches a node from its parent.
+func (c *htmlCleaner) remove(n *html.Node) {
+	n.Parent.RemoveChild(n)
+	c.changes++
+}
+
+// unwrap replaces an element with its children.
+func (c *htmlCleaner) unwrap(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = n.FirstChild {
+		n.RemoveChild(child)
+		n.Parent.InsertBefore(child, n)
+	}
+	c.remove(n)
+}
+
+// isOfficeElement reports if an element is an Office artifact that
+// should be removed with its content.
+func isOfficeElement(n *html.Node) bool {
+	switch strings.ToLower(n.Data) {
+	case "meta", "xml", "title":
+		return true
+	case "link":
+		href, _ := attr(n, "href")
+		rel, _ := attr(n, "rel")
+		return isLocalFile(href) || officeLinkRels[strings.ToLower(rel)]
+	case "style":
+		text := nodeText(n)
+		return text == "" || strings.Contains(strings.ToLower(text), "mso")
+	}
+	return false
+}
+
+// cleanAttributes drops the Mso classes and mso- style declarations.
+func (c *htmlCleaner) cleanAttributes(n *html.Node) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		switch strings.ToLower(a.Key) {
+		case "class":
+			classes := []string{}
+			for _, class := range strings.Fields(a.Val) {
+				if !strings.HasPrefix(strings.ToLower(class), "mso") {
+					classes = append(classes, class)
+				}
+			}
+			if len(classes) != len(strings.Fields(a.Val)) {
+				c.changes++
+				a.Val = strings.Join(classes, " ")
+			}
+		case "style":
+			declarations := []string{}
+			for _, declaration := range strings.Split(a.Val, ";") {
+				if strings.TrimSpace(declaration) == "" {
+					continue
+				}
+				if reOfficeStyle.MatchString(declaration) {
+					c.changes++
+					continue
+				}
+				declarations = append(declarations, strings.TrimSpace(declaration))
+			}
+			a.Val = strings.Join(declarations, "; ")
+			if a.Val != "" {
+				a.Val += ";"
+			}
+		}
+		if a.Val == "" && (strings.EqualFold(a.Key, "class") || strings.EqualFold(a.Key, "style")) {
+			continue
+		}
+		attrs = append(attrs, a)
+	}
+	n.Attr = attrs
+}
+
+// isEmpty reports if an element has no text (other than white space)
+// and no content elements like images, tables or embedded media.
+func isEmpty(n *html.Node) bool {
+	if _, ok := attr(n, "id"); ok {
+		return false
+	}
+	if _, ok := attr(n, "name"); ok {
+		return false
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case html.TextNode:
+			if strings.TrimSpace(strings.ReplaceAll(child.Data, "\u00a0", " ")) != "" {
+				return false
+			}
+		case html.ElementNode:
+			if child.DataAtom != atom.Br && !(emptyRemovable[child.DataAtom] && isEmpty(child)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// clean walks the children of a node removing the Office artifacts,
+// local file links, empty elements and runs of non-breaking spaces.
+func (c *htmlCleaner) clean(n *html.Node) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		switch child.Type {
+		case html.CommentNode:
+			if reOfficeComment.MatchString(child.Data) {
+				c.remove(child)
+			}
+		case html.TextNode:
+			if s := reNbspRun.ReplaceAllStringFunc(child.Data, func(run string) string {
+				if run == "\u00a0" {
+					return run
+				}
+				return " "
+			}); s != child.Data {
+				child.Data = s
+				c.changes++
+			}
+		case html.ElementNode:
+			href, _ := attr(child, "href")
+			src, _ := attr(child, "src")
+			switch {
+			case isOfficeElement(child):
+				c.remove(child)
+			case strings.Contains(child.Data, ":"):
+				// Office namespaced elements, e.g. <o:p>
+				c.clean(child)
+				c.unwrap(child)
+			case child.DataAtom == atom.A && isLocalFile(href):
+				c.clean(child)
+				c.unwrap(child)
+			case child.DataAtom == atom.Img && isLocalFile(src):
+				c.remove(child)
+			default:
+				c.cleanAttributes(child)
+				c.clean(child)
+				if emptyRemovable[child.DataAtom] && isEmpty(child) {
+					// Keep the space an inline element separated words with
+					if inlineElements[child.DataAtom] && child.FirstChild != nil {
+						n.InsertBefore(&html.Node{Type: html.TextNode, Data: " "}, child)
+					}
+					c.remove(child)
+				}
+			}
+		}
+		child = next
+	}
+	// Merge the white space left between the removed elements
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		for child.Type == html.TextNode && child.NextSibling != nil && child.NextSibling.Type == html.TextNode {
+			child.Data += child.NextSibling.Data
+			n.RemoveChild(child.NextSibling)
+		}
+		if child.Type == html.TextNode && strings.Count(child.Data, "\n") > 1 && strings.TrimSpace(child.Data) == "" {
+			child.Data = "\n"
+		}
+	}
+}
+
+// CleanHTML removes the artifacts left by pasting from Microsoft Word
+// into a description: meta, link and style elements, conditional
+// comments, Office namespaced elements like <o:p>, Mso classes and
+// mso- styles, links and images pointing at local files, empty
+// paragraphs and runs of non-breaking spaces. The remaining structure
+// is kept. A description needing no changes is returned as is.
+func CleanHTML(description string) (string, error) {
+	if strings.TrimSpace(description) == "" {
+		return description, nil
+	}
+	nodes, err := parseDescription(description)
+	if err != nil {
+		return description, err
+	}
+	c := new(htmlCleaner)
+	root := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+	c.clean(root)
+	if c.changes == 0 && decodeDescription(description) == description {
+		return description, nil
+	}
+	buf := new(bytes.Buffer)
+	for n := root.FirstChild; n != nil; n = n.NextSibling {
+		if err := html.Render(buf, n); err != nil {
+			return description, err
+		}
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// CleanedDescription records a description changed by CleanLibGuides.
+type CleanedDescription struct {
+	ObjectType  string `json:"object_type"`
+	Id          int    `json:"id"`
+	GuideId     int    `json:"guide_id"`
+	PageId      int    `json:"page_id,omitempty"`
+	BoxId       int    `json:"box_id,omitempty"`
+	Before      int    `json:"before"`
+	After       int    `json:"after"`
+	Description string `json:"description"`
+}
+
+// CleanLibGuides cleans the descriptions of the guides, pages and
+// assets in a LibGuides object (see CleanHTML), updating it in place.
+// It returns the descriptions changed, an asset mapped into several
+// boxes is listed once.
+func CleanLibGuides(lg *LibGuides) ([]*CleanedDescription, error) {
+	cleaned := []*CleanedDescription{}
+	seen := map[int]bool{}
+	clean := func(description *string, record *CleanedDescription) error {
+		s, err := CleanHTML(*description)
+		if err != nil {
+			return fmt.Errorf("%s %d, %s", record.ObjectType, record.Id, err)
+		}
+		if s != *description {
+			record.Before, record.After, record.Description = len(*description), len(s), s
+			*description = s
+			cleaned = append(cleaned, record)
+		}
+		return nil
+	}
+	for _, guide := range lg.Guides {
+		if err := clean(&guide.Description, &CleanedDescription{ObjectType: "Guide", Id: guide.Id, GuideId: guide.Id}); err != nil {
+			return cleaned, err
+		}
+		for _, page := range guide.Pages {
+			if err := clean(&page.Description, &CleanedDescription{ObjectType: "Page", Id: page.Id, GuideId: guide.Id, PageId: page.Id}); err != nil {
+				return cleaned, err
+			}
+			for _, box := range page.Boxes {
+				assets := append([]*Asset{}, box.Assets...)
+				for _, pane := range box.Panes {
+					assets = append(assets, pane.Assets...)
+				}
+				for _, asset := range assets {
+					record := &CleanedDescription{ObjectType: "Asset", Id: asset.Id, GuideId: guide.Id, PageId: page.Id, BoxId: box.Id}
+					if seen[asset.Id] {
+						// Apply the same patch without listing it again
+						if s, err := CleanHTML(asset.Description); err == nil {
+							asset.Description = s
+						}
+						continue
+					}
+					seen[asset.Id] = true
+					if err := clean(&asset.Description, record); err != nil {
+						return cleaned, err
+					}
+				}
+			}
+		}
+	}
+	return cleaned, nil
+}
+
+// CleanedTable returns the changed descriptions as a patch list with
+// the columns "Object Type", "Id", "Guide Id", "Page Id", "Box Id",
+// "Before Bytes", "After Bytes" and "Description". Without the
+// descriptions it is a size report ending with a "Total" row.
+func CleanedTable(cleaned []*CleanedDescription, caption string, withDescription bool) *Table {
+	optionalId := func(id int) string {
+		if id == 0 {
+			return ""
+		}
+		return strInt(id)
+	}
+	tbl := new(Table)
+	tbl.SetCaption(caption)
+	headings := []string{"Object Type", "Id", "Guide Id", "Page Id", "Box Id", "Before Bytes", "After Bytes"}
+	if withDescription {
+		headings = append(headings, "Description")
+	}
+	tbl.AppendHeadings(headings...)
+	before, after := 0, 0
+	for _, c := range cleaned {
+		row := []string{c.ObjectType, strInt(c.Id), strInt(c.GuideId), optionalId(c.PageId), optionalId(c.BoxId), strInt(c.Before), strInt(c.After)}
+		if withDescription {
+			row = append(row, c.Description)
+		}
+		tbl.AppendRow(row...)
+		before += c.Before
+		after += c.After
+	}
+	if !withDescription {
+		tbl.AppendRow("Total", strInt(len(cleaned)), "", "", "", strInt(before), strInt(after))
+	}
+	return tbl
+}
+
+// CleanReport reads a LibGuides export, cleans the descriptions and
+// writes the cleaned XML export to destName, or the patch list when
+// opts.Patch is true. When opts.SizeReport is set a before and after
+// size report is written to it.
+func CleanReport(srcName string, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	lg, err := opts.ReadLibGuides(srcName)
+	if err != nil {
+		return err
+	}
+	cleaned, err := CleanLibGuides(lg)
+	if err != nil {
+		return err
+	}
+	before, after := 0, 0
+	for _, c := range cleaned {
+		before += c.Before
+		after += c.After
+	}
+	opts.Logf("cleaned %d descriptions, %d bytes to %d bytes", len(cleaned), before, after)
+	if opts.SizeReport != "" {
+		tbl := CleanedTable(cleaned, fmt.Sprintf("Description sizes cleaning %q", srcName), false)
+		if err := (&Options{Verbose: opts.Verbose, WriteOptions: opts.WriteOptions}).WriteTable(tbl, opts.SizeReport); err != nil {
+			return err
+		}
+	}
+	if opts.Patch {
+		return opts.WriteTable(CleanedTable(cleaned, fmt.Sprintf("Cleaned descriptions of %q", srcName), true), destName)
+	}
+	src, err := lg.ToXML()
+	if err != nil {
+		return err
+	}
+	return WriteDestinationWithOptions(destName, src, &opts.WriteOptions)
+}
@@ -0,0 +1,137 @@
+// cleaner_test.go tests removing Word artifacts from descriptions.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestCleanHTML(t *testing.T) {
+	for _, test := range []struct {
+		src      string
+		expected string
+	}{
+		{``, ``},
+		{`<p>Nothing to <b>clean</b></p>`, `<p>Nothing to <b>clean</b></p>`},
+		{`<p><meta name="Generator" content="Microsoft Word 12" /></p><p>Text</p>`, `<p>Text</p>`},
+		{`<link href="file:///C:DOCUME~1Tempclip_filelist.xml" rel="File-List" /><p>Text</p>`, `<p>Text</p>`},
+		{`<style type="text/css"></style><!--[if gte mso 9]><xml><w:WordDocument></w:WordDocument></xml><![endif]--><p>Text</p>`, `<p>Text</p>`},
+		{`<p class="MsoNormal">Text<o:p></o:p></p>`, `<p>Text</p>`},
+		{`<p class="MsoNormal intro" style="mso-bidi-font-weight: bold; text-align: center">Text</p>`, `<p class="intro" style="text-align: center;">Text</p>`},
+		{`<p class="MsoNormal"><font size="3"><o:p>&nbsp;</o:p></font></p><p>Text</p>`, `<p>Text</p>`},
+		{`<p>A&nbsp;&nbsp;&nbsp; B&nbsp;C</p>`, "<p>A B\u00a0C</p>"},
+		{`<p>I.<span style="font: 7pt">&nbsp;&nbsp;&nbsp;</span>Intro</p>`, `<p>I. Intro</p>`},
+		{`<p><a href="file:///C:/Users/me/notes.docx">Notes</a> <img src="file:///C:/tmp/a.png" alt="A"></p>`, `<p>Notes </p>`},
+		{`<p><img src="https://example.edu/a.png" alt="A"></p><p><a name="top"></a></p>`, `<p><img src="https://example.edu/a.png" alt="A"></p><p><a name="top"></a></p>`},
+		{`<p><br></p><h3>&nbsp;</h3><p>Text</p>`, `<p>Text</p>`},
+		{`<p>One</p>
+
+
+
+<p>&nbsp;</p>
+
+<p>Two</p>`, "<p>One</p>\n<p>Two</p>"},
+		{`&lt;p class=&quot;MsoNormal&quot;&gt;Text&lt;/p&gt;`, `<p>Text</p>`},
+	} {
+		got, err := CleanHTML(test.src)
+		if err != nil {
+			t.Errorf("CleanHTML(%q): %s", test.src, err)
+		}
+		expectedString(t, test.expected, got)
+	}
+}
+
+func TestCleanLibGuides(t *testing.T) {
+	word := `<p class="MsoNormal">Text<o:p></o:p></p>`
+	lg := &LibGuides{
+		Guides: []*Guide{
+			{Id: 1, Description: "A plain description", Pages: []*Page{
+				{Id: 10, Description: word, Boxes: []*Box{
+					{Id: 100, Assets: []*Asset{{Id: 1000, Description: word}}},
+					{Id: 101, Panes: []*Pane{{Assets: []*Asset{{Id: 1000, Description: word}, {Id: 1001, Description: "<p>Clean</p>"}}}}},
+				}},
+			}},
+		},
+	}
+	cleaned, err := CleanLibGuides(lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 2, len(cleaned))
+	expectedString(t, "Page,10", cleaned[0].ObjectType+","+strInt(cleaned[0].Id))
+	expectedString(t, "Asset,1000", cleaned[1].ObjectType+","+strInt(cleaned[1].Id))
+	expectedInt(t, len(word), cleaned[1].Before)
+	expectedInt(t, len("<p>Text</p>"), cleaned[1].After)
+	expectedString(t, "<p>Text</p>", lg.Guides[0].Pages[0].Boxes[1].Panes[0].Assets[0].Description)
+	expectedString(t, "A plain description", lg.Guides[0].Description)
+
+	tbl := CleanedTable(cleaned, "sizes", false)
+	expectedInt(t, 3, len(tbl.Body.Rows))
+	expectedString(t, "Total,2,,,,"+strInt(len(word)*2)+",22", joinRow(tbl.Body.Rows[2]))
+	tbl = CleanedTable(cleaned, "patches", true)
+	expectedString(t, "Asset,1000,1,10,100,"+strInt(len(word))+",11,<p>Text</p>", joinRow(tbl.Body.Rows[1]))
+}
+
+func TestCleanReport(t *testing.T) {
+	srcName := path.Join("testinput", "LibGuides_export_XXXXX.xml")
+	destName := path.Join("testout", "cleaned.xml")
+	sizeName := path.Join("testout", "cleaned-sizes.csv")
+	if err := CleanReport(srcName, destName, &Options{SizeReport: sizeName}); err != nil {
+		t.Fatal(err)
+	}
+	lg, err := ReadLibGuides(destName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, guide := range lg.Guides {
+		for _, asset := range guideAssets(guide) {
+			if strings.Contains(asset.Description, "MsoNormal") || strings.Contains(asset.Description, "<o:p>") {
+				t.Errorf("expected asset %d to be cleaned", asset.Id)
+			}
+		}
+	}
+	src, err := ioutil.ReadFile(sizeName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "\nTotal,") {
+		t.Errorf("expected a Total row in %q", sizeName)
+	}
+	if err := CleanReport(srcName, path.Join("testout", "cleaned-patch.json"), &Options{Patch: true}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,1170 @@
+// cli.go implements the springytools command line interface. Subcommands are
+// described by a Command and share the global options. Usage text is generated
+// from the Command definitions.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Command describes a springytools subcommand. The usage text of the
+// springytools command and its aliases is generated from these fields.
+type Command struct {
+	// Name is used to pick the subcommand, e.g. "links"
+	Name string
+	// Args describes the positional parameters
+	Args string
+	// Synopsis is a one line description of the command
+	Synopsis string
+	// Description explains what the command does
+	Description string
+	// Examples are shown at the end of the usage, "{app}" is
+	// replaced by the program name
+	Examples string
+	// TableReport adds the -where, -group-by, -sort and -columns options
+	TableReport bool
+	// SetFlags adds options specific to the command
+	SetFlags func(fs *flag.FlagSet, opts *Options)
+	// Run executes the command with the parsed options and
+	// remaining command line parameters.
+	Run func(opts *Options, args []string) error
+}
+
+// Commands lists the springytools subcommands.
+var Commands = []*Command{
+	{
+		Name:     "convert",
+		Args:     "SOURCE_FILE [DESTINATION_FILE]",
+		Synopsis: "convert a LibGuides XML export to JSON",
+		Description: `Converts a LibGuides' XML export to JSON.
+
+With -jsonl DESTINATION_FILE is a directory where a JSON Lines file
+is written for each entity: customer, site, accounts, groups,
+subjects, tags, vendors, guides, guide_subjects, guide_tags, pages,
+boxes and assets (e.g. guides.jsonl). Records are flattened, nested
+lists are removed and child records carry their parent's ids
+(guide_id, page_id, box_id and pane) so they can be loaded by tools
+like DuckDB or pandas.
+
+With -description-text a "description_text" field holding the plain
+text of the description (paragraphs separated by blank lines, links
+as numbered footnotes) is added next to each description of the
+groups, guides, pages and assets.
+`,
+		Examples: `    {app} LibGuides_export_221133.xml LibGuides_export_221133.json
+
+    {app} -description-text LibGuides_export_221133.xml - | jq '.guides[].pages[].description_text'
+
+    unzip -p export.zip | {app} - - | jq .guides
+
+    {app} -jsonl LibGuides_export_221133.xml export_221133
+    duckdb -c "SELECT owner_email, count(*) FROM 'export_221133/assets.jsonl' GROUP BY 1"
+`,
+		SetFlags: func(fs *flag.FlagSet, opts *Options) {
+			fs.BoolVar(&opts.JSONLines, "jsonl", opts.JSONLines, "write a directory of JSON Lines files, one per entity")
+			fs.BoolVar(&opts.DescriptionText, "description-text", opts.DescriptionText, "add the plain text of each description as description_text")
+		},
+		Run: runConvert,
+	},
+	{
+		Name:     "links",
+		Args:     "SOURCE_FILE [DESTINATION_FILE]",
+		Synopsis: "report on the links found in a LibGuides XML export",
+		Description: `Reads a LibGuides' XML export and generates a report on links
+found and where they were found. Links in descriptions include
+file: URLs and UNC paths (\\server\share) as well as http(s) URLs.
+
+The columns of the report are "URL", "Owner", "Object Type",
+"Id", "Guide Id", "Page Id", "LibGuides Link", "Embedded URL" and
+"Category". The Category is "public" or, for links patrons can't
+follow, "local file", "UNC path", "localhost", "private IP" or
+"intranet". Intranet hosts are single label host names, hosts in
+domains like .local or .corp and hosts matching the "intranet"
+patterns of the configuration. Use -summary for a count of the links
+in each category.
+`,
+		Examples: `    {app} LibGuides_export_221133.xml links.json
+
+Links on the live site, read from the API with the client_id and
+client_secret of the configuration
+
+    {app} -source api links.csv
+
+Only embedded links, sorted by owner
+
+    {app} -where '"Embedded URL" == "true"' -sort Owner,URL \
+        LibGuides_export_221133.xml embedded.csv
+
+Count of links per owner
+
+    {app} -group-by Owner -sort -Count \
+        LibGuides_export_221133.xml owners.csv
+
+Links patrons can't follow and the count per category
+
+    {app} -where 'Category != "public"' LibGuides_export_221133.xml
+    {app} -summary LibGuides_export_221133.xml
+
+Reading a zipped export and writing to standard output
+
+    unzip -p export.zip | {app} - - | cut -d , -f 1
+`,
+		TableReport: true,
+		SetFlags: func(fs *flag.FlagSet, opts *Options) {
+			fs.BoolVar(&opts.Summary, "summary", opts.Summary, "count the links per category")
+		},
+		Run: runLinks,
+	},
+	{
+		Name:     "sanitize",
+		Args:     "SOURCE_FILE [DESTINATION_FILE]",
+		Synopsis: "remove characters not allowed in XML from an export",
+		Description: `Removes control codes (e.g. ^A, ^K, ^L, ^S, ^C, ^R) which are
+not allowed in XML and replaces invalid UTF-8 so the export can be
+parsed. Use -verbose to see how many characters were changed.
+`,
+		Examples: `    {app} LibGuides_export_221133.xml LibGuides_export_clean.xml
+`,
+		Run: runSanitize,
+	},
+	{
+		Name:     "clean",
+		Args:     "SOURCE_FILE [DESTINATION_FILE]",
+		Synopsis: "remove Word artifacts from the rich text descriptions",
+		Description: `Cleans the HTML descriptions of the guides, pages and assets in a
+LibGuides' XML export. Meta, link and style elements, conditional
+comments, Office elements like <o:p>, Mso classes, mso- styles, links
+and images pointing at local files (file://), empty paragraphs and
+runs of &nbsp; are removed, the remaining structure is kept.
+
+The cleaned XML export is written to DESTINATION_FILE. With -patch
+a patch list is written instead with the columns "Object Type", "Id",
+"Guide Id", "Page Id", "Box Id", "Before Bytes", "After Bytes" and
+"Description". Use -report FILE for a before and after size report.
+`,
+		Examples: `    {app} -report sizes.csv LibGuides_export_221133.xml LibGuides_export_cleaned.xml
+    {app} -patch LibGuides_export_221133.xml patches.json
+`,
+		SetFlags: func(fs *flag.FlagSet, opts *Options) {
+			fs.BoolVar(&opts.Patch, "patch", opts.Patch, "write a patch list rather than a cleaned export")
+			fs.StringVar(&opts.SizeReport, "report", opts.SizeReport, "write a size report to `FILE`")
+		},
+		Run: runClean,
+	},
+	{
+		Name:     "fixurls",
+		Args:     "-map MAP_FILE SOURCE_FILE [DESTINATION_FILE]",
+		Synopsis: "replace old URLs in assets through the API, dry run first",
+		Description: `Replaces old URLs with new ones in the url and rich text description
+of the assets. MAP_FILE is a CSV of old and new URLs, the first column
+is the old URL and the second the new one, or a header row may name
+the columns "Old URL" (or "URL") and "New URL" (or "Suggested URL" or
+"Redirect"). An asset reused in several boxes is changed once.
+
+Without -apply this is a dry run, the changes are written to
+DESTINATION_FILE as a table with the columns "Asset Id", "Name",
+"Field", "Old URL", "New URL", "Guide Ids", "Status", "Before" and
+"After", or with -format diff (or a .diff file) as a diff.
+
+With -apply and -source api the changes are made through the API,
+skipping assets changed by someone else since they were read. Each
+change is appended to the audit log (-audit-log, default
+fixurls-audit.jsonl) and the changes made are written to a rollback
+file (-rollback, default fixurls-rollback-TIMESTAMP.json). -undo FILE
+undoes the changes in a rollback file, as a dry run unless -apply is
+given too.
+`,
+		Examples: `Review the changes against the live site, then make them
+
+    {app} -source api -map redirects.csv -format diff
+    {app} -source api -map redirects.csv -apply -rollback undo.json fixed.csv
+
+Undo them
+
+    {app} -source api -undo undo.json -apply
+`,
+		SetFlags: func(fs *flag.FlagSet, opts *Options) {
+			fs.StringVar(&opts.URLMap, "map", opts.URLMap, "CSV `FILE` of old and new URLs")
+			fs.BoolVar(&opts.Apply, "apply", opts.Apply, "make the changes through the API")
+			fs.StringVar(&opts.Undo, "undo", opts.Undo, "undo the changes in rollback `FILE`")
+			fs.StringVar(&opts.AuditLog, "audit-log", opts.AuditLog, "append the changes made to `FILE`")
+			fs.StringVar(&opts.RollbackFile, "rollback", opts.RollbackFile, "write the changes made to rollback `FILE`")
+		},
+		TableReport: true,
+		Run:         runFixURLs,
+	},
+	{
+		Name:     "sqlite",
+		Args:     "SOURCE_FILE DESTINATION_FILE",
+		Synopsis: "export a LibGuides XML export to a SQLite database",
+		Description: `Writes a LibGuides' XML export to a new SQLite database for ad-hoc
+SQL analysis. The tables are customer, site, accounts, groups,
+subjects, tags, vendors, guides, guide_subjects, guide_tags, pages,
+boxes, panes, assets and links. The links table holds the link report
+(see "links"). Owners are kept as columns of guides and assets (with
+the account id in owner_id) as they may be missing from accounts.
+`,
+		Examples: `    {app} LibGuides_export_221133.xml libguides.db
+
+All assets by owner in guides tagged "patents"
+
+    sqlite3 libguides.db "SELECT a.owner_email, count(*) FROM assets a
+        JOIN boxes b USING (box_key) JOIN pages p ON p.id = b.page_id
+        JOIN guide_tags gt ON gt.guide_id = p.guide_id
+        JOIN tags t ON t.id = gt.tag_id
+        WHERE t.name = 'patents' GROUP BY a.owner_email"
+`,
+		Run: runSQLite,
+	},
+	{
+		Name:     "site",
+		Args:     "SOURCE_FILE DESTINATION_DIR",
+		Synopsis: "render a LibGuides XML export as a static site for archiving",
+		Description: `Renders the guides in a LibGuides' XML export as a browsable offline
+copy in DESTINATION_DIR. Each guide is a directory named by its id
+holding an index.html listing its pages and a file per page named by
+the page id. Pages keep their hierarchy (parent page id and position)
+and boxes their column layout. DESTINATION_DIR/index.html lists the
+guides by subject and by tag.
+
+Links to guides and pages in the export, by their URL or as
+c.php?g=...&p=... links on the site, are rewritten to the local files.
+Hidden pages and boxes are included according to -hidden.
+
+With -markdown the pages are written as Markdown with YAML front
+matter (ids, position, URL and modified date) rather than HTML.
+`,
+		Examples: `    {app} LibGuides_export_221133.xml archive
+    {app} -markdown LibGuides_export_221133.xml archive-md
+`,
+		SetFlags: func(fs *flag.FlagSet, opts *Options) {
+			fs.BoolVar(&opts.Markdown, "markdown", opts.Markdown, "write Markdown with front matter rather than HTML")
+		},
+		Run: runSite,
+	},
+	{
+		Name:     "jsonld",
+		Args:     "SOURCE_FILE [DESTINATION_FILE]",
+		Synopsis: "describe the guides and pages as schema.org JSON-LD",
+		Description: `Describes the published guides in a LibGuides' XML export as
+schema.org CreativeWorks with their pages as WebPages (hasPart), for
+search engines and discovery layers. The author is the guide's owner,
+the keywords its tags, the subjects are what it is about (about) and
+the dates come from created, modified and published. The customer is
+the publisher. URLs missing from the export are built from the site's
+domain (or the site_prefix setting).
+
+Unpublished (e.g. private) guides, redirects and hidden pages are
+always left out. The result is a JSON-LD document with a @graph of
+the guides.
+`,
+		Examples: `    {app} LibGuides_export_221133.xml guides.jsonld
+`,
+		Run: runJSONLD,
+	},
+	{
+		Name:     "sitemap",
+		Args:     "SOURCE_FILE [DESTINATION_FILE]",
+		Synopsis: "generate an XML sitemap of the published guides and pages",
+		Description: `Generates an XML sitemap (see https://www.sitemaps.org) listing the
+URLs of the published guides in a LibGuides' XML export and their
+pages, with lastmod from the date modified. URLs missing from the
+export are built from the site's domain (or the site_prefix setting),
+URLs on other hosts aren't listed. It is an error if neither is known.
+
+Unpublished (e.g. private) guides, redirects and hidden pages are
+always left out.
+`,
+		Examples: `    {app} LibGuides_export_221133.xml sitemap.xml
+    {app} -source api sitemap.xml
+`,
+		Run: runSitemap,
+	},
+	{
+		Name:     "rdf",
+		Args:     "SOURCE_FILE [DESTINATION_FILE]",
+		Synopsis: "export the site as RDF (Turtle or N-Triples)",
+		Description: `Exports a LibGuides' XML export as RDF for loading into a triplestore.
+The output is Turtle unless -format is ntriples (or nt) or the
+destination ends in ".nt".
+
+IRIs are built from the site's domain (or the site_prefix setting)
+and the ids, e.g. https://libguides.example.edu/id/guide/512671. It is
+an error if neither is known.
+Accounts and owners are foaf:Persons, groups foaf:Groups, the
+customer and vendors foaf:Organizations. Subjects and tags are
+skos:Concepts in the .../id/subjects and .../id/tags concept schemes.
+Guides, pages, boxes and assets are described with Dublin Core terms
+(title, description, creator, subject, created, modified, issued)
+and nested with dcterms:hasPart and dcterms:isPartOf. Assets
+reference (dcterms:references) the URLs they link to.
+
+Hidden pages and boxes are included according to -hidden.
+`,
+		Examples: `    {app} LibGuides_export_221133.xml libguides.ttl
+    {app} -format ntriples LibGuides_export_221133.xml > libguides.nt
+`,
+		Run: runRDF,
+	},
+	{
+		Name:     "serve",
+		Args:     "SOURCE_FILE|DIRECTORY",
+		Synopsis: "serve a LibGuides XML export as a read-only JSON REST service",
+		Description: `Serves the guide metadata of a LibGuides' XML export as read-only
+JSON over HTTP. Given a DIRECTORY the most recently modified export
+in it (*.xml, *.xml.gz or *.zip) is served and the directory is
+checked for a newer export every -poll seconds.
+
+    /                       what is being served
+    /guides                 guides without their pages
+    /guides/{id}            a guide with its pages, boxes and assets
+    /guides/{id}/pages      the pages of a guide
+    /assets/{id}            an asset and the boxes it is mapped into
+    /accounts               accounts
+    /subjects/{id}/guides   the guides with a subject
+    /links                  the link report
+
+Lists are paginated with page and per_page (default 50, at most
+1000) and returned as {"total", "page", "per_page", "items"}.
+/guides and /subjects/{id}/guides filter on status, type, owner
+(email), group, subject, tag and q (part of the name), /accounts on q
+(part of the name or email) and /links on status, category, guide_id
+and page_id. A link's status is "nonpublic" (or "broken") when its
+category shows patrons can't follow it from off campus (see "links")
+and "public" (or "ok") otherwise, no requests are made. Responses
+carry an ETag and If-None-Match is answered with 304 Not Modified.
+Hidden pages and boxes are served according to -hidden.
+`,
+		Examples: `    {app} -addr localhost:8080 LibGuides_export_221133.xml
+    curl 'http://localhost:8080/guides?tag=patents&per_page=10'
+    curl 'http://localhost:8080/links?status=broken'
+
+    {app} -poll 300 /data/libguides/exports
+`,
+		SetFlags: func(fs *flag.FlagSet, opts *Options) {
+			fs.StringVar(&opts.Addr, "addr", opts.Addr, "listen on `HOST:PORT`, default "+DefaultAddr)
+			fs.IntVar(&opts.PollSeconds, "poll", opts.PollSeconds, fmt.Sprintf("check a directory for new exports every `SECONDS`, default %d", DefaultPollSeconds))
+		},
+		Run: runServe,
+	},
+	{
+		Name:     "index",
+		Args:     "SOURCE_FILE DESTINATION_FILE",
+		Synopsis: "build a full-text search index of a LibGuides XML export",
+		Description: `Builds a full-text index of the guides, pages, boxes and assets in
+a LibGuides' XML export for the "search" command. The names and the
+plain text of the descriptions are indexed with the positions of the
+words so phrases can be found. Pages, boxes and assets carry the tags
+and subjects of their guide. Hidden pages and boxes are indexed
+according to -hidden.
+`,
+		Examples: `    {app} LibGuides_export_221133.xml libguides.idx
+`,
+		Run: runIndex,
+	},
+	{
+		Name:     "search",
+		Args:     "INDEX_FILE QUERY",
+		Synopsis: "search a full-text index of a LibGuides XML export",
+		Description: `Searches an index built by the "index" command (or a LibGuides' XML
+export, indexed on the fly) and reports the matching guides, pages,
+boxes and assets, best match first. A query is made of words,
+"quoted phrases" and field filters, all of which must match.
+
+    owner:VALUE     owner name or email contains VALUE
+    tag:VALUE       the guide is tagged VALUE
+    subject:VALUE   the guide has the subject VALUE
+    type:VALUE      object type (guide, page, box, asset) or the
+                    guide, box or asset type, e.g. type:"rich text"
+
+Field values may be quoted, e.g. subject:"Chemical Engineering". The
+columns of the report are "Score", "Object Type", "Id", "Guide Id",
+"Page Id", "Box Id", "Name", "Type" and "Owner". Use -o to write the
+report to a file.
+`,
+		Examples: `    {app} libguides.idx '"web of science"'
+    {app} libguides.idx 'patents type:asset owner:jane@example.edu'
+    {app} -format json -o results.json libguides.idx 'tag:chemistry databases'
+`,
+		TableReport: true,
+		Run:         runSearch,
+	},
+	{
+		Name:     "stats",
+		Args:     "SOURCE_FILE [DESTINATION_FILE]",
+		Synopsis: "inventory the content of a LibGuides XML export",
+		Description: `Counts the accounts, groups, subjects, tags, vendors, guides,
+pages, boxes and assets in a LibGuides' XML export. Guides are counted
+by status, type, group, owner, subject and tag, pages per guide, boxes
+and assets by type and assets per owner. Guides without pages or
+subjects are listed. The report is a table with the columns Category,
+Name and Count or, with -format summary, a one page HTML summary.
+`,
+		Examples: `    {app} LibGuides_export_221133.xml
+    {app} -where 'Category == "Asset Type"' LibGuides_export_221133.xml
+    {app} -format summary LibGuides_export_221133.xml inventory.html
+`,
+		TableReport: true,
+		Run:         runStats,
+	},
+	{
+		Name:     "diff",
+		Args:     "OLD_SOURCE_FILE NEW_SOURCE_FILE [DESTINATION_FILE]",
+		Synopsis: "report what changed between two LibGuides XML exports",
+		Description: `Compares two LibGuides' XML exports matching guides, pages, boxes
+and assets by id. Reports the records added, removed, moved (new
+parent or position) and modified (name, URL, owner, status, hidden,
+type or description). Records where only the Updated or Modified
+timestamp changed are reported as modified "updated".
+
+The format may be csv, json, xml or changelog. The changelog format is
+an HTML page grouped by guide. Hidden pages and boxes are compared
+according to -hidden.
+
+The columns of the report are "Action", "Object Type", "Id", "Name",
+"Guide Id", "Guide Name", "Page Id", "Field", "Old" and "New".
+`,
+		Examples: `    {app} LibGuides_export_2021-06.xml LibGuides_export_2021-07.xml changes.csv
+
+    {app} -format changelog export-06.xml.gz export-07.xml.gz > changelog.html
+`,
+		TableReport: true,
+		Run:         runDiff,
+	},
+	{
+		Name:     "stale",
+		Args:     "SOURCE_FILE [DESTINATION_FILE]",
+		Synopsis: "report guides and pages not modified within a review window",
+		Description: `Lists the guides and pages which have not been modified within the
+review window (default 18 months). A guide's date is its Modified
+timestamp (or Updated if missing), likewise for pages. Content with
+no date is listed too. The report shows the owner, status, group,
+page count and the newest asset update.
+
+The columns of the report are "Object Type", "Id", "Name", "Guide Id",
+"Owner", "Status", "Group", "Modified", "Page Count" and
+"Newest Asset Update".
+
+Use -rollup owner or -rollup group for a summary with the columns
+"Owner" (or "Group"), "Guides", "Stale Guides", "Stale Pages" and
+"Oldest Modified".
+`,
+		Examples: `    {app} -months 18 LibGuides_export_221133.xml stale.csv
+
+    {app} -rollup owner LibGuides_export_221133.xml stale-owners.csv
+`,
+		TableReport: true,
+		SetFlags: func(fs *flag.FlagSet, opts *Options) {
+			fs.IntVar(&opts.StaleMonths, "months", opts.StaleMonths, "review window in `MONTHS` (default 18)")
+			fs.StringVar(&opts.Rollup, "rollup", opts.Rollup, "summarize by `owner` or group")
+		},
+		Run: runStale,
+	},
+	{
+		Name:     "owners",
+		Args:     "SOURCE_FILE [DESTINATION_FILE]",
+		Synopsis: "report orphaned accounts and suggest ownership transfers",
+		Description: `Cross references the owners of guides and assets with the accounts
+in a LibGuides' XML export. The report lists guides and assets owned
+by someone missing from the accounts ("missing account"), accounts
+owning nothing ("unused account") and assets owned by someone other
+than the guide's owner ("mixed ownership"). The columns are "Issue",
+"Object Type", "Id", "Name", "Guide Id", "Owner" and "Guide Owner".
+
+With -departed FILE, a CSV of departed staff email addresses with an
+optional second column naming a successor, the report is a worksheet
+of the guides and assets to reassign. The suggested owner is the
+successor, else the account owning the most assets in the guide, else
+the account owning the most guides in the group. The columns are
+"Object Type", "Id", "Name", "Guide Id", "Guide Name", "Group",
+"Current Owner", "Suggested Owner", "Reason" and "New Owner".
+`,
+		Examples: `    {app} -group-by Issue LibGuides_export_221133.xml
+    {app} -departed departed.csv LibGuides_export_221133.xml reassign.csv
+`,
+		TableReport: true,
+		SetFlags: func(fs *flag.FlagSet, opts *Options) {
+			fs.StringVar(&opts.Departed, "departed", opts.Departed, "CSV `FILE` of departed staff emails and successors")
+		},
+		Run: runOwners,
+	},
+	{
+		Name:     "duplicates",
+		Args:     "SOURCE_FILE [DESTINATION_FILE]",
+		Synopsis: "find reused assets, copied pages and duplicated text",
+		Description: `Looks for duplicated content in a LibGuides' XML export. The report
+lists assets mapped into more than one box ("reused asset"), pages
+copied from other pages ("page copy") grouped by the original page,
+guide, page and asset descriptions with the same text ("duplicate text")
+and descriptions which are near duplicates ("similar text"). Text is
+compared after removing the HTML markup, punctuation and case, near
+duplicates by the Jaccard similarity of their three word shingles.
+Descriptions of fewer than 8 words are ignored.
+
+The columns are "Kind", "Group", "Object Type", "Id", "Name",
+"Guide Id", "Page Id", "Box Id" and "Detail". Rows with the same
+Kind and Group are copies of each other.
+`,
+		Examples: `    {app} LibGuides_export_221133.xml duplicates.csv
+    {app} -similarity 0.9 -where 'Kind == "similar text"' LibGuides_export_221133.xml
+`,
+		TableReport: true,
+		SetFlags: func(fs *flag.FlagSet, opts *Options) {
+			fs.Float64Var(&opts.Similarity, "similarity", opts.Similarity, "near duplicate `THRESHOLD` from 0 to 1 (default 0.8)")
+		},
+		Run: runDuplicates,
+	},
+	{
+		Name:     "vocabulary",
+		Args:     "SOURCE_FILE [DESTINATION_FILE]",
+		Synopsis: "report subject and tag usage and likely duplicates, merge them",
+		Description: `Reports the subjects and tags of a LibGuides' XML export with the
+number of guides using them. Terms no guide uses are "unused", terms
+guides use which aren't in the export's lists are "missing". Likely
+duplicates across both vocabularies are clustered: the same name
+ignoring case, spacing and punctuation ("same name"), the same word
+stems ("same stem"), one the start of the other, e.g. "Chem" and
+"chemistry" ("abbreviation"), or a small edit distance apart. The
+most used term of a vocabulary in a cluster is suggested as its
+preferred term. The columns are "Vocabulary", "Id", "Name", "Guides",
+"Status", "Cluster", "Match" and "Preferred".
+
+With -map FILE the vocabularies are merged and the merged XML export
+is written to DESTINATION_FILE. FILE is a CSV with the headings
+"Vocabulary", "Id", "Name" and "Preferred" (e.g. the report as CSV
+with the Preferred column edited) or two columns, the name and the
+preferred term. A term is merged into the term named by its preferred
+term, which the guides using it then use, or renamed if there is no
+such term.
+`,
+		Examples: `    {app} -where 'Cluster != ""' -sort Cluster LibGuides_export_221133.xml clusters.csv
+    {app} -map clusters.csv LibGuides_export_221133.xml LibGuides_export_merged.xml
+`,
+		TableReport: true,
+		SetFlags: func(fs *flag.FlagSet, opts *Options) {
+			fs.StringVar(&opts.VocabularyMap, "map", opts.VocabularyMap, "CSV `FILE` mapping subjects and tags to preferred terms")
+		},
+		Run: runVocabulary,
+	},
+	{
+		Name:     "accessibility",
+		Args:     "SOURCE_FILE [DESTINATION_FILE]",
+		Synopsis: "check the rich text descriptions for accessibility issues",
+		Description: `Parses the HTML descriptions of the guides, pages and assets in a
+LibGuides' XML export and reports WCAG relevant issues: images without
+alt text ("missing alt"), links without text ("empty link") or with
+text like "click here" ("ambiguous link text"), skipped heading levels
+("heading order"), tables without header cells ("table without
+headers"), text distinguished only by color ("color only") and
+presentational tags like <font> and <center> ("deprecated tag").
+
+The columns are "Issue", "WCAG", "Object Type", "Guide Id", "Page Id",
+"Box Id", "Asset Id", "Name", "Element" and "Detail".
+`,
+		Examples: `    {app} LibGuides_export_221133.xml accessibility.csv
+    {app} -group-by Issue LibGuides_export_221133.xml
+`,
+		TableReport: true,
+		Run:         runAccessibility,
+	},
+	{
+		Name:     "config",
+		Args:     "show",
+		Synopsis: "show the configuration settings in effect",
+		Description: `Shows the settings in effect after merging the configuration
+files, SPRINGYTOOLS_* environment variables and command line options.
+
+Settings are read from the JSON file named by -config (or
+$SPRINGYTOOLS_CONFIG). Otherwise $XDG_CONFIG_HOME/springytools/config.json
+(default $HOME/.config/springytools/config.json) and then springytools.json
+in the working directory are read if they exist. Environment variables
+override the files and command line options override both.
+
+The settings are format, hidden, verbose, site_prefix, proxy_patterns,
+ignore, intranet, owner_overrides, stale_months, similarity, where,
+group_by, sort, columns, no_clobber, backup, backup_suffix, perm, addr,
+poll_seconds, audit_log, source, api_base, client_id and client_secret,
+other settings (e.g. input and output) are an error. The environment
+variables are SPRINGYTOOLS_FORMAT, SPRINGYTOOLS_HIDDEN,
+SPRINGYTOOLS_VERBOSE, SPRINGYTOOLS_SITE_PREFIX, SPRINGYTOOLS_PROXY_PATTERNS,
+SPRINGYTOOLS_IGNORE, SPRINGYTOOLS_INTRANET, SPRINGYTOOLS_NO_CLOBBER,
+SPRINGYTOOLS_BACKUP, SPRINGYTOOLS_ADDR, SPRINGYTOOLS_SOURCE,
+SPRINGYTOOLS_API_BASE, SPRINGYTOOLS_CLIENT_ID and
+SPRINGYTOOLS_CLIENT_SECRET, lists are comma delimited. The client secret
+is shown masked.
+`,
+		Examples: `    {app} show
+
+    {app} -config caltech.json -hidden include show > merged.json
+`,
+		Run: runConfig,
+	},
+}
+
+// FindCommand returns the named subcommand or nil if not found.
+func FindCommand(name string) *Command {
+	for _, cmd := range Commands {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// SetFlags adds the global options to a flag set.
+func (o *Options) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Input, "i", o.Input, "read from `FILE`, \"-\" for standard input")
+	fs.StringVar(&o.Input, "input", o.Input, "read from `FILE`, \"-\" for standard input")
+	fs.StringVar(&o.Output, "o", o.Output, "write to `FILE`, \"-\" for standard output")
+	fs.StringVar(&o.Output, "output", o.Output, "write to `FILE`, \"-\" for standard output")
+	fs.StringVar(&o.Format, "format", o.Format, "report `FORMAT`, i.e. csv, json, xml")
+	fs.Var(&o.Hidden, "hidden", "hidden content `POLICY`, i.e. skip, include, only")
+	fs.BoolVar(&o.Verbose, "v", o.Verbose, "log progress to standard error")
+	fs.BoolVar(&o.Verbose, "verbose", o.Verbose, "log progress to standard error")
+	fs.BoolVar(&o.NoClobber, "no-clobber", o.NoClobber, "don't replace an existing destination file")
+	fs.BoolVar(&o.Backup, "backup", o.Backup, "keep a copy of a replaced file with a \"~\" suffix")
+	fs.StringVar(&o.Config, "config", o.Config, "read settings from configuration `FILE`")
+	fs.StringVar(&o.Source, "source", o.Source, "read LibGuides from `SOURCE`, i.e. export, api")
+}
+
+// SetTableFlags adds the table report options to a flag set.
+func (o *Options) SetTableFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Where, "where", o.Where, "only keep rows matching `EXPR`")
+	fs.Var(columnList{&o.GroupBy}, "group-by", "count rows grouped by `COLUMNS`")
+	fs.Var(columnList{&o.Sort}, "sort", "sort by `COLUMNS`, \"-\" prefix for descending")
+	fs.Var(columnList{&o.Columns}, "columns", "output only `COLUMNS`, in that order")
+}
+
+// SetInputOutput takes the SOURCE_FILE and DESTINATION_FILE from the
+// command line parameters, falling back to the -input and -output
+// options. The destination defaults to standard output. When reading
+// from the API there is no SOURCE_FILE and the input is the API's
+// base URL.
+func (o *Options) SetInputOutput(args []string) error {
+	if o.FromAPI() {
+		if len(args) > 1 {
+			return fmt.Errorf("too many parameters, expected [DESTINATION_FILE] with -source api")
+		}
+		if len(args) > 0 {
+			o.Output = args[0]
+		}
+		o.Input = o.apiBase()
+		if o.Output == "" {
+			o.Output = StdIO
+		}
+		return nil
+	}
+	if len(args) > 2 {
+		return fmt.Errorf("too many parameters, expected SOURCE_FILE [DESTINATION_FILE]")
+	}
+	if len(args) > 0 {
+		o.Input = args[0]
+	}
+	if len(args) > 1 {
+		o.Output = args[1]
+	}
+	if o.Input == "" {
+		return fmt.Errorf("missing SOURCE_FILE")
+	}
+	if o.Output == "" {
+		o.Output = StdIO
+	}
+	return nil
+}
+
+// flagUsage renders the options of a flag set, options sharing the
+// same description (e.g. -v and -verbose) are listed together.
+func flagUsage(fs *flag.FlagSet) string {
+	usages := []string{}
+	names := map[string][]string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		argName, usage := flag.UnquoteUsage(f)
+		name := "-" + f.Name
+		if argName != "" && !isBoolFlag(f) {
+			name = fmt.Sprintf("-%s %s", f.Name, argName)
+		}
+		if _, ok := names[usage]; !ok {
+			usages = append(usages, usage)
+		}
+		names[usage] = append(names[usage], name)
+	})
+	sb := new(strings.Builder)
+	for _, usage := range usages {
+		label := strings.Join(names[usage], ", ")
+		if len(label) > 20 {
+			fmt.Fprintf(sb, "    %s\n    %-20s  %s\n", label, "", usage)
+		} else {
+			fmt.Fprintf(sb, "    %-20s  %s\n", label, usage)
+		}
+	}
+	return sb.String()
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); ok {
+		return bf.IsBoolFlag()
+	}
+	return false
+}
+
+// commandFlags returns a flag set holding the global options, the
+// table options if needed and the command's own options.
+func commandFlags(appName string, cmd *Command, opts *Options) (*flag.FlagSet, *bool) {
+	help := false
+	fs := flag.NewFlagSet(appName, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.BoolVar(&help, "h", false, "display help")
+	fs.BoolVar(&help, "help", false, "display help")
+	opts.SetFlags(fs)
+	if cmd.TableReport {
+		opts.SetTableFlags(fs)
+	}
+	if cmd.SetFlags != nil {
+		cmd.SetFlags(fs, opts)
+	}
+	return fs, &help
+}
+
+// CommandUsage writes the usage of a command. appName is the name
+// used to invoke it, e.g. "springytools links" or "lglinkreport".
+func CommandUsage(out io.Writer, appName string, cmd *Command) {
+	fs, _ := commandFlags(appName, cmd, new(Options))
+	fmt.Fprintf(out, "\nUSAGE: %s [OPTIONS] %s\n\n%s", appName, cmd.Args, cmd.Description)
+	if strings.Contains(cmd.Args, "SOURCE_FILE") {
+		fmt.Fprintf(out, `
+Use "-" as the SOURCE_FILE to read from standard input and
+as the DESTINATION_FILE to write to standard output (the default).
+Gzip and zip compressed exports are read directly. A DESTINATION_FILE
+ending in ".gz" is written gzip compressed.
+`)
+	}
+	if cmd.TableReport {
+		fmt.Fprintf(out, `
+The -where EXPR compares a column to a value using ==, !=, <, <=,
+>, >=, =~ or !~ (regular expression match) and combines comparisons
+with &&, || and !, e.g. '"Object Type" == "Asset"'. COLUMNS is a
+comma delimited list of column names.
+`)
+	}
+	fmt.Fprintf(out, "\nOPTIONS\n\n%s", flagUsage(fs))
+	if cmd.Examples != "" {
+		fmt.Fprintf(out, "\nEXAMPLE\n\n%s", strings.ReplaceAll(cmd.Examples, "{app}", appName))
+	}
+	fmt.Fprintf(out, "\nspringytools v%s\n", Version)
+}
+
+// Usage writes the usage of the springytools command.
+func Usage(out io.Writer, appName string) {
+	fs := flag.NewFlagSet(appName, flag.ContinueOnError)
+	fs.Bool("h", false, "display help")
+	fs.Bool("help", false, "display help")
+	fs.Bool("version", false, "display version")
+	new(Options).SetFlags(fs)
+	fmt.Fprintf(out, `
+USAGE: %s [OPTIONS] COMMAND [COMMAND_OPTIONS] [PARAMETERS]
+
+A set of tools for working with LibGuides' XML exports.
+
+COMMANDS
+
+`, appName)
+	for _, cmd := range Commands {
+		fmt.Fprintf(out, "    %-10s  %s\n", cmd.Name, cmd.Synopsis)
+	}
+	fmt.Fprintf(out, "    %-10s  %s\n", "help", "display help for a COMMAND")
+	fmt.Fprintf(out, "    %-10s  %s\n", "version", "display version")
+	fmt.Fprintf(out, `
+OPTIONS
+
+The options are shared by all commands and may be given before or
+after the COMMAND.
+
+%s
+Use "%s help COMMAND" for more about a command.
+
+springytools v%s
+`, flagUsage(fs), appName, Version)
+}
+
+// runCommand parses the command's options and runs it. Returns the
+// exit code.
+func runCommand(appName string, cmd *Command, opts *Options, args []string) int {
+	fs, help := commandFlags(appName, cmd, opts)
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		CommandUsage(os.Stderr, appName, cmd)
+		return 1
+	}
+	if *help {
+		CommandUsage(os.Stdout, appName, cmd)
+		return 0
+	}
+	if err := opts.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	if len(opts.ConfigFiles) > 0 {
+		opts.Logf("read settings from %s", strings.Join(opts.ConfigFiles, ", "))
+	}
+	if err := cmd.Run(opts, fs.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// RunCommand runs the springytools command with the command line
+// parameters in args (not including the program name). Returns the
+// exit code.
+func RunCommand(appName string, args []string) int {
+	help, version := false, false
+	opts := new(Options)
+	if err := opts.Configure(args); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	fs := flag.NewFlagSet(appName, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.BoolVar(&help, "h", false, "display help")
+	fs.BoolVar(&help, "help", false, "display help")
+	fs.BoolVar(&version, "version", false, "display version")
+	opts.SetFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		Usage(os.Stderr, appName)
+		return 1
+	}
+	args = fs.Args()
+	switch {
+	case help:
+		Usage(os.Stdout, appName)
+		return 0
+	case version:
+		fmt.Printf("springytools, %s v%s\n", appName, Version)
+		return 0
+	case len(args) == 0:
+		fmt.Fprintf(os.Stderr, "Missing COMMAND\n")
+		Usage(os.Stderr, appName)
+		return 1
+	}
+	switch args[0] {
+	case "help":
+		if len(args) > 1 {
+			if cmd := FindCommand(args[1]); cmd != nil {
+				CommandUsage(os.Stdout, appName+" "+cmd.Name, cmd)
+				return 0
+			}
+			fmt.Fprintf(os.Stderr, "%q is not a command\n", args[1])
+			return 1
+		}
+		Usage(os.Stdout, appName)
+		return 0
+	case "version":
+		fmt.Printf("springytools, %s v%s\n", appName, Version)
+		return 0
+	}
+	cmd := FindCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "%q is not a command\n", args[0])
+		Usage(os.Stderr, appName)
+		return 1
+	}
+	return runCommand(appName+" "+cmd.Name, cmd, opts, args[1:])
+}
+
+// RunAlias runs a springytools subcommand as its own program, e.g.
+// lglinkreport is an alias for "springytools links". Returns the exit
+// code.
+func RunAlias(appName string, cmdName string, args []string) int {
+	cmd := FindCommand(cmdName)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "%q is not a command\n", cmdName)
+		return 1
+	}
+	for _, arg := range args {
+		if arg == "-version" || arg == "--version" {
+			fmt.Printf("springytools, %s v%s\n", appName, Version)
+			return 0
+		}
+	}
+	opts := new(Options)
+	if err := opts.Configure(args); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	return runCommand(appName, cmd, opts, args)
+}
+
+//
+// Subcommand implementations
+//
+
+func runConvert(opts *Options, args []string) error {
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	if opts.JSONLines {
+		return LibGuidesXMLFileToJSONLines(opts.Input, opts.Output, opts)
+	}
+	lg, err := opts.ReadLibGuides(opts.Input)
+	if err != nil {
+		return err
+	}
+	opts.Logf("read %d guides from %q", len(lg.Guides), opts.Input)
+	if opts.DescriptionText {
+		lg.SetDescriptionText()
+	}
+	src, err := lg.ToJSON()
+	if err != nil {
+		return err
+	}
+	return WriteDestinationWithOptions(opts.Output, src, &opts.WriteOptions)
+}
+
+func runLinks(opts *Options, args []string) error {
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	return LinkReportWithOptions(opts.Input, opts.Output, opts)
+}
+
+func runSanitize(opts *Options, args []string) error {
+	if opts.FromAPI() {
+		return fmt.Errorf("sanitize works on an export file, not -source api")
+	}
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	src, err := ReadSource(opts.Input)
+	if err != nil {
+		return err
+	}
+	src, cnt := SanitizeXML(src)
+	opts.Logf("removed or replaced %d characters in %q", cnt, opts.Input)
+	return WriteDestinationWithOptions(opts.Output, src, &opts.WriteOptions)
+}
+
+func runClean(opts *Options, args []string) error {
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	return CleanReport(opts.Input, opts.Output, opts)
+}
+
+func runConfig(opts *Options, args []string) error {
+	if len(args) != 1 || args[0] != "show" {
+		return fmt.Errorf("expected \"show\"")
+	}
+	shown := *opts
+	if shown.ClientSecret != "" {
+		shown.ClientSecret = "********"
+	}
+	src, err := shown.ToJSON()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s\n", src)
+	return nil
+}
+
+func runDiff(opts *Options, args []string) error {
+	if opts.FromAPI() {
+		// The API is the newer side
+		if len(args) < 1 || len(args) > 2 {
+			return fmt.Errorf("expected OLD_SOURCE_FILE [DESTINATION_FILE] with -source api")
+		}
+		if len(args) == 2 {
+			opts.Output = args[1]
+		}
+		if opts.Output == "" {
+			opts.Output = StdIO
+		}
+		return DiffReport(args[0], opts.apiBase(), opts.Output, opts)
+	}
+	if len(args) < 2 || len(args) > 3 {
+		return fmt.Errorf("expected OLD_SOURCE_FILE NEW_SOURCE_FILE [DESTINATION_FILE]")
+	}
+	if len(args) == 3 {
+		opts.Output = args[2]
+	}
+	if opts.Output == "" {
+		opts.Output = StdIO
+	}
+	return DiffReport(args[0], args[1], opts.Output, opts)
+}
+
+func runStale(opts *Options, args []string) error {
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	return StaleReport(opts.Input, opts.Output, opts)
+}
+
+func runAccessibility(opts *Options, args []string) error {
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	return AccessibilityReport(opts.Input, opts.Output, opts)
+}
+
+func runDuplicates(opts *Options, args []string) error {
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	return DuplicatesReport(opts.Input, opts.Output, opts)
+}
+
+func runVocabulary(opts *Options, args []string) error {
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	return VocabularyReport(opts.Input, opts.Output, opts)
+}
+
+func runOwners(opts *Options, args []string) error {
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	return OwnershipReport(opts.Input, opts.Output, opts)
+}
+
+func runFixURLs(opts *Options, args []string) error {
+	if opts.Undo != "" {
+		// The changes come from the rollback file
+		if len(args) > 1 {
+			return fmt.Errorf("too many parameters, expected [DESTINATION_FILE] with -undo")
+		}
+		if len(args) == 1 {
+			opts.Output = args[0]
+		}
+		if opts.Output == "" {
+			opts.Output = StdIO
+		}
+		return FixURLsReport("", opts.Output, opts)
+	}
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	return FixURLsReport(opts.Input, opts.Output, opts)
+}
+
+func runSQLite(opts *Options, args []string) error {
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	return SQLiteReport(opts.Input, opts.Output, opts)
+}
+
+func runSite(opts *Options, args []string) error {
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	return SiteReport(opts.Input, opts.Output, opts)
+}
+
+func runJSONLD(opts *Options, args []string) error {
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	return JSONLDReport(opts.Input, opts.Output, opts)
+}
+
+func runSitemap(opts *Options, args []string) error {
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	return SitemapReport(opts.Input, opts.Output, opts)
+}
+
+func runRDF(opts *Options, args []string) error {
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	return RDFReport(opts.Input, opts.Output, opts)
+}
+
+func runServe(opts *Options, args []string) error {
+	if opts.FromAPI() {
+		if len(args) > 0 {
+			return fmt.Errorf("too many parameters, expected none with -source api")
+		}
+		return Serve(opts.apiBase(), opts)
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("too many parameters, expected SOURCE_FILE|DIRECTORY")
+	}
+	if len(args) == 1 {
+		opts.Input = args[0]
+	}
+	if opts.Input == "" || opts.Input == StdIO {
+		return fmt.Errorf("missing SOURCE_FILE|DIRECTORY")
+	}
+	return Serve(opts.Input, opts)
+}
+
+func runIndex(opts *Options, args []string) error {
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	return SearchIndexReport(opts.Input, opts.Output, opts)
+}
+
+func runSearch(opts *Options, args []string) error {
+	if opts.FromAPI() {
+		opts.Input = opts.apiBase()
+	}
+	if opts.Input == "" && len(args) > 0 {
+		opts.Input, args = args[0], args[1:]
+	}
+	if opts.Input == "" || len(args) == 0 {
+		return fmt.Errorf("expected INDEX_FILE QUERY")
+	}
+	if opts.Output == "" {
+		opts.Output = StdIO
+	}
+	return SearchReport(opts.Input, strings.Join(args, " "), opts.Output, opts)
+}
+
+func runStats(opts *Options, args []string) error {
+	if err := opts.SetInputOutput(args); err != nil {
+		return err
+	}
+	return InventoryReport(opts.Input, opts.Output, opts)
+}
@@ -0,0 +1,148 @@
+// cli_test.go provides tests for cli.go and options.go
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestHiddenPolicy(t *testing.T) {
+	var h HiddenPolicy
+	expectedString(t, "skip", h.String())
+	if !h.Allows(false) || h.Allows(true) {
+		t.Errorf("expected the default policy to skip hidden content")
+	}
+	if err := h.Set("Include"); err != nil {
+		t.Fatal(err)
+	}
+	if !h.Allows(false) || !h.Allows(true) {
+		t.Errorf("expected include to allow all content")
+	}
+	h.Set("only")
+	if h.Allows(false) || !h.Allows(true) {
+		t.Errorf("expected only to allow hidden content")
+	}
+	if err := h.Set("maybe"); err == nil {
+		t.Errorf("expected an error for an unknown policy")
+	}
+}
+
+func TestLinkReportTableHidden(t *testing.T) {
+	lg := &LibGuides{
+		Site: &Site{Domain: "libguides.example.edu"},
+		Guides: []*Guide{
+			{
+				Id: 1,
+				Pages: []*Page{
+					{Id: 10, Url: "https://libguides.example.edu/c.php?g=1&p=10", Boxes: []*Box{
+						{Id: 100, Assets: []*Asset{{Id: 1000, Url: "https://visible.example.edu"}}},
+						{Id: 101, Hidden: 1, Assets: []*Asset{{Id: 1001, Url: "https://hidden-box.example.edu"}}},
+					}},
+					{Id: 11, Hidden: 1, Url: "https://libguides.example.edu/c.php?g=1&p=11", Boxes: []*Box{
+						{Id: 102, Assets: []*Asset{{Id: 1002, Url: "https://hidden-page.example.edu"}}},
+					}},
+				},
+			},
+		},
+	}
+	expectedInt(t, 2, len(LinkReportTable(lg, "skip", nil).Body.Rows))
+	expectedInt(t, 5, len(LinkReportTable(lg, "include", &Options{Hidden: HiddenInclude}).Body.Rows))
+	tbl := LinkReportTable(lg, "only", &Options{Hidden: HiddenOnly})
+	expectedInt(t, 3, len(tbl.Body.Rows))
+	expectedString(t, "https://hidden-box.example.edu", tbl.Body.Rows[0][0])
+
+	tbl = StatsTable(lg, "stats", HiddenInclude)
+	if err := tbl.Where(`"Object Type" =~ "^(Page|Box|Asset)$"`); err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, "2,3,3", tbl.Body.Rows[0][1]+","+tbl.Body.Rows[1][1]+","+tbl.Body.Rows[2][1])
+}
+
+func TestFlagUsage(t *testing.T) {
+	out := new(bytes.Buffer)
+	CommandUsage(out, "lglinkreport", FindCommand("links"))
+	usage := out.String()
+	for _, expected := range []string{
+		"USAGE: lglinkreport [OPTIONS] SOURCE_FILE [DESTINATION_FILE]",
+		"-v, -verbose",
+		"-where EXPR",
+		"lglinkreport -group-by Owner",
+	} {
+		if !strings.Contains(usage, expected) {
+			t.Errorf("expected usage to contain %q\n%s", expected, usage)
+		}
+	}
+	out.Reset()
+	Usage(out, "springytools")
+	for _, cmd := range Commands {
+		if !strings.Contains(out.String(), cmd.Synopsis) {
+			t.Errorf("expected usage to list %q", cmd.Name)
+		}
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	srcName := "testinput/LibGuides_export_XXXXX.xml"
+	destName := "testout/cli-links.json"
+	if code := RunCommand("springytools", []string{"-format", "json", "links", "-where", `"Object Type" == "Subject"`, srcName, destName}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	src, err := ioutil.ReadFile(destName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl := new(Table)
+	if err := json.Unmarshal(src, tbl); err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 8, len(tbl.Body.Rows))
+
+	destName = "testout/cli-stats.csv"
+	if code := RunAlias("lgstats", "stats", []string{"-o", destName, "-i", srcName}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if code := RunCommand("springytools", []string{"stats", "-no-clobber", srcName, destName}); code == 0 {
+		t.Errorf("expected no clobber to fail")
+	}
+	if code := RunCommand("springytools", []string{"nosuchcommand"}); code == 0 {
+		t.Errorf("expected an unknown command to fail")
+	}
+	if code := RunCommand("springytools", []string{"convert"}); code == 0 {
+		t.Errorf("expected a missing source to fail")
+	}
+}
@@ -1,5 +1,5 @@
 // linkreport.go traverse all the fields that have links and reports
-// where they are found. Output is JSON.
+// where they are found. It is an alias for "springytools links".
 //
 // Author: R. S. Doiel <rsdoiel@caltech.edu>
 //
@@ -35,8 +35,6 @@
 package main
 
 import (
-	"flag"
-	"fmt"
 	"os"
 	"path"
 
@@ -44,64 +42,7 @@ import (
 	"github.com/caltechlibrary/springytools"
 )
 
-func usage(appName string, exitCode int) {
-	out := os.Stderr
-	if exitCode == 0 {
-		out = os.Stdout
-	}
-	fmt.Fprintf(out, `
-USAGE: %s
-
-    %s SOURCE_FILE DESTINATION_FILE
-
-Reads a LibGuides' XML export and generates JSON reporting
-on links founds and where they were found.
-
-OPTIONS
-
-    -h, -help      display help
-    -fmt FORMAT    set the output format, 
-                   i.e. csv (defualt), json, xml
-
-EXAMPLE
-
-    %s LibGuides_export_221133.xml links.json
-
-springytools v%s
-`, appName, appName, appName, springytools.Version)
-	os.Exit(exitCode)
-}
-
 func main() {
-	// command line name and options support
 	appName := path.Base(os.Args[0])
-	help, version := false, false
-	format := "csv"
-	args := []string{}
-	// Setup to parse command line
-	flag.BoolVar(&help, "h", false, "display help")
-	flag.BoolVar(&help, "help", false, "display help")
-	flag.BoolVar(&version, "version", false, "display version")
-	flag.StringVar(&format, "format", format, "output report using format (i.e. csv, json, xml)")
-	flag.Parse()
-
-	args = flag.Args()
-
-	// Process options and run report
-	if help {
-		usage(appName, 0)
-	}
-	if version {
-		fmt.Printf("springytools, %s v%s\n", appName, springytools.Version)
-		os.Exit(0)
-	}
-	if len(args) != 2 {
-		fmt.Printf("Missing source or destination names\n\n")
-		usage(appName, 1)
-	}
-	err := springytools.LinkReport(args[0], args[1], format)
-	if err != nil {
-		fmt.Printf("ERROR: %s", err)
-		os.Exit(1)
-	}
+	os.Exit(springytools.RunAlias(appName, "links", os.Args[1:]))
 }
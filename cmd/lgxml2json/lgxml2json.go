@@ -1,4 +1,5 @@
-// lgxml2json.go converts a LibGuides XML export into JSON
+// lgxml2json.go converts a LibGuides XML export into JSON. It is an alias for
+// "springytools convert".
 //
 // Author: R. S. Doiel <rsdoiel@caltech.edu>
 //
@@ -34,8 +35,6 @@
 package main
 
 import (
-	"flag"
-	"fmt"
 	"os"
 	"path"
 
@@ -43,51 +42,7 @@ import (
 	"github.com/caltechlibrary/springytools"
 )
 
-func usage(appName string, exitCode int) {
-	fmt.Printf(`
-USAGE: %s
-
-    %s SOURCE_FILE DESTINATION_FILE
-
-Converts a LibGuides' XML export to JSON.
-
-EXAMPLE
-
-    %s LibGuides_export_221133.xml LibGuides_export_221133.json
-
-springytools v%s
-`, appName, appName, appName, springytools.Version)
-	os.Exit(exitCode)
-}
-
 func main() {
-	var (
-		help, version bool     // display help or version pages
-		appName       string   // application name
-		args          []string // non-optional command line parameters
-	)
-	appName = path.Base(os.Args[0])
-	flag.BoolVar(&help, "h", false, "display help")
-	flag.BoolVar(&help, "help", false, "display help")
-	flag.BoolVar(&version, "version", false, "display version")
-	flag.Parse()
-
-	args = flag.Args()
-
-	if help {
-		usage(appName, 0)
-	}
-	if version {
-		fmt.Printf("springytools, %s v%s\n", appName, springytools.Version)
-		os.Exit(0)
-	}
-	if len(args) != 2 {
-		fmt.Printf("Missing paramaters source or destination names\n\n")
-		usage(appName, 1)
-	}
-	err := springytools.LibGuidesXMLFileToJSONFile(args[0], args[1])
-	if err != nil {
-		fmt.Printf("ERROR: %s", err)
-		os.Exit(1)
-	}
+	appName := path.Base(os.Args[0])
+	os.Exit(springytools.RunAlias(appName, "convert", os.Args[1:]))
 }
@@ -0,0 +1,276 @@
+// config.go provides loading of springytools settings from a JSON configuration
+// file and environment variables.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ConfigName is the name of the configuration file looked for
+	// in the working directory.
+	ConfigName = "springytools.json"
+
+	// EnvPrefix is the prefix of the environment variables which
+	// override the configuration file, e.g. SPRINGYTOOLS_FORMAT.
+	EnvPrefix = "SPRINGYTOOLS_"
+)
+
+// ConfigKeys are the settings a configuration file may hold. Settings
+// of a single run, like the input and output, are left to the command
+// line.
+var ConfigKeys = []string{
+	"format", "hidden", "verbose", "site_prefix", "proxy_patterns",
+	"ignore", "intranet", "owner_overrides", "stale_months", "similarity",
+	"where", "group_by", "sort", "columns", "no_clobber", "backup",
+	"backup_suffix", "perm", "addr", "poll_seconds", "audit_log", "source",
+	"api_base", "client_id", "client_secret",
+}
+
+// isConfigKey returns true if key is one of the ConfigKeys.
+func isConfigKey(key string) bool {
+	for _, k := range ConfigKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigPaths returns the configuration files searched when no
+// configuration file is named. These are $XDG_CONFIG_HOME/springytools/config.json
+// (defaulting to $HOME/.config) and springytools.json in the working
+// directory. Settings in the later files take precedence.
+func ConfigPaths() []string {
+	paths := []string{}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			configHome = filepath.Join(home, ".config")
+		}
+	}
+	if configHome != "" {
+		paths = append(paths, filepath.Join(configHome, "springytools", "config.json"))
+	}
+	return append(paths, ConfigName)
+}
+
+// LoadConfig reads a JSON configuration file and sets the options
+// found in it. Options missing from the file are left unchanged. It is
+// an error if the file holds a setting not in ConfigKeys. The email
+// addresses of owner_overrides are lower cased, two differing only in
+// case are an error.
+func (o *Options) LoadConfig(fName string) error {
+	src, err := ioutil.ReadFile(fName)
+	if err != nil {
+		return err
+	}
+	settings := map[string]json.RawMessage{}
+	if err := json.Unmarshal(src, &settings); err != nil {
+		return fmt.Errorf("%s: %s", fName, err)
+	}
+	for key := range settings {
+		if !isConfigKey(key) {
+			return fmt.Errorf("%s: unknown setting %q", fName, key)
+		}
+	}
+	// Owner overrides are keyed by lower case email address, merged
+	// with those of any earlier configuration file
+	overrides := map[string]string{}
+	for email, name := range o.OwnerOverrides {
+		overrides[email] = name
+	}
+	if err := json.Unmarshal(src, o); err != nil {
+		return fmt.Errorf("%s: %s", fName, err)
+	}
+	if raw, ok := settings["owner_overrides"]; ok {
+		found := map[string]string{}
+		if err := json.Unmarshal(raw, &found); err != nil {
+			return fmt.Errorf("%s: %s", fName, err)
+		}
+		seen := map[string]bool{}
+		for email, name := range found {
+			key := strings.ToLower(strings.TrimSpace(email))
+			if seen[key] {
+				return fmt.Errorf("%s: owner_overrides has %q more than once", fName, key)
+			}
+			seen[key] = true
+			overrides[key] = name
+		}
+		o.OwnerOverrides = overrides
+	}
+	return nil
+}
+
+// ApplyEnv overrides options with the SPRINGYTOOLS_* environment
+// variables. Lists are comma delimited.
+func (o *Options) ApplyEnv() error {
+	lookup := func(name string) (string, bool) {
+		return os.LookupEnv(EnvPrefix + name)
+	}
+	boolean := func(name string, val *bool) error {
+		if s, ok := lookup(name); ok {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("%s%s: %s", EnvPrefix, name, err)
+			}
+			*val = b
+		}
+		return nil
+	}
+	list := func(s string) []string {
+		items := []string{}
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	if s, ok := lookup("FORMAT"); ok {
+		o.Format = s
+	}
+	if s, ok := lookup("HIDDEN"); ok {
+		if err := o.Hidden.Set(s); err != nil {
+			return fmt.Errorf("%sHIDDEN: %s", EnvPrefix, err)
+		}
+	}
+	if s, ok := lookup("SITE_PREFIX"); ok {
+		o.SitePrefix = s
+	}
+	if s, ok := lookup("PROXY_PATTERNS"); ok {
+		o.ProxyPatterns = list(s)
+	}
+	if s, ok := lookup("IGNORE"); ok {
+		o.Ignore = list(s)
+	}
+	if s, ok := lookup("INTRANET"); ok {
+		o.Intranet = list(s)
+	}
+	if s, ok := lookup("ADDR"); ok {
+		o.Addr = s
+	}
+	if s, ok := lookup("SOURCE"); ok {
+		o.Source = s
+	}
+	if s, ok := lookup("API_BASE"); ok {
+		o.APIBase = s
+	}
+	if s, ok := lookup("CLIENT_ID"); ok {
+		o.ClientId = s
+	}
+	if s, ok := lookup("CLIENT_SECRET"); ok {
+		o.ClientSecret = s
+	}
+	if err := boolean("VERBOSE", &o.Verbose); err != nil {
+		return err
+	}
+	if err := boolean("NO_CLOBBER", &o.NoClobber); err != nil {
+		return err
+	}
+	return boolean("BACKUP", &o.Backup)
+}
+
+// configFlag returns the value of a -config option found in args
+// so the configuration can be loaded before the options are parsed.
+func configFlag(args []string) string {
+	for i, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if name == "config" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(name, "config=") {
+			return strings.TrimPrefix(name, "config=")
+		}
+	}
+	return ""
+}
+
+// Configure sets the options from the configuration file(s) and then
+// the environment. The configuration file named by a -config option
+// in args (or $SPRINGYTOOLS_CONFIG) is used if present, otherwise the
+// ConfigPaths that exist are loaded in order. Command line options
+// are parsed afterwards so they take precedence.
+func (o *Options) Configure(args []string) error {
+	fName := configFlag(args)
+	if fName == "" {
+		fName = os.Getenv(EnvPrefix + "CONFIG")
+	}
+	if fName != "" {
+		if err := o.LoadConfig(fName); err != nil {
+			return err
+		}
+		o.ConfigFiles = []string{fName}
+	} else {
+		for _, fName := range ConfigPaths() {
+			if _, err := os.Stat(fName); err != nil {
+				continue
+			}
+			if err := o.LoadConfig(fName); err != nil {
+				return err
+			}
+			o.ConfigFiles = append(o.ConfigFiles, fName)
+		}
+	}
+	return o.ApplyEnv()
+}
+
+// ToJSON renders the ConfigKeys settings of the options as a JSON
+// configuration file.
+func (o *Options) ToJSON() ([]byte, error) {
+	src, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	settings := map[string]json.RawMessage{}
+	if err := json.Unmarshal(src, &settings); err != nil {
+		return nil, err
+	}
+	for key := range settings {
+		if !isConfigKey(key) {
+			delete(settings, key)
+		}
+	}
+	return json.MarshalIndent(settings, "", "    ")
+}
@@ -0,0 +1,128 @@
+// config_test.go provides tests for config.go
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestConfigure(t *testing.T) {
+	fName := "testout/springytools-config.json"
+	src := []byte(`{
+    "format": "json",
+    "hidden": "include",
+    "site_prefix": "https://guides.example.edu/",
+    "proxy_patterns": [ "https://proxy.example.edu/login?url=" ],
+    "ignore": [ "^https://ignore\\.example\\.edu" ],
+    "owner_overrides": { "Gone@example.edu": "Still Here <here@example.edu>" },
+    "sort": [ "Owner" ]
+}`)
+	if err := ioutil.WriteFile(fName, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, fName, configFlag([]string{"links", "-config", fName, "export.xml"}))
+	expectedString(t, fName, configFlag([]string{"--config=" + fName}))
+	expectedString(t, "", configFlag([]string{"links", "config"}))
+
+	os.Setenv("SPRINGYTOOLS_FORMAT", "csv")
+	os.Setenv("SPRINGYTOOLS_IGNORE", "^https://ignore\\.example\\.edu, ^mailto:")
+	defer os.Unsetenv("SPRINGYTOOLS_FORMAT")
+	defer os.Unsetenv("SPRINGYTOOLS_IGNORE")
+
+	opts := new(Options)
+	if err := opts.Configure([]string{"-config", fName, "links"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, "csv", opts.Format)
+	expectedString(t, "include", opts.Hidden.String())
+	expectedInt(t, 2, len(opts.Ignore))
+	expectedInt(t, 1, len(opts.Sort))
+	expectedInt(t, 1, len(opts.ConfigFiles))
+
+	// Check the settings are applied by the link report
+	owner := Owner{Email: "gone@example.edu"}
+	lg := &LibGuides{
+		Guides: []*Guide{{Id: 1, Owner: owner, Pages: []*Page{{Id: 2, Hidden: 1, Boxes: []*Box{{Assets: []*Asset{
+			{Id: 3, Owner: owner, Url: "https://proxy.example.edu/login?url=https%3A%2F%2Fdoi.org%2F10.1000%2F1"},
+			{Id: 4, Owner: owner, Url: "https://ignore.example.edu/page.html"},
+		}}}}}}},
+	}
+	tbl := LinkReportTable(lg, "config", opts)
+	expectedInt(t, 1, len(tbl.Body.Rows))
+	if len(tbl.Body.Rows) == 1 {
+		expectedString(t, "https://doi.org/10.1000/1", tbl.Body.Rows[0][0])
+		expectedString(t, "Still Here <here@example.edu>", tbl.Body.Rows[0][1])
+		expectedString(t, "https://guides.example.edu/c.php?g=1&p=2", tbl.Body.Rows[0][6])
+	}
+
+	os.Setenv("SPRINGYTOOLS_HIDDEN", "sometimes")
+	defer os.Unsetenv("SPRINGYTOOLS_HIDDEN")
+	if err := new(Options).Configure([]string{"-config", fName}); err == nil {
+		t.Errorf("expected an error for an invalid SPRINGYTOOLS_HIDDEN")
+	}
+	// Settings of a single run can't be set by a configuration file
+	if err := ioutil.WriteFile(fName, []byte(`{ "format": "csv", "output": "report.csv" }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := new(Options).LoadConfig(fName); err == nil {
+		t.Errorf("expected an error for an output setting")
+	}
+	// Owner overrides are matched on the lower case address
+	expectedString(t, "Still Here <here@example.edu>", opts.OwnerOverrides["gone@example.edu"])
+	if err := ioutil.WriteFile(fName, []byte(`{ "owner_overrides": { "gone@example.edu": "A", "Gone@Example.edu": "B" } }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := new(Options).LoadConfig(fName); err == nil {
+		t.Errorf("expected an error for owner overrides differing only in case")
+	}
+	// config show writes only the configuration settings
+	src, err := (&Options{Input: "export.xml", Format: "csv"}).ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, "{\n    \"format\": \"csv\"\n}", string(src))
+	opts = &Options{Ignore: []string{"("}}
+	if err := opts.Validate(); err == nil {
+		t.Errorf("expected an error for an invalid ignore pattern")
+	}
+	opts = &Options{Intranet: []string{"["}}
+	if err := opts.Validate(); err == nil {
+		t.Errorf("expected an error for an invalid intranet pattern")
+	}
+}
@@ -0,0 +1,349 @@
+// diff.go compares two LibGuides exports and reports the guides, pages, boxes
+// and assets added, removed, moved or modified between them.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+)
+
+const (
+	// ChangeAdded is a record found only in the newer export
+	ChangeAdded = "added"
+	// ChangeRemoved is a record found only in the older export
+	ChangeRemoved = "removed"
+	// ChangeMoved is a record with a new parent or position
+	ChangeMoved = "moved"
+	// ChangeModified is a record with changed content
+	ChangeModified = "modified"
+)
+
+// Change describes one difference between two LibGuides exports.
+// Modified and moved records have one Change per field changed.
+type Change struct {
+	Action    string `json:"action"`
+	Type      string `json:"type"`
+	Id        int    `json:"id"`
+	Name      string `json:"name,omitempty"`
+	GuideId   int    `json:"guide_id"`
+	GuideName string `json:"guide_name,omitempty"`
+	PageId    int    `json:"page_id,omitempty"`
+	Field     string `json:"field,omitempty"`
+	Old       string `json:"old,omitempty"`
+	New       string `json:"new,omitempty"`
+}
+
+// diffRecord is a flattened guide, page, box or asset used to compare
+// exports. Fields are compared in order, parent fields report a move.
+type diffRecord struct {
+	objType   string
+	id        int
+	name      string
+	guideId   int
+	guideName string
+	pageId    int
+	fields    [][2]string
+	parents   [][2]string
+	updated   string
+}
+
+// descriptionHash returns a short fingerprint of a description so
+// edits can be spotted without reporting the full HTML.
+func descriptionHash(s string) string {
+	if s == "" {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))[0:12]
+}
+
+// diffRecords flattens a LibGuides object into records keyed by type
+// and id. Assets mapped into more than one box are keyed by map id.
+func diffRecords(lg *LibGuides) (map[string]*diffRecord, []string) {
+	records := map[string]*diffRecord{}
+	keys := []string{}
+	add := func(key string, rec *diffRecord) {
+		if _, ok := records[key]; !ok {
+			keys = append(keys, key)
+		}
+		records[key] = rec
+	}
+	for _, guide := range lg.Guides {
+		add(fmt.Sprintf("Guide:%d", guide.Id), &diffRecord{
+			objType: "Guide", id: guide.Id, name: guide.Name,
+			guideId: guide.Id, guideName: guide.Name,
+			fields: [][2]string{
+				{"name", guide.Name},
+				{"url", guide.Url},
+				{"owner", guide.Owner.Email},
+				{"status", guide.Status},
+				{"description", descriptionHash(guide.Description)},
+			},
+			updated: guide.Modified + " " + guide.Updated,
+		})
+		for _, page := range guide.Pages {
+			add(fmt.Sprintf("Page:%d", page.Id), &diffRecord{
+				objType: "Page", id: page.Id, name: page.Name,
+				guideId: guide.Id, guideName: guide.Name, pageId: page.Id,
+				fields: [][2]string{
+					{"name", page.Name},
+					{"url", page.Url},
+					{"hidden", strInt(page.Hidden)},
+					{"description", descriptionHash(page.Description)},
+				},
+				parents: [][2]string{
+					{"guide_id", strInt(guide.Id)},
+					{"parent_page_id", strInt(page.ParentPageId)},
+					{"position", strInt(page.Position)},
+				},
+				updated: page.Modified + " " + page.Updated,
+			})
+			for _, box := range page.Boxes {
+				add(fmt.Sprintf("Box:%d", box.Id), &diffRecord{
+					objType: "Box", id: box.Id, name: box.Name,
+					guideId: guide.Id, guideName: guide.Name, pageId: page.Id,
+					fields: [][2]string{
+						{"name", box.Name},
+						{"type", box.Type},
+						{"hidden", strInt(box.Hidden)},
+					},
+					parents: [][2]string{
+						{"page_id", strInt(page.Id)},
+						{"column", strInt(box.Column)},
+						{"position", strInt(box.Position)},
+					},
+					updated: box.Updated,
+				})
+				assets := append([]*Asset{}, box.Assets...)
+				for _, pane := range box.Panes {
+					assets = append(assets, pane.Assets...)
+				}
+				for _, asset := range assets {
+					key := fmt.Sprintf("Asset:%d", asset.Id)
+					if asset.MapId != "" {
+						key = fmt.Sprintf("Asset:%d:%s", asset.Id, asset.MapId)
+					}
+					add(key, &diffRecord{
+						objType: "Asset", id: asset.Id, name: asset.Name,
+						guideId: guide.Id, guideName: guide.Name, pageId: page.Id,
+						fields: [][2]string{
+							{"name", asset.Name},
+							{"type", asset.Type},
+							{"url", asset.Url},
+							{"owner", asset.Owner.Email},
+							{"description", descriptionHash(asset.Description)},
+						},
+						parents: [][2]string{
+							{"box_id", strInt(box.Id)},
+							{"position", strInt(asset.Position)},
+						},
+						updated: asset.Updated,
+					})
+				}
+			}
+		}
+	}
+	return records, keys
+}
+
+// DiffLibGuides compares an older and newer LibGuides export matching
+// guides, pages, boxes and assets by id. It returns the records added,
+// removed, moved (new parent or position) and modified (name, URL,
+// owner, status, hidden, type or description). A record with a new
+// Updated or Modified timestamp but no other change is reported as
+// modified with the timestamps as the old and new values.
+func DiffLibGuides(older *LibGuides, newer *LibGuides) []*Change {
+	oldRecords, oldKeys := diffRecords(older)
+	newRecords, newKeys := diffRecords(newer)
+	changes := []*Change{}
+	change := func(action string, rec *diffRecord, field, oldVal, newVal string) {
+		changes = append(changes, &Change{
+			Action: action, Type: rec.objType, Id: rec.id, Name: rec.name,
+			GuideId: rec.guideId, GuideName: rec.guideName, PageId: rec.pageId,
+			Field: field, Old: oldVal, New: newVal,
+		})
+	}
+	for _, key := range oldKeys {
+		if _, ok := newRecords[key]; !ok {
+			change(ChangeRemoved, oldRecords[key], "", "", "")
+		}
+	}
+	for _, key := range newKeys {
+		rec := newRecords[key]
+		oldRec, ok := oldRecords[key]
+		if !ok {
+			change(ChangeAdded, rec, "", "", "")
+			continue
+		}
+		changed := false
+		for i, parent := range rec.parents {
+			if oldVal := oldRec.parents[i][1]; oldVal != parent[1] {
+				change(ChangeMoved, rec, parent[0], oldVal, parent[1])
+				changed = true
+			}
+		}
+		for i, field := range rec.fields {
+			if oldVal := oldRec.fields[i][1]; oldVal != field[1] {
+				change(ChangeModified, rec, field[0], oldVal, field[1])
+				changed = true
+			}
+		}
+		if !changed && strings.TrimSpace(oldRec.updated) != strings.TrimSpace(rec.updated) {
+			change(ChangeModified, rec, "updated", strings.TrimSpace(oldRec.updated), strings.TrimSpace(rec.updated))
+		}
+	}
+	return changes
+}
+
+// ChangesTable returns the changes as a Table for CSV, JSON or XML output.
+func ChangesTable(changes []*Change, caption string) *Table {
+	tbl := new(Table)
+	tbl.SetCaption(caption)
+	tbl.AppendHeadings("Action", "Object Type", "Id", "Name",
+		"Guide Id", "Guide Name", "Page Id",
+		"Field", "Old", "New")
+	for _, c := range changes {
+		pageId := ""
+		if c.PageId != 0 {
+			pageId = strInt(c.PageId)
+		}
+		tbl.AppendRow(c.Action, c.Type, strInt(c.Id), c.Name,
+			strInt(c.GuideId), c.GuideName, pageId,
+			c.Field, c.Old, c.New)
+	}
+	return tbl
+}
+
+var changelogTemplate = template.Must(template.New("changelog").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+<p>{{ len .Changes }} changes in {{ len .Guides }} guides.</p>
+{{ range .Guides }}
+<section>
+<h2>{{ if .Name }}{{ .Name }}{{ else }}Guide{{ end }} ({{ .Id }})</h2>
+<ul>
+{{- range .Changes }}
+<li><strong>{{ .Action }}</strong> {{ .Type }} {{ .Id }}{{ if .Name }} &ldquo;{{ .Name }}&rdquo;{{ end }}
+{{- if .Field }}: {{ .Field }}{{ if .Old }} <del>{{ .Old }}</del>{{ end }}{{ if .New }} <ins>{{ .New }}</ins>{{ end }}{{ end }}</li>
+{{- end }}
+</ul>
+</section>
+{{ end }}
+</body>
+</html>
+`))
+
+// ChangesHTML renders the changes as an HTML changelog grouped by guide.
+func ChangesHTML(changes []*Change, title string) ([]byte, error) {
+	type guideChanges struct {
+		Id      int
+		Name    string
+		Changes []*Change
+	}
+	guides := []*guideChanges{}
+	byGuide := map[int]*guideChanges{}
+	for _, c := range changes {
+		g, ok := byGuide[c.GuideId]
+		if !ok {
+			g = &guideChanges{Id: c.GuideId, Name: c.GuideName}
+			byGuide[c.GuideId] = g
+			guides = append(guides, g)
+		}
+		g.Changes = append(g.Changes, c)
+	}
+	sort.SliceStable(guides, func(i, j int) bool {
+		return guides[i].Id < guides[j].Id
+	})
+	buf := new(bytes.Buffer)
+	err := changelogTemplate.Execute(buf, map[string]interface{}{
+		"Title":   title,
+		"Changes": changes,
+		"Guides":  guides,
+	})
+	return buf.Bytes(), err
+}
+
+// ChangelogFormat is the format name of the HTML changelog written by
+// DiffReport, "html" remains the table format.
+const ChangelogFormat = "changelog"
+
+// visibleLibGuides returns a copy of a LibGuides object with the pages
+// and boxes of its guides allowed by the hidden policy.
+func visibleLibGuides(lg *LibGuides, hidden HiddenPolicy) *LibGuides {
+	visible := *lg
+	visible.Guides = []*Guide{}
+	for _, guide := range lg.Guides {
+		visible.Guides = append(visible.Guides, visibleGuide(guide, hidden))
+	}
+	return &visible
+}
+
+// DiffReport reads two LibGuides exports and writes the changes from
+// oldName to newName to destName. The pages and boxes of both exports
+// are compared according to opts.Hidden. The format in opts may be
+// csv, json or xml (a table) or changelog (an HTML changelog). Returns
+// an error if any encountered.
+func DiffReport(oldName string, newName string, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	older, err := ReadLibGuides(oldName)
+	if err != nil {
+		return err
+	}
+	newer, err := opts.ReadLibGuides(newName)
+	if err != nil {
+		return err
+	}
+	changes := DiffLibGuides(visibleLibGuides(older, opts.Hidden), visibleLibGuides(newer, opts.Hidden))
+	opts.Logf("found %d changes from %q to %q", len(changes), oldName, newName)
+	caption := fmt.Sprintf("Changes from %q to %q", oldName, newName)
+	if strings.EqualFold(opts.Format, ChangelogFormat) {
+		src, err := ChangesHTML(changes, caption)
+		if err != nil {
+			return err
+		}
+		return WriteDestinationWithOptions(destName, src, &opts.WriteOptions)
+	}
+	return opts.WriteTable(ChangesTable(changes, caption), destName)
+}
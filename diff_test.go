@@ -0,0 +1,164 @@
+// diff_test.go provides tests for diff.go
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func diffFixture() *LibGuides {
+	owner := Owner{Id: 1, Email: "shrimps@engineering.example.edu"}
+	return &LibGuides{
+		Guides: []*Guide{
+			{
+				Id: 1, Name: "Engineering", Status: "Published", Owner: owner,
+				Modified: "2021-06-01 10:00:00",
+				Pages: []*Page{
+					{Id: 10, Name: "Home", Position: 1, Modified: "2021-06-01 10:00:00", Boxes: []*Box{
+						{Id: 100, Name: "Databases", Column: 1, Position: 1, Assets: []*Asset{
+							{Id: 1000, Name: "Web of Science", Url: "http://wos.example.edu", Owner: owner, MapId: "5000", Position: 1},
+							{Id: 1001, Name: "Notes", Description: "<p>Old notes</p>", Owner: owner, MapId: "5001", Position: 2},
+						}},
+					}},
+					{Id: 11, Name: "Journals", Position: 2},
+				},
+			},
+			{Id: 2, Name: "Retired", Status: "Private", Owner: owner},
+		},
+	}
+}
+
+func TestDiffLibGuides(t *testing.T) {
+	older, newer := diffFixture(), diffFixture()
+	// Make a set of changes to the newer export
+	newer.Guides = newer.Guides[0:1]
+	guide := newer.Guides[0]
+	guide.Status = "Private"
+	guide.Pages[0].Modified = "2021-07-01 10:00:00"
+	box := guide.Pages[0].Boxes[0]
+	box.Assets[0].Url = "https://wos.example.edu"
+	box.Assets[1].Description = "<p>New notes</p>"
+	box.Assets[1].Position = 3
+	// Move the box to the Journals page and add a new page
+	guide.Pages[0].Boxes = nil
+	guide.Pages[1].Boxes = []*Box{box}
+	guide.Pages = append(guide.Pages, &Page{Id: 12, Name: "Patents", Position: 3})
+
+	changes := DiffLibGuides(older, newer)
+	found := map[string]*Change{}
+	for _, c := range changes {
+		found[strings.Join([]string{c.Action, c.Type, strInt(c.Id), c.Field}, " ")] = c
+	}
+	for _, expected := range []string{
+		"removed Guide 2 ",
+		"modified Guide 1 status",
+		"modified Page 10 updated",
+		"moved Box 100 page_id",
+		"modified Asset 1000 url",
+		"moved Asset 1001 position",
+		"modified Asset 1001 description",
+		"added Page 12 ",
+	} {
+		if _, ok := found[expected]; !ok {
+			t.Errorf("expected change %q", expected)
+		}
+	}
+	expectedInt(t, 8, len(changes))
+	if c, ok := found["moved Box 100 page_id"]; ok {
+		expectedString(t, "10", c.Old)
+		expectedString(t, "11", c.New)
+		expectedInt(t, 11, c.PageId)
+	}
+
+	// No changes between identical exports
+	expectedInt(t, 0, len(DiffLibGuides(diffFixture(), diffFixture())))
+
+	tbl := ChangesTable(changes, "Changes")
+	expectedInt(t, len(changes), len(tbl.Body.Rows))
+	src, err := ChangesHTML(changes, "Changes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"<h2>Engineering (1)</h2>", "<h2>Retired (2)</h2>", "<del>http://wos.example.edu</del>"} {
+		if !strings.Contains(string(src), expected) {
+			t.Errorf("expected changelog to contain %q", expected)
+		}
+	}
+}
+
+func TestDiffReport(t *testing.T) {
+	older, newer := diffFixture(), diffFixture()
+	// Hide the Journals page in both exports and rename it in the newer
+	older.Guides[0].Pages[1].Hidden = 1
+	newer.Guides[0].Pages[1].Hidden = 1
+	newer.Guides[0].Pages[1].Name = "Hidden journals"
+	newer.Guides[0].Status = "Private"
+	oldName, newName := filepath.Join("testout", "diff-old.xml"), filepath.Join("testout", "diff-new.xml")
+	for name, lg := range map[string]*LibGuides{oldName: older, newName: newer} {
+		src, err := lg.ToXML()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, src, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		opts     *Options
+		expected string
+	}{
+		{&Options{Format: "csv"}, "modified,Guide,1"},
+		{&Options{Format: "csv", Hidden: HiddenInclude}, "modified,Page,11,"},
+		{&Options{Format: ChangelogFormat}, "<h2>Engineering (1)</h2>"},
+		{&Options{Format: "html"}, "<table"},
+	} {
+		destName := filepath.Join("testout", "diff.out")
+		if err := DiffReport(oldName, newName, destName, test.opts); err != nil {
+			t.Fatal(err)
+		}
+		src, err := ioutil.ReadFile(destName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(src), test.expected) {
+			t.Errorf("expected %q with format %q, hidden %q, got\n%s", test.expected, test.opts.Format, test.opts.Hidden, src)
+		}
+		if test.opts.Hidden != HiddenInclude && strings.Contains(string(src), "Hidden journals") {
+			t.Errorf("expected the hidden page to be skipped, got\n%s", src)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// duplicates.go finds reused assets, copied pages and duplicated descriptions
+// in a LibGuides export.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// Kinds of duplicates reported by DuplicatesTable
+const (
+	DuplicateReusedAsset = "reused asset"
+	DuplicatePageCopy    = "page copy"
+	DuplicateText        = "duplicate text"
+	DuplicateSimilarText = "similar text"
+)
+
+const (
+	// DefaultSimilarity is the Jaccard similarity of the description
+	// shingles above which descriptions are near duplicates
+	DefaultSimilarity = 0.8
+	// MinFingerprintWords is the fewest words a description needs to be
+	// fingerprinted, shorter text is too common to be interesting
+	MinFingerprintWords = 8
+	// shingleSize is the number of words in a shingle
+	shingleSize = 3
+	// maxShingleDocs skips shingles shared by more descriptions than
+	// this, e.g. boilerplate, when looking for near duplicates
+	maxShingleDocs = 50
+)
+
+// textWords returns the lower case words of a text ignoring punctuation.
+func textWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// textFingerprint returns a short hash of normalized text.
+func textFingerprint(words []string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(words, " "))))[0:12]
+}
+
+// textShingles returns the set of hashed word shingles in a text.
+func textShingles(words []string) map[uint64]bool {
+	shingles := map[uint64]bool{}
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		shingles[h.Sum64()] = true
+	}
+	return shingles
+}
+
+// jaccard returns the Jaccard similarity of two sets of shingles.
+func jaccard(a, b map[uint64]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for k := range a {
+		if b[k] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// textDoc is a description to compare.
+type textDoc struct {
+	row         []string
+	fingerprint string
+	shingles    map[uint64]bool
+	words       int
+}
+
+// unionFind groups near duplicate descriptions.
+type unionFind []int
+
+func newUnionFind(n int) unionFind {
+	uf := make(unionFind, n)
+	for i := range uf {
+		uf[i] = i
+	}
+	return uf
+}
+
+func (uf unionFind) find(i int) int {
+	for uf[i] != i {
+		uf[i] = uf[uf[i]]
+		i = uf[i]
+	}
+	return i
+}
+
+func (uf unionFind) union(i, j int) {
+	i, j = uf.find(i), uf.find(j)
+	if i < j {
+		uf[j] = i
+	} else if j < i {
+		uf[i] = j
+	}
+}
+
+// DuplicatesTable looks for duplicated content in a LibGuides object.
+// It reports assets mapped into more than one box ("reused asset"),
+// grouped by asset id, pages copied from other pages ("page copy"),
+// grouped by the original page id so the copy lineage can be followed,
+// guide, page and asset descriptions with the same normalized text
+// ("duplicate text"), grouped by a fingerprint, and descriptions whose
+// word shingles are at least opts.Similarity alike ("similar text").
+// The columns are "Kind", "Group", "Object Type", "Id", "Name",
+// "Guide Id", "Page Id", "Box Id" and "Detail".
+func DuplicatesTable(lg *LibGuides, caption string, opts *Options) *Table {
+	hidden, similarity := HiddenSkip, DefaultSimilarity
+	if opts != nil {
+		hidden = opts.Hidden
+		if opts.Similarity > 0 {
+			similarity = opts.Similarity
+		}
+	}
+	tbl := new(Table)
+	tbl.SetCaption(caption)
+	tbl.AppendHeadings("Kind", "Group", "Object Type", "Id", "Name", "Guide Id", "Page Id", "Box Id", "Detail")
+
+	// Collect the placements of the assets and the pages
+	assetIds := []int{}
+	placements := map[int][][]string{}
+	pageIds := []int{}
+	pages := map[int][]string{}
+	sources := map[int]int{}
+	docs := []*textDoc{}
+	addDoc := func(description string, row ...string) {
+		words := textWords(descriptionText(description))
+		if len(words) < MinFingerprintWords {
+			return
+		}
+		docs = append(docs, &textDoc{
+			row:         row,
+			fingerprint: textFingerprint(words),
+			shingles:    textShingles(words),
+			words:       len(words),
+		})
+	}
+	for _, guide := range lg.Guides {
+		guideId := strInt(guide.Id)
+		addDoc(guide.Description, "Guide", guideId, guide.Name, guideId, "", "")
+		for _, page := range guide.Pages {
+			pageHidden := page.Hidden != 0
+			if hidden.Allows(pageHidden) {
+				pageIds = append(pageIds, page.Id)
+				pages[page.Id] = []string{"Page", strInt(page.Id), page.Name, guideId, strInt(page.Id), ""}
+				sources[page.Id] = page.SourcePageId
+				addDoc(page.Description, "Page", strInt(page.Id), page.Name, guideId, strInt(page.Id), "")
+			}
+			for _, box := range page.Boxes {
+				if !hidden.Allows(pageHidden || box.Hidden != 0) {
+					continue
+				}
+				assets := append([]*Asset{}, box.Assets...)
+				for _, pane := range box.Panes {
+					assets = append(assets, pane.Assets...)
+				}
+				for _, asset := range assets {
+					row := []string{"Asset", strInt(asset.Id), asset.Name, guideId, strInt(page.Id), strInt(box.Id)}
+					if _, seen := placements[asset.Id]; !seen {
+						assetIds = append(assetIds, asset.Id)
+						addDoc(asset.Description, row...)
+					}
+					placements[asset.Id] = append(placements[asset.Id], append(row, "map id "+asset.MapId))
+				}
+			}
+		}
+	}
+
+	// Assets mapped into more than one box
+	for _, id := range assetIds {
+		if len(placements[id]) > 1 {
+			for _, row := range placements[id] {
+				tbl.AppendRow(append([]string{DuplicateReusedAsset, strInt(id)}, row...)...)
+			}
+		}
+	}
+
+	// Copy lineage, follow the source page ids back to the original
+	original := func(id int) int {
+		seen := map[int]bool{}
+		for sources[id] != 0 && !seen[id] {
+			seen[id] = true
+			if _, ok := pages[sources[id]]; !ok {
+				return sources[id]
+			}
+			id = sources[id]
+		}
+		return id
+	}
+	lineage := map[int][]int{}
+	roots := []int{}
+	for _, id := range pageIds {
+		if sources[id] == 0 {
+			continue
+		}
+		root := original(id)
+		if _, ok := lineage[root]; !ok {
+			roots = append(roots, root)
+		}
+		lineage[root] = append(lineage[root], id)
+	}
+	for _, root := range roots {
+		group := strInt(root)
+		if row, ok := pages[root]; ok {
+			tbl.AppendRow(append(append([]string{DuplicatePageCopy, group}, row...), "original")...)
+		}
+		for _, id := range lineage[root] {
+			detail := fmt.Sprintf("copied from page %d", sources[id])
+			if _, ok := pages[sources[id]]; !ok {
+				detail += " (not in export)"
+			}
+			tbl.AppendRow(append(append([]string{DuplicatePageCopy, group}, pages[id]...), detail)...)
+		}
+	}
+
+	// Exact duplicates by fingerprint
+	fingerprints := []string{}
+	byFingerprint := map[string][]*textDoc{}
+	for _, doc := range docs {
+		if _, ok := byFingerprint[doc.fingerprint]; !ok {
+			fingerprints = append(fingerprints, doc.fingerprint)
+		}
+		byFingerprint[doc.fingerprint] = append(byFingerprint[doc.fingerprint], doc)
+	}
+	for _, fingerprint := range fingerprints {
+		if len(byFingerprint[fingerprint]) > 1 {
+			for _, doc := range byFingerprint[fingerprint] {
+				tbl.AppendRow(append(append([]string{DuplicateText, fingerprint}, doc.row...), fmt.Sprintf("%d words", doc.words))...)
+			}
+		}
+	}
+
+	// Near duplicates, candidates share a shingle, exact duplicates
+	// are left out as they are already reported
+	index := map[uint64][]int{}
+	for i, doc := range docs {
+		for shingle := range doc.shingles {
+			index[shingle] = append(index[shingle], i)
+		}
+	}
+	candidates := map[[2]int]bool{}
+	for _, postings := range index {
+		if len(postings) > maxShingleDocs {
+			continue
+		}
+		for i := 0; i < len(postings); i++ {
+			for j := i + 1; j < len(postings); j++ {
+				candidates[[2]int{postings[i], postings[j]}] = true
+			}
+		}
+	}
+	uf := newUnionFind(len(docs))
+	best := make([]float64, len(docs))
+	for pair := range candidates {
+		a, b := docs[pair[0]], docs[pair[1]]
+		if a.fingerprint == b.fingerprint {
+			continue
+		}
+		if score := jaccard(a.shingles, b.shingles); score >= similarity {
+			uf.union(pair[0], pair[1])
+			if score > best[pair[0]] {
+				best[pair[0]] = score
+			}
+			if score > best[pair[1]] {
+				best[pair[1]] = score
+			}
+		}
+	}
+	clusters := map[int][]int{}
+	clusterIds := []int{}
+	for i := range docs {
+		if best[i] == 0 {
+			continue
+		}
+		root := uf.find(i)
+		if _, ok := clusters[root]; !ok {
+			clusterIds = append(clusterIds, root)
+		}
+		clusters[root] = append(clusters[root], i)
+	}
+	for _, root := range clusterIds {
+		group := docs[root].fingerprint
+		for _, i := range clusters[root] {
+			tbl.AppendRow(append(append([]string{DuplicateSimilarText, group}, docs[i].row...), fmt.Sprintf("similarity %.2f", best[i]))...)
+		}
+	}
+	return tbl
+}
+
+// DuplicatesReport reads a LibGuides export and writes the duplicated
+// content found to destName.
+func DuplicatesReport(srcName string, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	lg, err := opts.ReadLibGuides(srcName)
+	if err != nil {
+		return err
+	}
+	tbl := DuplicatesTable(lg, fmt.Sprintf("Duplicated content in %q", srcName), opts)
+	opts.Logf("found %d duplicate rows", len(tbl.Body.Rows))
+	return opts.WriteTable(tbl, destName)
+}
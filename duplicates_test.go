@@ -0,0 +1,140 @@
+// duplicates_test.go tests finding reused and duplicated content.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"html"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestTextShingles(t *testing.T) {
+	words := textWords("The quick, brown fox jumps over the lazy dog.")
+	expectedString(t, "the quick brown fox jumps over the lazy dog", strings.Join(words, " "))
+	a := textShingles(words)
+	expectedInt(t, 7, len(a))
+	b := textShingles(textWords("The quick brown fox jumps over the lazy cat"))
+	if score := jaccard(a, b); score < 0.7 || score > 0.8 {
+		t.Errorf("expected a similarity of 0.75, got %g", score)
+	}
+	expectedString(t, textFingerprint(words), textFingerprint(textWords("<THE> quick brown fox jumps over the lazy dog!")))
+}
+
+func TestDuplicatesTable(t *testing.T) {
+	notes := "<p>Use the library catalog to find books, journals and theses held by the library.</p>"
+	similar := "<p>Use the library catalog to find books, journals and theses held by the library today.</p>"
+	shared := &Asset{Id: 1000, Name: "Catalog", MapId: "5000"}
+	lg := &LibGuides{
+		Guides: []*Guide{
+			{Id: 1, Name: "Engineering", Pages: []*Page{
+				{Id: 10, Name: "Home", Boxes: []*Box{
+					{Id: 100, Assets: []*Asset{shared, {Id: 1001, Name: "Notes", Description: notes}}},
+				}},
+				{Id: 11, Name: "Home copy", SourcePageId: 10, Boxes: []*Box{
+					{Id: 101, Assets: []*Asset{{Id: 1000, Name: "Catalog", MapId: "5001"}}},
+				}},
+			}},
+			{Id: 2, Name: "Chemistry", Pages: []*Page{
+				{Id: 20, Name: "Copy of a copy", SourcePageId: 11, Boxes: []*Box{
+					{Id: 200, Assets: []*Asset{{Id: 2001, Name: "More notes", Description: html.EscapeString(notes)}}},
+				}},
+				{Id: 21, Name: "From elsewhere", SourcePageId: 99, Boxes: []*Box{
+					{Id: 201, Assets: []*Asset{{Id: 2002, Name: "Other notes", Description: similar}}},
+				}},
+				{Id: 22, Name: "Short", Description: notes, Boxes: []*Box{
+					{Id: 202, Assets: []*Asset{{Id: 2003, Name: "Short", Description: "<p>Hi</p>"}, {Id: 2004, Name: "Short", Description: "<p>Hi</p>"}}},
+				}},
+			}},
+		},
+	}
+	tbl := DuplicatesTable(lg, "duplicates", nil)
+	found := map[string][]string{}
+	for _, row := range tbl.Body.Rows {
+		key := row[0] + ":" + row[2] + ":" + row[3]
+		found[key] = row
+	}
+	reused := 0
+	for _, row := range tbl.Body.Rows {
+		if row[0] == DuplicateReusedAsset {
+			expectedString(t, "1000", row[1])
+			reused++
+		}
+	}
+	expectedInt(t, 2, reused)
+	for key, expected := range map[string]string{
+		"page copy:Page:10": "10,original",
+		"page copy:Page:11": "10,copied from page 10",
+		"page copy:Page:20": "10,copied from page 11",
+		"page copy:Page:21": "99,copied from page 99 (not in export)",
+	} {
+		if row, ok := found[key]; !ok {
+			t.Errorf("expected a %q row", key)
+		} else {
+			expectedString(t, expected, row[1]+","+row[8])
+		}
+	}
+	dup1, dup2 := found["duplicate text:Asset:1001"], found["duplicate text:Asset:2001"]
+	if dup1 == nil || dup2 == nil {
+		t.Fatalf("expected assets 1001 and 2001 to be duplicate text")
+	}
+	expectedString(t, dup1[1], dup2[1])
+	if dup3 := found["duplicate text:Page:22"]; dup3 == nil {
+		t.Errorf("expected page 22 to be duplicate text")
+	} else {
+		expectedString(t, dup1[1], dup3[1])
+	}
+	if found["duplicate text:Asset:2003"] != nil {
+		t.Errorf("expected short descriptions to be ignored")
+	}
+	if row := found["similar text:Asset:2002"]; row == nil {
+		t.Errorf("expected asset 2002 to be similar text")
+	} else {
+		expectedString(t, "similarity 0.92", row[8])
+	}
+
+	tbl = DuplicatesTable(lg, "duplicates", &Options{Similarity: 0.95})
+	for _, row := range tbl.Body.Rows {
+		if row[0] == DuplicateSimilarText {
+			t.Errorf("expected no similar text at 0.95, got %s", joinRow(row))
+		}
+	}
+}
+
+func TestDuplicatesReport(t *testing.T) {
+	srcName := path.Join("testinput", "LibGuides_export_XXXXX.xml")
+	if err := DuplicatesReport(srcName, path.Join("testout", "duplicates.csv"), nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -34,6 +34,7 @@
 package springytools
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -60,3 +61,7 @@ func expectedString(t *testing.T, expected string, got string) {
 		t.Errorf("expected %q, got %q", expected, got)
 	}
 }
+
+func joinRow(row []string) string {
+	return strings.Join(row, ",")
+}
@@ -35,8 +35,11 @@ package springytools
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"regexp"
+	"strings"
 )
 
 var (
@@ -64,3 +67,98 @@ func ExtractHTTPLinks(src string) ([]string, int) {
 	}
 	return urlList, cnt
 }
+
+// Link categories reported by ClassifyLink. Links other than LinkPublic
+// can't be followed by patrons.
+const (
+	LinkPublic    = "public"
+	LinkLocalFile = "local file"
+	LinkUNCPath   = "UNC path"
+	LinkLocalhost = "localhost"
+	LinkPrivateIP = "private IP"
+	LinkIntranet  = "intranet"
+)
+
+// LinkCategories lists the link categories in report order
+var LinkCategories = []string{LinkPublic, LinkLocalFile, LinkUNCPath, LinkLocalhost, LinkPrivateIP, LinkIntranet}
+
+var (
+	// reFileLink matches file: URLs and UNC paths (\\server\share)
+	reFileLink = regexp.MustCompile(`(?i)\bfile:[^\s"'<>]+|\\\\[\w.$-]+\\[^\s"'<>]*`)
+
+	// privateNets are the private, link local and unique local networks
+	privateNets = []*net.IPNet{}
+
+	// intranetSuffixes are top level domains used inside organizations
+	intranetSuffixes = []string{".local", ".localdomain", ".internal", ".intranet", ".lan", ".corp", ".home.arpa"}
+)
+
+func init() {
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "169.254.0.0/16", "100.64.0.0/10", "fc00::/7", "fe80::/10"} {
+		_, ipNet, _ := net.ParseCIDR(cidr)
+		privateNets = append(privateNets, ipNet)
+	}
+}
+
+// ExtractFileLinks scans a string for file: URLs and UNC paths (e.g.
+// \\server\share\notes.docx) returning the list found and count.
+func ExtractFileLinks(src string) ([]string, int) {
+	linkList := reFileLink.FindAllString(src, -1)
+	return linkList, len(linkList)
+}
+
+// extractLinks returns the http(s), file: and UNC links in a string.
+func extractLinks(src string) ([]string, int) {
+	urlList, _ := ExtractHTTPLinks(src)
+	fileList, _ := ExtractFileLinks(src)
+	urlList = append(urlList, fileList...)
+	return urlList, len(urlList)
+}
+
+// ClassifyLink reports if a link is public or why patrons can't follow
+// it: a local file (file:), a UNC path, localhost, a private IP address
+// or an intranet host. Intranet hosts are single label host names (e.g.
+// http://intranet/), hosts in organization only domains like .local or
+// .corp and hosts matching one of the intranet patterns.
+func ClassifyLink(link string, intranet []*regexp.Regexp) string {
+	link = strings.TrimSpace(link)
+	switch {
+	case strings.HasPrefix(strings.ToLower(link), "file:"):
+		return LinkLocalFile
+	case strings.HasPrefix(link, `\\`):
+		return LinkUNCPath
+	}
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return LinkPublic
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return LinkLocalhost
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsLoopback() || ip.IsUnspecified() {
+			return LinkLocalhost
+		}
+		for _, ipNet := range privateNets {
+			if ipNet.Contains(ip) {
+				return LinkPrivateIP
+			}
+		}
+		return LinkPublic
+	}
+	for _, re := range intranet {
+		if re.MatchString(host) {
+			return LinkIntranet
+		}
+	}
+	if !strings.Contains(host, ".") {
+		return LinkIntranet
+	}
+	for _, suffix := range intranetSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return LinkIntranet
+		}
+	}
+	return LinkPublic
+}
@@ -34,6 +34,7 @@
 package springytools
 
 import (
+	"regexp"
 	"testing"
 )
 
@@ -71,3 +72,63 @@ And here's  EZ Proxy style URL:
 		t.Errorf("urlList was nil, expected two urls")
 	}
 }
+
+func TestExtractFileLinks(t *testing.T) {
+	src := `<link href="file:///C:DOCUME~1Tempclip_filelist.xml" rel="File-List" />
+<a href='FILE://fileserver/share/syllabus.pdf'>Syllabus</a> and \\fileserver\dept$\notes.docx
+<a href="https://library.example.edu">Library</a>`
+	linkList, cnt := ExtractFileLinks(src)
+	expectedInt(t, 3, cnt)
+	if cnt == 3 {
+		expectedString(t, "file:///C:DOCUME~1Tempclip_filelist.xml", linkList[0])
+		expectedString(t, "FILE://fileserver/share/syllabus.pdf", linkList[1])
+		expectedString(t, `\\fileserver\dept$\notes.docx`, linkList[2])
+	}
+	_, cnt = extractLinks(src)
+	expectedInt(t, 4, cnt)
+}
+
+func TestClassifyLink(t *testing.T) {
+	intranet := []*regexp.Regexp{regexp.MustCompile(`(?i)\.ad\.example\.edu$`)}
+	for _, test := range []struct {
+		link, expected string
+	}{
+		{"https://library.example.edu/guides", LinkPublic},
+		{"http://8.8.8.8/", LinkPublic},
+		{"mailto:help@example.edu", LinkPublic},
+		{"file:///C:/Users/me/notes.docx", LinkLocalFile},
+		{`\\fileserver\share\notes.docx`, LinkUNCPath},
+		{"http://localhost:8080/admin", LinkLocalhost},
+		{"http://127.0.0.1/", LinkLocalhost},
+		{"http://[::1]/", LinkLocalhost},
+		{"http://10.1.2.3/wiki", LinkPrivateIP},
+		{"https://172.20.0.5/", LinkPrivateIP},
+		{"http://192.168.1.10/printer", LinkPrivateIP},
+		{"http://[fd00::1]/", LinkPrivateIP},
+		{"http://intranet/policies", LinkIntranet},
+		{"https://wiki.corp/", LinkIntranet},
+		{"https://files.ad.example.edu/share", LinkIntranet},
+	} {
+		expectedString(t, test.expected, ClassifyLink(test.link, intranet))
+	}
+	expectedString(t, LinkPublic, ClassifyLink("https://files.ad.example.edu/share", nil))
+}
+
+func TestLinkSummaryTable(t *testing.T) {
+	lg := &LibGuides{
+		Guides: []*Guide{{Id: 1, Pages: []*Page{{Id: 2, Boxes: []*Box{{Assets: []*Asset{
+			{Id: 3, Url: "https://library.example.edu"},
+			{Id: 4, Url: "http://intranet/policies", Description: `<a href="file:///C:/notes.docx">Notes</a>`},
+			{Id: 5, Url: "https://staff.example.edu/wiki"},
+		}}}}}}},
+	}
+	opts := &Options{Intranet: []string{`^staff\.example\.edu$`}}
+	links := LinkReportTable(lg, "links", opts)
+	expectedInt(t, 4, len(links.Body.Rows))
+	expectedString(t, "file:///C:/notes.docx,local file", links.Body.Rows[2][0]+","+links.Body.Rows[2][8])
+	tbl := LinkSummaryTable(links, "summary")
+	expectedInt(t, len(LinkCategories)+1, len(tbl.Body.Rows))
+	expectedString(t, "public,1,0", joinRow(tbl.Body.Rows[0]))
+	expectedString(t, "intranet,2,2", joinRow(tbl.Body.Rows[5]))
+	expectedString(t, "Total,4,3", joinRow(tbl.Body.Rows[6]))
+}
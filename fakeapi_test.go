@@ -0,0 +1,324 @@
+// fakeapi_test.go provides a fake LibGuides v1.2 API serving recorded
+// responses for testing without network access.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FakeAPIEndpoints are the list endpoints of the fake API, each served
+// from the JSON array in the file of the same name, e.g. guides.json.
+var FakeAPIEndpoints = []string{"accounts", "groups", "subjects", "tags", "vendors", "guides", "assets"}
+
+// FakeAPIObjects are the endpoints of the fake API returning an object,
+// each served from the JSON file of the same name, e.g. site.json.
+var FakeAPIObjects = []string{"site"}
+
+// FakeAPIAssetFields are the fields of an asset that can be changed
+// with a PUT to /assets/{id}.
+var FakeAPIAssetFields = []string{"name", "url", "description"}
+
+// FakeAPI is an http.Handler answering like the LibGuides v1.2 API
+// from recorded responses, for use with httptest.NewServer. The base
+// path "/1.2" is optional. Tokens are issued to ClientId and
+// ClientSecret and required by the other endpoints. A PUT to
+// /assets/{id} changes the asset in the responses, both in the assets
+// and in the guides' boxes.
+type FakeAPI struct {
+	ClientId     string
+	ClientSecret string
+
+	mu sync.Mutex
+	// Throttle is the number of following requests answered with 429
+	// Too Many Requests (and Retry-After: 0)
+	Throttle int
+	// Failures is the number of following requests answered with 503
+	// Service Unavailable
+	Failures int
+	// IgnorePaging answers lists in full whatever page and per_page ask
+	IgnorePaging bool
+	// Requests lists the method and path of each request received
+	Requests []string
+
+	fixtures map[string][]json.RawMessage
+	objects  map[string]json.RawMessage
+	tokens   map[string]bool
+}
+
+// NewFakeAPI returns a FakeAPI serving the fixtures in dir (see
+// FakeAPIEndpoints), a missing file is an empty list.
+func NewFakeAPI(dir string, clientId, clientSecret string) (*FakeAPI, error) {
+	f := &FakeAPI{
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		fixtures:     map[string][]json.RawMessage{},
+		objects:      map[string]json.RawMessage{},
+		tokens:       map[string]bool{},
+	}
+	for _, name := range FakeAPIEndpoints {
+		items := []json.RawMessage{}
+		src, err := ioutil.ReadFile(filepath.Join(dir, name+".json"))
+		if err == nil {
+			err = json.Unmarshal(src, &items)
+		} else if os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		f.fixtures[name] = items
+	}
+	for _, name := range FakeAPIObjects {
+		src, err := ioutil.ReadFile(filepath.Join(dir, name+".json"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err == nil && !json.Valid(src) {
+			err = fmt.Errorf("invalid JSON")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		f.objects[name] = src
+	}
+	return f, nil
+}
+
+// RevokeTokens invalidates the tokens issued so far.
+func (f *FakeAPI) RevokeTokens() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens = map[string]bool{}
+}
+
+// RequestCount returns the number of requests to a path, e.g.
+// "GET /guides".
+func (f *FakeAPI) RequestCount(request string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cnt := 0
+	for _, r := range f.Requests {
+		if r == request {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+func fakeAPIWrite(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (f *FakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/1.2")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Requests = append(f.Requests, r.Method+" "+path)
+	switch {
+	case f.Throttle > 0:
+		f.Throttle--
+		w.Header().Set("Retry-After", "0")
+		fakeAPIWrite(w, http.StatusTooManyRequests, map[string]string{"error": "rate limit exceeded"})
+		return
+	case f.Failures > 0:
+		f.Failures--
+		fakeAPIWrite(w, http.StatusServiceUnavailable, map[string]string{"error": "service unavailable"})
+		return
+	}
+	if path == "/oauth/token" {
+		if r.Method != http.MethodPost {
+			fakeAPIWrite(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		r.ParseForm()
+		if r.PostForm.Get("grant_type") != "client_credentials" ||
+			r.PostForm.Get("client_id") != f.ClientId || r.PostForm.Get("client_secret") != f.ClientSecret {
+			fakeAPIWrite(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client", "error_description": "The client credentials are invalid"})
+			return
+		}
+		token := fmt.Sprintf("fake-token-%d", len(f.Requests))
+		f.tokens[token] = true
+		fakeAPIWrite(w, http.StatusOK, &apiToken{AccessToken: token, ExpiresIn: 3600, TokenType: "Bearer"})
+		return
+	}
+	if !f.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")] {
+		fakeAPIWrite(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token", "error_description": "The access token provided is invalid"})
+		return
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if r.Method == http.MethodPut && len(parts) == 2 && parts[0] == "assets" {
+		f.updateAsset(w, r, parts[1])
+		return
+	}
+	if r.Method != http.MethodGet {
+		fakeAPIWrite(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if obj, ok := f.objects[parts[0]]; ok && len(parts) == 1 {
+		fakeAPIWrite(w, http.StatusOK, obj)
+		return
+	}
+	items, ok := f.fixtures[parts[0]]
+	if !ok || len(parts) > 2 {
+		fakeAPIWrite(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	if len(parts) == 2 {
+		// A comma separated list of ids, e.g. /guides/1,2
+		ids := map[int]bool{}
+		for _, s := range strings.Split(parts[1], ",") {
+			id, err := strconv.Atoi(s)
+			if err != nil {
+				fakeAPIWrite(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid id %q", s)})
+				return
+			}
+			ids[id] = true
+		}
+		found := []json.RawMessage{}
+		for _, item := range items {
+			obj := struct {
+				Id int `json:"id"`
+			}{}
+			if json.Unmarshal(item, &obj) == nil && ids[obj.Id] {
+				found = append(found, item)
+			}
+		}
+		fakeAPIWrite(w, http.StatusOK, found)
+		return
+	}
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	perPage, _ := strconv.Atoi(q.Get("per_page"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || f.IgnorePaging {
+		page, perPage = 1, len(items)
+	}
+	start, end := (page-1)*perPage, page*perPage
+	if start > len(items) {
+		start = len(items)
+	}
+	if end > len(items) {
+		end = len(items)
+	}
+	fakeAPIWrite(w, http.StatusOK, items[start:end])
+}
+
+// updateAsset changes the fields of an asset given in the JSON body of
+// the request.
+func (f *FakeAPI) updateAsset(w http.ResponseWriter, r *http.Request, s string) {
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		fakeAPIWrite(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid id %q", s)})
+		return
+	}
+	fields := map[string]string{}
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		fakeAPIWrite(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	for name := range fields {
+		known := false
+		for _, field := range FakeAPIAssetFields {
+			known = known || name == field
+		}
+		if !known {
+			fakeAPIWrite(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown field %q", name)})
+			return
+		}
+	}
+	var updated interface{}
+	for _, name := range []string{"assets", "guides"} {
+		for i, item := range f.fixtures[name] {
+			var v interface{}
+			if err := json.Unmarshal(item, &v); err != nil {
+				continue
+			}
+			found := fakeAPISetAsset(v, id, fields, name == "assets")
+			if found == nil {
+				continue
+			}
+			if updated == nil {
+				updated = found
+			}
+			if src, err := json.Marshal(v); err == nil {
+				f.fixtures[name][i] = src
+			}
+		}
+	}
+	if updated == nil {
+		fakeAPIWrite(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	fakeAPIWrite(w, http.StatusOK, updated)
+}
+
+// fakeAPISetAsset sets the fields of the asset id in v, a decoded
+// asset (when isAsset is true) or guide. Returns the asset found or nil.
+func fakeAPISetAsset(v interface{}, id int, fields map[string]string, isAsset bool) interface{} {
+	var found interface{}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if n, ok := v["id"].(float64); isAsset && ok && int(n) == id {
+			for name, val := range fields {
+				v[name] = val
+			}
+			return v
+		}
+		for key, child := range v {
+			if a := fakeAPISetAsset(child, id, fields, key == "assets"); a != nil && found == nil {
+				found = a
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if a := fakeAPISetAsset(child, id, fields, isAsset); a != nil && found == nil {
+				found = a
+			}
+		}
+	}
+	return found
+}
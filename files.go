@@ -0,0 +1,359 @@
+// files.go provides reading LibGuides exports and writing reports to files,
+// standard input and standard output.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// StdIO is the name used for standard input (source) and standard
+// output (destination) by the reports and conversions.
+const StdIO = "-"
+
+var (
+	// reportFormats maps variations for the three supported formats
+	// of CSV, JSON and XML (as HTML)
+	reportFormats = map[string]string{
+		"CSV":   "csv",
+		"csv":   "csv",
+		"JSON":  "json",
+		"json":  "json",
+		"HTML":  "xml",
+		"html":  "xml",
+		"XML":   "xml",
+		"xml":   "xml",
+		".csv":  "csv",
+		".json": "json",
+		".html": "xml",
+		".xml":  "xml",
+	}
+)
+
+// formatName returns a format name in lower case without a leading
+// dot. If format is an empty string it is destName's extension,
+// ignoring a trailing .gz.
+func formatName(format string, destName string) string {
+	if format == "" {
+		format = path.Ext(strings.TrimSuffix(strings.ToLower(destName), ".gz"))
+	}
+	return strings.TrimPrefix(strings.ToLower(format), ".")
+}
+
+// ReportFormat normalizes a report format name to one of csv, json
+// or xml. If format is an empty string the format is guessed from
+// the extension of destName (ignoring a trailing .gz), falling back
+// to csv. Returns the format and an error if the format is not supported.
+func ReportFormat(format string, destName string) (string, error) {
+	if format == "" {
+		if val, ok := reportFormats[formatName("", destName)]; ok {
+			return val, nil
+		}
+		return "csv", nil
+	}
+	if val, ok := reportFormats[format]; ok {
+		return val, nil
+	}
+	return "", fmt.Errorf("%q is not a supported format", format)
+}
+
+// ReadSource reads the contents of srcName. A srcName of "-" reads
+// from standard input. Gzip and zip compressed sources are decompressed,
+// for a zip archive the first XML file found is read.
+func ReadSource(srcName string) ([]byte, error) {
+	var (
+		src []byte
+		err error
+	)
+	if srcName == StdIO {
+		src, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		src, err = ioutil.ReadFile(srcName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decompress(src)
+}
+
+// decompress checks the leading "magic" bytes for gzip or zip content
+// and decompresses it, other content is returned as is.
+func decompress(src []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(src, []byte{0x1f, 0x8b}):
+		r, err := gzip.NewReader(bytes.NewReader(src))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	case bytes.HasPrefix(src, []byte("PK\x03\x04")):
+		r, err := zip.NewReader(bytes.NewReader(src), int64(len(src)))
+		if err != nil {
+			return nil, err
+		}
+		var entry *zip.File
+		for _, f := range r.File {
+			if strings.HasSuffix(strings.ToLower(f.Name), ".xml") {
+				entry = f
+				break
+			}
+		}
+		if entry == nil {
+			if len(r.File) != 1 {
+				return nil, fmt.Errorf("could not find an XML file in zip archive")
+			}
+			entry = r.File[0]
+		}
+		fp, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer fp.Close()
+		return ioutil.ReadAll(fp)
+	}
+	return src, nil
+}
+
+// ReadLibGuides reads a LibGuides XML export (see ReadSource) and
+// returns a populated LibGuides object and error.
+func ReadLibGuides(srcName string) (*LibGuides, error) {
+	lg := &LibGuides{
+		XMLName:  xml.Name{},
+		Customer: &Customer{},
+		Site:     &Site{},
+		Accounts: []*Account{},
+		Groups:   []*Group{},
+		Subjects: []*Subject{},
+		Tags:     []*Tag{},
+		Vendors:  []*Vendor{},
+		Guides:   []*Guide{},
+	}
+	src, err := ReadSource(srcName)
+	if err != nil {
+		return nil, err
+	}
+	if err = lg.FromXML(src); err != nil {
+		return nil, err
+	}
+	return lg, nil
+}
+
+// WriteOptions controls how files are written by WriteDestination
+// and CreateAtomicFile.
+type WriteOptions struct {
+	// Perm is the file mode of the written file, zero means 0644
+	Perm os.FileMode `json:"perm,omitempty"`
+	// NoClobber returns an error rather than replace an existing file
+	NoClobber bool `json:"no_clobber,omitempty"`
+	// Backup renames an existing file by appending BackupSuffix
+	// before it is replaced
+	Backup bool `json:"backup,omitempty"`
+	// BackupSuffix is appended to the backup file name, empty means "~"
+	BackupSuffix string `json:"backup_suffix,omitempty"`
+}
+
+// DefaultWriteOptions are always used by WriteDestination and the
+// table file writers, so they never refuse to replace or back up a
+// file. The commands pass their -no-clobber and -backup settings to
+// WriteDestinationWithOptions instead.
+var DefaultWriteOptions = &WriteOptions{
+	Perm:         0644,
+	BackupSuffix: "~",
+}
+
+// AtomicFile writes to a temporary file in the same directory as the
+// destination. The destination is only replaced when Close succeeds so
+// a failed write never leaves a partial file behind.
+type AtomicFile struct {
+	*os.File
+	destName string
+	opts     *WriteOptions
+	done     bool
+}
+
+// CreateAtomicFile creates a temporary file to be renamed to destName
+// when closed. If opts is nil DefaultWriteOptions are used. Returns an
+// error if the temporary file can't be created or NoClobber is set
+// and destName exists.
+func CreateAtomicFile(destName string, opts *WriteOptions) (*AtomicFile, error) {
+	if opts == nil {
+		opts = DefaultWriteOptions
+	}
+	if opts.NoClobber {
+		if _, err := os.Lstat(destName); err == nil {
+			return nil, fmt.Errorf("%q already exists", destName)
+		}
+	}
+	dir, base := filepath.Split(destName)
+	if dir == "" {
+		dir = "."
+	}
+	fp, err := ioutil.TempFile(dir, "."+base+".tmp*")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{File: fp, destName: destName, opts: opts}, nil
+}
+
+// Abort closes and removes the temporary file leaving any existing
+// destination file untouched. It is safe to call after Close.
+func (f *AtomicFile) Abort() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	f.File.Close()
+	return os.Remove(f.File.Name())
+}
+
+// backupFile makes backupName a copy of name, a hard link where the
+// file system allows so name is never moved away.
+func backupFile(name string, backupName string) error {
+	if err := os.Remove(backupName); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Link(name, backupName); err == nil {
+		return nil
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(backupName, src, info.Mode().Perm())
+}
+
+// Close flushes the temporary file to disk, sets its permissions,
+// makes a backup of an existing destination if requested and renames
+// the temporary file to the destination. The backup is a link (or a
+// copy) so the rename is the only step replacing the destination. On
+// error the temporary file is removed and the destination is left
+// untouched.
+func (f *AtomicFile) Close() error {
+	if f.done {
+		return fmt.Errorf("%q already closed", f.destName)
+	}
+	tmpName := f.File.Name()
+	fail := func(err error) error {
+		f.done = true
+		f.File.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	perm := f.opts.Perm
+	if perm == 0 {
+		perm = 0644
+	}
+	if err := f.File.Chmod(perm); err != nil {
+		return fail(err)
+	}
+	if err := f.File.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := f.File.Close(); err != nil {
+		return fail(err)
+	}
+	f.done = true
+	if _, err := os.Lstat(f.destName); err == nil {
+		if f.opts.NoClobber {
+			os.Remove(tmpName)
+			return fmt.Errorf("%q already exists", f.destName)
+		}
+		if f.opts.Backup {
+			suffix := f.opts.BackupSuffix
+			if suffix == "" {
+				suffix = "~"
+			}
+			if err := backupFile(f.destName, f.destName+suffix); err != nil {
+				os.Remove(tmpName)
+				return err
+			}
+		}
+	}
+	if err := os.Rename(tmpName, f.destName); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+// WriteDestination writes src to destName using DefaultWriteOptions,
+// see WriteDestinationWithOptions.
+func WriteDestination(destName string, src []byte) error {
+	return WriteDestinationWithOptions(destName, src, DefaultWriteOptions)
+}
+
+// WriteDestinationWithOptions writes src to destName. A destName of "-"
+// writes to standard output. If destName ends in ".gz" the output is
+// gzip compressed. Files are written to a temporary file then renamed
+// into place (see AtomicFile). Returns an error if any encountered.
+func WriteDestinationWithOptions(destName string, src []byte, opts *WriteOptions) error {
+	if strings.HasSuffix(strings.ToLower(destName), ".gz") {
+		buf := new(bytes.Buffer)
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(src); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		src = buf.Bytes()
+	}
+	if destName == StdIO {
+		_, err := os.Stdout.Write(src)
+		return err
+	}
+	fp, err := CreateAtomicFile(destName, opts)
+	if err != nil {
+		return err
+	}
+	if _, err := fp.Write(src); err != nil {
+		fp.Abort()
+		return err
+	}
+	return fp.Close()
+}
@@ -0,0 +1,199 @@
+// files_test.go provides tests for files.go
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestReportFormat(t *testing.T) {
+	for _, test := range []struct {
+		format, destName, expected string
+	}{
+		{"", "links.csv", "csv"},
+		{"", "links.JSON", "json"},
+		{"", "links.html", "xml"},
+		{"", "links.json.gz", "json"},
+		{"", "-", "csv"},
+		{"", "links", "csv"},
+		{"JSON", "links.csv", "json"},
+		{"html", "-", "xml"},
+	} {
+		got, err := ReportFormat(test.format, test.destName)
+		if err != nil {
+			t.Errorf("ReportFormat(%q, %q): %s", test.format, test.destName, err)
+		}
+		expectedString(t, test.expected, got)
+	}
+	if _, err := ReportFormat("yaml", "links.yaml"); err == nil {
+		t.Errorf("expected an error for an unsupported format")
+	}
+	expectedString(t, "diff", formatName("", "Changes.DIFF.gz"))
+	expectedString(t, "nt", formatName(".NT", "graph.ttl"))
+}
+
+func TestReadSourceCompressed(t *testing.T) {
+	srcName := "testinput/LibGuides_export_XXXXX.xml"
+	expected, err := ioutil.ReadFile(srcName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// gzip compressed export
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	gz.Write(expected)
+	gz.Close()
+	gzName := "testout/LibGuides_export.xml.gz"
+	if err := ioutil.WriteFile(gzName, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSource(gzName)
+	if err != nil {
+		t.Fatalf("ReadSource(%q): %s", gzName, err)
+	}
+	expectedBytes(t, expected, got)
+
+	// zip compressed export
+	buf = new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	w, _ := zw.Create("README.txt")
+	w.Write([]byte("Not the export"))
+	w, _ = zw.Create("LibGuides_export_XXXXX.xml")
+	w.Write(expected)
+	zw.Close()
+	zipName := "testout/LibGuides_export.zip"
+	if err := ioutil.WriteFile(zipName, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lg, err := ReadLibGuides(zipName)
+	if err != nil {
+		t.Fatalf("ReadLibGuides(%q): %s", zipName, err)
+	}
+	expectedString(t, "libguides.example.edu", lg.Site.Domain)
+	expectedInt(t, 1, len(lg.Guides))
+}
+
+func TestWriteDestination(t *testing.T) {
+	expected := []byte("URL,Owner\n")
+	destName := "testout/write-destination.csv.gz"
+	if err := WriteDestination(destName, expected); err != nil {
+		t.Fatal(err)
+	}
+	fp, err := os.Open(destName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+	r, err := gzip.NewReader(fp)
+	if err != nil {
+		t.Fatalf("expected gzip output in %q: %s", destName, err)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedBytes(t, expected, got)
+}
+
+func TestAtomicWrites(t *testing.T) {
+	destName := "testout/atomic.txt"
+	os.Remove(destName)
+	os.Remove(destName + "~")
+	opts := &WriteOptions{}
+	if err := WriteDestinationWithOptions(destName, []byte("one"), opts); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(destName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("expected mode 0644, got %o", info.Mode().Perm())
+	}
+
+	// An aborted write leaves the existing file untouched
+	fp, err := CreateAtomicFile(destName, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp.Write([]byte("partial"))
+	if err := fp.Abort(); err != nil {
+		t.Errorf("Abort: %s", err)
+	}
+	if _, err := os.Stat(fp.Name()); !os.IsNotExist(err) {
+		t.Errorf("expected temporary file %q to be removed", fp.Name())
+	}
+	src, _ := ioutil.ReadFile(destName)
+	expectedString(t, "one", string(src))
+
+	// No clobber refuses to replace the file
+	opts.NoClobber = true
+	if err := WriteDestinationWithOptions(destName, []byte("two"), opts); err == nil {
+		t.Errorf("expected an error replacing %q with no clobber", destName)
+	}
+	src, _ = ioutil.ReadFile(destName)
+	expectedString(t, "one", string(src))
+
+	// Backup keeps the previous version
+	opts.NoClobber, opts.Backup, opts.Perm = false, true, 0600
+	if err := WriteDestinationWithOptions(destName, []byte("three"), opts); err != nil {
+		t.Fatal(err)
+	}
+	src, _ = ioutil.ReadFile(destName)
+	expectedString(t, "three", string(src))
+	src, _ = ioutil.ReadFile(destName + "~")
+	expectedString(t, "one", string(src))
+	if info, err = os.Stat(destName); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %o", info.Mode().Perm())
+	}
+	// A later backup replaces the earlier one
+	if err := WriteDestinationWithOptions(destName, []byte("four"), opts); err != nil {
+		t.Fatal(err)
+	}
+	src, _ = ioutil.ReadFile(destName)
+	expectedString(t, "four", string(src))
+	src, _ = ioutil.ReadFile(destName + "~")
+	expectedString(t, "three", string(src))
+
+	// Writing into a missing directory is an error
+	if err := WriteDestinationWithOptions("testout/no-such-dir/atomic.txt", []byte("five"), nil); err == nil {
+		t.Errorf("expected an error writing to a missing directory")
+	}
+}
@@ -1,3 +1,9 @@
 module github.com/caltechlibrary/springytools
 
 go 1.16
+
+require (
+	github.com/mattn/go-sqlite3 v1.14.17 // indirect
+	golang.org/x/net v0.11.0
+	modernc.org/sqlite v1.23.1
+)
@@ -0,0 +1,334 @@
+// inventory.go provides a content inventory of a LibGuides export counting guides,
+// pages, boxes and assets by status, type, group, owner, subject and tag.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+)
+
+// Count is a name and the number of times it was counted.
+type Count struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// counter tallies names keeping the order they were first seen.
+type counter struct {
+	names  []string
+	counts map[string]int
+}
+
+func newCounter() *counter {
+	return &counter{counts: map[string]int{}}
+}
+
+func (c *counter) add(name string, n int) {
+	if _, ok := c.counts[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.counts[name] += n
+}
+
+// sorted returns the counts, largest first then by name.
+func (c *counter) sorted() []Count {
+	counts := make([]Count, 0, len(c.names))
+	for _, name := range c.names {
+		counts = append(counts, Count{Name: name, Count: c.counts[name]})
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		if counts[i].Count == counts[j].Count {
+			return counts[i].Name < counts[j].Name
+		}
+		return counts[i].Count > counts[j].Count
+	})
+	return counts
+}
+
+// InventorySection is one group of counts in an inventory, e.g.
+// "Asset Type".
+type InventorySection struct {
+	Category string  `json:"category"`
+	Counts   []Count `json:"counts"`
+}
+
+// Inventory holds the counts of the content in a LibGuides export.
+type Inventory struct {
+	Sections []*InventorySection `json:"sections"`
+	// GuidesWithoutPages and GuidesWithoutSubjects list guides as
+	// "Name (Id)" for follow up
+	GuidesWithoutPages    []string `json:"guides_without_pages"`
+	GuidesWithoutSubjects []string `json:"guides_without_subjects"`
+}
+
+// Section returns the named section or nil if not found.
+func (inv *Inventory) Section(category string) *InventorySection {
+	for _, section := range inv.Sections {
+		if section.Category == category {
+			return section
+		}
+	}
+	return nil
+}
+
+// Total returns the count for the name in the "Total" section.
+func (inv *Inventory) Total(name string) int {
+	if section := inv.Section("Total"); section != nil {
+		for _, c := range section.Counts {
+			if c.Name == name {
+				return c.Count
+			}
+		}
+	}
+	return 0
+}
+
+func guideLabel(guide *Guide) string {
+	return fmt.Sprintf("%s (%d)", guide.Name, guide.Id)
+}
+
+// orUnknown labels empty values so they are still counted.
+func orUnknown(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+// ownerOrUnknown labels a missing owner like orUnknown, ownerName
+// renders a missing owner as " <>".
+func ownerOrUnknown(opts *Options, owner Owner) string {
+	if owner.FirstName == "" && owner.LastName == "" && owner.Email == "" {
+		return orUnknown("")
+	}
+	return opts.ownerName(owner)
+}
+
+// NewInventory walks a LibGuides object counting guides by status, type
+// and group, pages per guide, boxes by type, assets by type, guides and
+// assets per owner and guides per subject and tag. Pages, boxes and
+// assets are counted according to the hidden policy and owners named
+// using the owner overrides in opts, which may be nil. It also lists
+// the guides with no pages or no subjects.
+func NewInventory(lg *LibGuides, opts *Options) *Inventory {
+	hidden := HiddenSkip
+	if opts != nil {
+		hidden = opts.Hidden
+	}
+	totals := newCounter()
+	byStatus, byType, byGroup := newCounter(), newCounter(), newCounter()
+	pagesPerGuide, boxTypes, assetTypes := newCounter(), newCounter(), newCounter()
+	ownerGuides, ownerAssets := newCounter(), newCounter()
+	bySubject, byTag := newCounter(), newCounter()
+	inv := &Inventory{
+		GuidesWithoutPages:    []string{},
+		GuidesWithoutSubjects: []string{},
+	}
+	for _, name := range []string{"Account", "Group", "Subject", "Tag", "Vendor", "Guide", "Page", "Box", "Asset"} {
+		totals.add(name, 0)
+	}
+	totals.add("Account", len(lg.Accounts))
+	totals.add("Group", len(lg.Groups))
+	totals.add("Subject", len(lg.Subjects))
+	totals.add("Tag", len(lg.Tags))
+	totals.add("Vendor", len(lg.Vendors))
+	for _, guide := range lg.Guides {
+		totals.add("Guide", 1)
+		byStatus.add(orUnknown(guide.Status), 1)
+		byType.add(orUnknown(guide.Type), 1)
+		byGroup.add(orUnknown(guide.Group.Name), 1)
+		ownerGuides.add(ownerOrUnknown(opts, guide.Owner), 1)
+		for _, subject := range guide.Subjects {
+			bySubject.add(subject.Name, 1)
+		}
+		for _, tag := range guide.Tags {
+			byTag.add(tag.Name, 1)
+		}
+		if len(guide.Subjects) == 0 {
+			inv.GuidesWithoutSubjects = append(inv.GuidesWithoutSubjects, guideLabel(guide))
+		}
+		pages := 0
+		for _, page := range guide.Pages {
+			pageHidden := page.Hidden != 0
+			if hidden.Allows(pageHidden) {
+				pages++
+			}
+			for _, box := range page.Boxes {
+				if !hidden.Allows(pageHidden || box.Hidden != 0) {
+					continue
+				}
+				totals.add("Box", 1)
+				boxTypes.add(orUnknown(box.Type), 1)
+				assets := append([]*Asset{}, box.Assets...)
+				for _, pane := range box.Panes {
+					assets = append(assets, pane.Assets...)
+				}
+				for _, asset := range assets {
+					totals.add("Asset", 1)
+					assetTypes.add(orUnknown(asset.Type), 1)
+					ownerAssets.add(ownerOrUnknown(opts, asset.Owner), 1)
+				}
+			}
+		}
+		totals.add("Page", pages)
+		pagesPerGuide.add(guideLabel(guide), pages)
+		if len(guide.Pages) == 0 {
+			inv.GuidesWithoutPages = append(inv.GuidesWithoutPages, guideLabel(guide))
+		}
+	}
+	// Totals keep their natural order, the rest are largest first
+	inv.Sections = append(inv.Sections, &InventorySection{Category: "Total", Counts: []Count{}})
+	for _, name := range totals.names {
+		inv.Sections[0].Counts = append(inv.Sections[0].Counts, Count{Name: name, Count: totals.counts[name]})
+	}
+	for _, section := range []struct {
+		category string
+		counts   *counter
+	}{
+		{"Guide Status", byStatus},
+		{"Guide Type", byType},
+		{"Guide Group", byGroup},
+		{"Pages per Guide", pagesPerGuide},
+		{"Box Type", boxTypes},
+		{"Asset Type", assetTypes},
+		{"Guides per Owner", ownerGuides},
+		{"Assets per Owner", ownerAssets},
+		{"Guides per Subject", bySubject},
+		{"Guides per Tag", byTag},
+	} {
+		inv.Sections = append(inv.Sections, &InventorySection{Category: section.category, Counts: section.counts.sorted()})
+	}
+	return inv
+}
+
+// InventoryTable returns the inventory as a Table with the columns
+// "Category", "Name" and "Count". Guides without pages or subjects
+// are listed with a count of zero.
+func InventoryTable(inv *Inventory, caption string) *Table {
+	tbl := new(Table)
+	tbl.SetCaption(caption)
+	tbl.AppendHeadings("Category", "Name", "Count")
+	for _, section := range inv.Sections {
+		for _, c := range section.Counts {
+			tbl.AppendRow(section.Category, c.Name, strInt(c.Count))
+		}
+	}
+	for _, label := range inv.GuidesWithoutPages {
+		tbl.AppendRow("Guide without Pages", label, "0")
+	}
+	for _, label := range inv.GuidesWithoutSubjects {
+		tbl.AppendRow("Guide without Subjects", label, "0")
+	}
+	return tbl
+}
+
+var inventoryTemplate = template.Must(template.New("inventory").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<style>
+body { font-family: sans-serif; }
+section { display: inline-block; vertical-align: top; margin: 0 2em 1em 0; }
+td.count { text-align: right; }
+</style>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+{{- range .Inventory.Sections }}
+<section>
+<table>
+<caption>{{ .Category }}</caption>
+<thead><tr><th>Name</th><th>Count</th></tr></thead>
+<tbody>
+{{- range .Counts }}
+<tr><td>{{ .Name }}</td><td class="count">{{ .Count }}</td></tr>
+{{- end }}
+</tbody>
+</table>
+</section>
+{{- end }}
+<section>
+<h2>Guides without pages</h2>
+{{ if .Inventory.GuidesWithoutPages }}<ul>{{ range .Inventory.GuidesWithoutPages }}<li>{{ . }}</li>{{ end }}</ul>{{ else }}<p>None</p>{{ end }}
+</section>
+<section>
+<h2>Guides without subjects</h2>
+{{ if .Inventory.GuidesWithoutSubjects }}<ul>{{ range .Inventory.GuidesWithoutSubjects }}<li>{{ . }}</li>{{ end }}</ul>{{ else }}<p>None</p>{{ end }}
+</section>
+</body>
+</html>
+`))
+
+// InventoryHTML renders the inventory as a one page HTML summary.
+func InventoryHTML(inv *Inventory, title string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := inventoryTemplate.Execute(buf, map[string]interface{}{
+		"Title":     title,
+		"Inventory": inv,
+	})
+	return buf.Bytes(), err
+}
+
+// SummaryFormat is the format name of the one page HTML summary written
+// by InventoryReport, "html" remains the table format.
+const SummaryFormat = "summary"
+
+// InventoryReport reads a LibGuides export and writes its inventory to
+// destName. The format in opts may be csv, json or xml (a table) or
+// summary (a one page HTML summary).
+func InventoryReport(srcName string, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	lg, err := opts.ReadLibGuides(srcName)
+	if err != nil {
+		return err
+	}
+	inv := NewInventory(lg, opts)
+	opts.Logf("counted %d guides, %d pages, %d boxes and %d assets", inv.Total("Guide"), inv.Total("Page"), inv.Total("Box"), inv.Total("Asset"))
+	caption := fmt.Sprintf("Inventory of %q", srcName)
+	if strings.EqualFold(opts.Format, SummaryFormat) {
+		src, err := InventoryHTML(inv, caption)
+		if err != nil {
+			return err
+		}
+		return WriteDestinationWithOptions(destName, src, &opts.WriteOptions)
+	}
+	return opts.WriteTable(InventoryTable(inv, caption), destName)
+}
@@ -0,0 +1,175 @@
+// inventory_test.go tests the content inventory report.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestNewInventory(t *testing.T) {
+	owner := Owner{Id: 1, FirstName: "Shrimp", LastName: "Jones", Email: "shrimps@engineering.example.edu"}
+	other := Owner{Id: 2, FirstName: "Zoe", LastName: "Smith", Email: "zoe@library.example.edu"}
+	lg := &LibGuides{
+		Subjects: []*Subject{{Id: 1, Name: "Engineering"}, {Id: 2, Name: "Chemistry"}},
+		Guides: []*Guide{
+			{
+				Id: 1, Name: "Engineering", Status: "Published", Type: "Subject Guide",
+				Group: Group{Name: "Library"}, Owner: owner,
+				Subjects: []*Subject{{Id: 1, Name: "Engineering"}},
+				Tags:     []*Tag{{Id: 1, Name: "patents"}},
+				Pages: []*Page{
+					{Id: 10, Boxes: []*Box{
+						{Id: 100, Type: "Rich Text/HTML", Assets: []*Asset{
+							{Id: 1000, Type: "Link", Owner: owner},
+							{Id: 1001, Type: "Link", Owner: other},
+						}},
+						{Id: 101, Type: "Tabbed", Panes: []*Pane{
+							{Assets: []*Asset{{Id: 1002, Type: "Database", Owner: owner}}},
+						}},
+					}},
+					{Id: 11, Hidden: 1, Boxes: []*Box{
+						{Id: 102, Type: "Rich Text/HTML", Assets: []*Asset{{Id: 1003, Type: "Link", Owner: owner}}},
+					}},
+				},
+			},
+			{
+				Id: 2, Name: "Chemistry", Status: "Published", Type: "Subject Guide", Owner: other,
+				Subjects: []*Subject{{Id: 2, Name: "Chemistry"}},
+				Pages:    []*Page{{Id: 20}},
+			},
+			{Id: 3, Name: "Draft", Status: "Unpublished", Type: "General Purpose Guide", Owner: owner},
+		},
+	}
+	inv := NewInventory(lg, nil)
+	expectedInt(t, 3, inv.Total("Guide"))
+	expectedInt(t, 2, inv.Total("Page"))
+	expectedInt(t, 2, inv.Total("Box"))
+	expectedInt(t, 3, inv.Total("Asset"))
+	expectedInt(t, 2, inv.Total("Subject"))
+
+	section := inv.Section("Guide Status")
+	if section == nil {
+		t.Fatalf("expected a Guide Status section")
+	}
+	expectedString(t, "Published", section.Counts[0].Name)
+	expectedInt(t, 2, section.Counts[0].Count)
+	section = inv.Section("Asset Type")
+	expectedString(t, "Link", section.Counts[0].Name)
+	expectedInt(t, 2, section.Counts[0].Count)
+	section = inv.Section("Guide Group")
+	expectedString(t, "(none)", section.Counts[0].Name)
+	expectedInt(t, 2, section.Counts[0].Count)
+	expectedString(t, "(none)", ownerOrUnknown(nil, Owner{}))
+	section = inv.Section("Assets per Owner")
+	expectedString(t, "Shrimp Jones <shrimps@engineering.example.edu>", section.Counts[0].Name)
+	expectedInt(t, 2, section.Counts[0].Count)
+
+	expectedString(t, "Draft (3)", strings.Join(inv.GuidesWithoutPages, ";"))
+	expectedString(t, "Draft (3)", strings.Join(inv.GuidesWithoutSubjects, ";"))
+
+	inv = NewInventory(lg, &Options{Hidden: HiddenInclude})
+	expectedInt(t, 3, inv.Total("Page"))
+	expectedInt(t, 4, inv.Total("Asset"))
+	// Owners are named using the owner overrides
+	overridden := NewInventory(lg, &Options{OwnerOverrides: map[string]string{owner.Email: "Engineering Library"}})
+	section = overridden.Section("Guides per Owner")
+	expectedString(t, "Engineering Library", section.Counts[0].Name)
+	expectedInt(t, 2, section.Counts[0].Count)
+	expectedString(t, "Engineering Library", overridden.Section("Assets per Owner").Counts[0].Name)
+
+	tbl := InventoryTable(inv, "inventory")
+	if err := tbl.Where(`Category == "Pages per Guide"`); err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 3, len(tbl.Body.Rows))
+	expectedString(t, "Pages per Guide,Engineering (1),2", joinRow(tbl.Body.Rows[0]))
+
+	src, err := InventoryHTML(inv, "Inventory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"<caption>Asset Type</caption>", "<li>Draft (3)</li>"} {
+		if !strings.Contains(string(src), expected) {
+			t.Errorf("expected HTML to contain %q", expected)
+		}
+	}
+}
+
+func TestInventoryReport(t *testing.T) {
+	srcName := path.Join("testinput", "LibGuides_export_XXXXX.xml")
+	destName := path.Join("testout", "inventory.json")
+	if err := InventoryReport(srcName, destName, &Options{Format: "json"}); err != nil {
+		t.Fatal(err)
+	}
+	src, err := ioutil.ReadFile(destName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl := new(Table)
+	if err := json.Unmarshal(src, tbl); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.Where(`Category == "Total" && Name == "Guide"`); err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 1, len(tbl.Body.Rows))
+
+	destName = path.Join("testout", "inventory.html")
+	if err := InventoryReport(srcName, destName, &Options{Format: SummaryFormat}); err != nil {
+		t.Fatal(err)
+	}
+	src, err = ioutil.ReadFile(destName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "<caption>Guide Status</caption>") {
+		t.Errorf("expected an HTML summary in %q", destName)
+	}
+	// An .html destination is the table, like the other reports
+	destName = path.Join("testout", "inventory-table.html")
+	if err := InventoryReport(srcName, destName, nil); err != nil {
+		t.Fatal(err)
+	}
+	src, err = ioutil.ReadFile(destName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(src), "<caption>Guide Status</caption>") {
+		t.Errorf("expected a table in %q, not the summary", destName)
+	}
+}
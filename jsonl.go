@@ -0,0 +1,315 @@
+// jsonl.go exports a LibGuides object as JSON Lines, one file per entity
+// with each record flattened and linked to its parents by id.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// JSONLinesEntities lists the entities of a JSON Lines export in the
+// order written, each is written to "<entity>.jsonl".
+var JSONLinesEntities = []string{
+	"customer", "site", "accounts", "groups", "subjects", "tags",
+	"vendors", "guides", "guide_subjects", "guide_tags", "pages",
+	"boxes", "assets",
+}
+
+// GuideRecord is a guide without its subjects, tags and pages, the
+// owner and group are flattened into columns.
+type GuideRecord struct {
+	Id              int    `json:"id"`
+	Type            string `json:"type"`
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	DescriptionText string `json:"description_text,omitempty"`
+	Url             string `json:"url"`
+	OwnerId         int    `json:"owner_id"`
+	OwnerEmail      string `json:"owner_email"`
+	OwnerFirstName  string `json:"owner_first_name"`
+	OwnerLastName   string `json:"owner_last_name"`
+	GroupId         int    `json:"group_id"`
+	GroupName       string `json:"group_name"`
+	Redirect        string `json:"redirect"`
+	Status          string `json:"status"`
+	Created         string `json:"created"`
+	Updated         string `json:"updated"`
+	Modified        string `json:"modified"`
+	Published       string `json:"published"`
+}
+
+// GuideSubjectRecord links a guide to a subject.
+type GuideSubjectRecord struct {
+	GuideId     int    `json:"guide_id"`
+	SubjectId   int    `json:"subject_id"`
+	SubjectName string `json:"subject_name"`
+}
+
+// GuideTagRecord links a guide to a tag.
+type GuideTagRecord struct {
+	GuideId int    `json:"guide_id"`
+	TagId   int    `json:"tag_id"`
+	TagName string `json:"tag_name"`
+}
+
+// PageRecord is a page without its boxes.
+type PageRecord struct {
+	Id              int    `json:"id"`
+	GuideId         int    `json:"guide_id"`
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	DescriptionText string `json:"description_text,omitempty"`
+	Url             string `json:"url"`
+	Redirect        string `json:"redirect"`
+	SourcePageId    int    `json:"source_page_id"`
+	ParentPageId    int    `json:"parent_page_id"`
+	Position        int    `json:"position"`
+	Hidden          int    `json:"hidden"`
+	Created         string `json:"created"`
+	Updated         string `json:"updated"`
+	Modified        string `json:"modified"`
+}
+
+// BoxRecord is a box without its assets and panes.
+type BoxRecord struct {
+	Id        int    `json:"id"`
+	GuideId   int    `json:"guide_id"`
+	PageId    int    `json:"page_id"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	MapId     string `json:"map_id"`
+	Column    int    `json:"column"`
+	Position  int    `json:"position"`
+	Hidden    int    `json:"hidden"`
+	PaneCount int    `json:"pane_count"`
+	Created   string `json:"created"`
+	Updated   string `json:"updated"`
+}
+
+// AssetRecord is an asset placed in a box. Pane is the position of the
+// box pane holding the asset counting from one, zero when the asset
+// belongs to the box itself.
+type AssetRecord struct {
+	Id              int    `json:"id"`
+	GuideId         int    `json:"guide_id"`
+	PageId          int    `json:"page_id"`
+	BoxId           int    `json:"box_id"`
+	Pane            int    `json:"pane"`
+	Name            string `json:"name"`
+	Type            string `json:"type"`
+	Description     string `json:"description"`
+	DescriptionText string `json:"description_text,omitempty"`
+	Url             string `json:"url"`
+	OwnerId         int    `json:"owner_id"`
+	OwnerEmail      string `json:"owner_email"`
+	OwnerFirstName  string `json:"owner_first_name"`
+	OwnerLastName   string `json:"owner_last_name"`
+	MapId           string `json:"map_id"`
+	Position        int    `json:"position"`
+	Created         string `json:"created"`
+	Updated         string `json:"updated"`
+}
+
+// jsonLinesWriter encodes records as JSON Lines per entity.
+type jsonLinesWriter struct {
+	files map[string]*bytes.Buffer
+	err   error
+}
+
+func (w *jsonLinesWriter) write(entity string, record interface{}) {
+	if w.err != nil {
+		return
+	}
+	buf, ok := w.files[entity]
+	if !ok {
+		buf = new(bytes.Buffer)
+		w.files[entity] = buf
+	}
+	// NOTE: Encode ends each record with a newline
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(record); err != nil {
+		w.err = fmt.Errorf("%s: %s", entity, err)
+	}
+}
+
+// newGuideRecord flattens a guide into a GuideRecord.
+func newGuideRecord(guide *Guide) *GuideRecord {
+	return &GuideRecord{
+		Id: guide.Id, Type: guide.Type, Name: guide.Name,
+		Description: guide.Description, DescriptionText: guide.DescriptionText,
+		Url: guide.Url, Redirect: guide.Redirect, Status: guide.Status,
+		OwnerId: guide.Owner.Id, OwnerEmail: guide.Owner.Email,
+		OwnerFirstName: guide.Owner.FirstName, OwnerLastName: guide.Owner.LastName,
+		GroupId: guide.Group.Id, GroupName: guide.Group.Name,
+		Created: guide.Created, Updated: guide.Updated,
+		Modified: guide.Modified, Published: guide.Published,
+	}
+}
+
+// newPageRecord flattens a page of a guide into a PageRecord.
+func newPageRecord(guideId int, page *Page) *PageRecord {
+	return &PageRecord{
+		Id: page.Id, GuideId: guideId, Name: page.Name,
+		Description: page.Description, DescriptionText: page.DescriptionText,
+		Url: page.Url, Redirect: page.Redirect,
+		SourcePageId: page.SourcePageId, ParentPageId: page.ParentPageId,
+		Position: page.Position, Hidden: page.Hidden,
+		Created: page.Created, Updated: page.Updated, Modified: page.Modified,
+	}
+}
+
+// newAssetRecord flattens an asset of a box (pane zero) or of one of
+// its panes (counting from one) into an AssetRecord.
+func newAssetRecord(guideId, pageId, boxId, pane int, asset *Asset) *AssetRecord {
+	return &AssetRecord{
+		Id: asset.Id, GuideId: guideId, PageId: pageId, BoxId: boxId, Pane: pane,
+		Name: asset.Name, Type: asset.Type, Description: asset.Description,
+		DescriptionText: asset.DescriptionText, Url: asset.Url,
+		OwnerId: asset.Owner.Id, OwnerEmail: asset.Owner.Email,
+		OwnerFirstName: asset.Owner.FirstName, OwnerLastName: asset.Owner.LastName,
+		MapId: asset.MapId, Position: asset.Position,
+		Created: asset.Created, Updated: asset.Updated,
+	}
+}
+
+// ToJSONLines flattens a LibGuides object into JSON Lines returning the
+// records of each entity in JSONLinesEntities. Nested lists are removed
+// and child records carry the ids of their parents (guide_id, page_id,
+// box_id and the pane position).
+func (lg *LibGuides) ToJSONLines() (map[string][]byte, error) {
+	w := &jsonLinesWriter{files: map[string]*bytes.Buffer{}}
+	for _, entity := range JSONLinesEntities {
+		w.files[entity] = new(bytes.Buffer)
+	}
+	if lg.Customer != nil {
+		w.write("customer", lg.Customer)
+	}
+	if lg.Site != nil {
+		w.write("site", lg.Site)
+	}
+	for _, account := range lg.Accounts {
+		w.write("accounts", account)
+	}
+	for _, group := range lg.Groups {
+		w.write("groups", group)
+	}
+	for _, subject := range lg.Subjects {
+		w.write("subjects", subject)
+	}
+	for _, tag := range lg.Tags {
+		w.write("tags", tag)
+	}
+	for _, vendor := range lg.Vendors {
+		w.write("vendors", vendor)
+	}
+	for _, guide := range lg.Guides {
+		w.write("guides", newGuideRecord(guide))
+		for _, subject := range guide.Subjects {
+			w.write("guide_subjects", &GuideSubjectRecord{GuideId: guide.Id, SubjectId: subject.Id, SubjectName: subject.Name})
+		}
+		for _, tag := range guide.Tags {
+			w.write("guide_tags", &GuideTagRecord{GuideId: guide.Id, TagId: tag.Id, TagName: tag.Name})
+		}
+		for _, page := range guide.Pages {
+			w.write("pages", newPageRecord(guide.Id, page))
+			for _, box := range page.Boxes {
+				w.write("boxes", &BoxRecord{
+					Id: box.Id, GuideId: guide.Id, PageId: page.Id,
+					Name: box.Name, Type: box.Type, MapId: box.MapId,
+					Column: box.Column, Position: box.Position, Hidden: box.Hidden,
+					PaneCount: len(box.Panes), Created: box.Created, Updated: box.Updated,
+				})
+				writeAsset := func(asset *Asset, pane int) {
+					w.write("assets", newAssetRecord(guide.Id, page.Id, box.Id, pane, asset))
+				}
+				for _, asset := range box.Assets {
+					writeAsset(asset, 0)
+				}
+				for i, pane := range box.Panes {
+					for _, asset := range pane.Assets {
+						writeAsset(asset, i+1)
+					}
+				}
+			}
+		}
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	files := map[string][]byte{}
+	for entity, buf := range w.files {
+		files[entity] = buf.Bytes()
+	}
+	return files, nil
+}
+
+// LibGuidesXMLFileToJSONLines reads a LibGuides XML export and writes a
+// JSON Lines file per entity (e.g. guides.jsonl, pages.jsonl) into the
+// directory destDir, creating it if needed. Files are written with the
+// write options in opts, which may be nil.
+func LibGuidesXMLFileToJSONLines(srcName string, destDir string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	if destDir == StdIO || destDir == "" {
+		return fmt.Errorf("JSON Lines are written to a directory, not standard output")
+	}
+	lg, err := opts.ReadLibGuides(srcName)
+	if err != nil {
+		return err
+	}
+	if opts.DescriptionText {
+		lg.SetDescriptionText()
+	}
+	files, err := lg.ToJSONLines()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(destDir, 0775); err != nil {
+		return err
+	}
+	for _, entity := range JSONLinesEntities {
+		destName := filepath.Join(destDir, entity+".jsonl")
+		if err := WriteDestinationWithOptions(destName, files[entity], &opts.WriteOptions); err != nil {
+			return err
+		}
+		opts.Logf("wrote %d %s to %q", bytes.Count(files[entity], []byte("\n")), entity, destName)
+	}
+	return nil
+}
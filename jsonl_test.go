@@ -0,0 +1,131 @@
+// jsonl_test.go tests the JSON Lines export.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestToJSONLines(t *testing.T) {
+	owner := Owner{Id: 1, Email: "shrimps@engineering.example.edu"}
+	lg := &LibGuides{
+		Accounts: []*Account{{Id: 1, Email: owner.Email}},
+		Guides: []*Guide{
+			{
+				Id: 1, Name: "Engineering", Owner: owner, Group: Group{Id: 5, Name: "Science"},
+				Subjects: []*Subject{{Id: 2, Name: "Engineering"}},
+				Tags:     []*Tag{{Id: 3, Name: "patents"}, {Id: 4, Name: "standards"}},
+				Pages: []*Page{
+					{Id: 10, Name: "Home", Boxes: []*Box{
+						{Id: 100, Assets: []*Asset{{Id: 1000, Name: "Catalog", Owner: owner}}},
+						{Id: 101, Panes: []*Pane{
+							{Assets: []*Asset{{Id: 1001}}},
+							{Assets: []*Asset{{Id: 1002, Description: "<p>A & B</p>"}}},
+						}},
+					}},
+				},
+			},
+		},
+	}
+	files, err := lg.ToJSONLines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entity := range JSONLinesEntities {
+		if _, ok := files[entity]; !ok {
+			t.Errorf("expected records for %q", entity)
+		}
+	}
+	lines := func(entity string) []map[string]interface{} {
+		records := []map[string]interface{}{}
+		scanner := bufio.NewScanner(bytes.NewReader(files[entity]))
+		for scanner.Scan() {
+			record := map[string]interface{}{}
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Fatalf("%s: %s", entity, err)
+			}
+			records = append(records, record)
+		}
+		return records
+	}
+	expectedInt(t, 0, len(lines("customer")))
+	expectedInt(t, 1, len(lines("accounts")))
+	expectedInt(t, 1, len(lines("guide_subjects")))
+	expectedInt(t, 2, len(lines("guide_tags")))
+	guides := lines("guides")
+	expectedInt(t, 1, len(guides))
+	if _, ok := guides[0]["pages"]; ok {
+		t.Errorf("expected the nested pages to be removed")
+	}
+	expectedString(t, "shrimps@engineering.example.edu", guides[0]["owner_email"].(string))
+	expectedString(t, "Science", guides[0]["group_name"].(string))
+	boxes := lines("boxes")
+	expectedInt(t, 2, len(boxes))
+	expectedInt(t, 2, int(boxes[1]["pane_count"].(float64)))
+	assets := lines("assets")
+	expectedInt(t, 3, len(assets))
+	for i, expected := range []string{"1000,1,10,100,0", "1001,1,10,101,1", "1002,1,10,101,2"} {
+		a := assets[i]
+		got := joinRow([]string{
+			strInt(int(a["id"].(float64))), strInt(int(a["guide_id"].(float64))),
+			strInt(int(a["page_id"].(float64))), strInt(int(a["box_id"].(float64))),
+			strInt(int(a["pane"].(float64))),
+		})
+		expectedString(t, expected, got)
+	}
+	if !bytes.Contains(files["assets"], []byte(`"<p>A & B</p>"`)) {
+		t.Errorf("expected HTML to be left unescaped")
+	}
+}
+
+func TestLibGuidesXMLFileToJSONLines(t *testing.T) {
+	srcName := path.Join("testinput", "LibGuides_export_XXXXX.xml")
+	destDir := path.Join("testout", "jsonl")
+	if err := RunCommand("springytools", []string{"convert", "-jsonl", srcName, destDir}); err != 0 {
+		t.Fatalf("expected exit code 0, got %d", err)
+	}
+	for _, entity := range JSONLinesEntities {
+		if _, err := os.Stat(path.Join(destDir, entity+".jsonl")); err != nil {
+			t.Errorf("expected %s.jsonl, %s", entity, err)
+		}
+	}
+	if err := LibGuidesXMLFileToJSONLines(srcName, StdIO, nil); err == nil {
+		t.Errorf("expected standard output to fail")
+	}
+}
@@ -82,14 +82,15 @@ type Account struct {
 }
 
 type Group struct {
-	Id          int    `xml:"id" json:"id"`
-	Type        string `xml:"type" json:"type"`
-	Name        string `xml:"name" json:"name"`
-	Url         string `xml:"url" json:"url"`
-	Description string `xml:"description" json:"description"`
-	Password    string `xml:"password" json:"password"`
-	Created     string `xml:"created" json:"created"`
-	Updated     string `xml:"updated" json:"updated"`
+	Id              int    `xml:"id" json:"id"`
+	Type            string `xml:"type" json:"type"`
+	Name            string `xml:"name" json:"name"`
+	Url             string `xml:"url" json:"url"`
+	Description     string `xml:"description" json:"description"`
+	DescriptionText string `xml:"-" json:"description_text,omitempty"`
+	Password        string `xml:"password" json:"password"`
+	Created         string `xml:"created" json:"created"`
+	Updated         string `xml:"updated" json:"updated"`
 }
 
 type Subject struct {
@@ -122,13 +123,14 @@ type Asset struct {
 	Name string `xml:"name" json:"name"`
 	Type string `xml:"type" json:"type"`
 	// Description contains HTML encoded text, double encoding existing encoded text
-	Description string `xml:"description" json:"description"`
-	Url         string `xml:"url" json:"url"`
-	Owner       Owner  `xml:"owner" json:"owner"`
-	MapId       string `xml:"map_id" json:"map_id"`
-	Position    int    `xml:"position" json:"position"`
-	Created     string `xml:"created" json:"created"`
-	Updated     string `xml:"updated" json:"updated"`
+	Description     string `xml:"description" json:"description"`
+	DescriptionText string `xml:"-" json:"description_text,omitempty"`
+	Url             string `xml:"url" json:"url"`
+	Owner           Owner  `xml:"owner" json:"owner"`
+	MapId           string `xml:"map_id" json:"map_id"`
+	Position        int    `xml:"position" json:"position"`
+	Created         string `xml:"created" json:"created"`
+	Updated         string `xml:"updated" json:"updated"`
 }
 
 type Pane struct {
@@ -151,38 +153,40 @@ type Box struct {
 }
 
 type Page struct {
-	Id           int    `xml:"id" json:"id"`
-	Name         string `xml:"name" json:"name"`
-	Description  string `xml:"description" json:"description"`
-	Url          string `xml:"url" json:"url"`
-	Redirect     string `xml:"redirect" json:"redirect"`
-	SourcePageId int    `xml:"source_page_id" json:"source_page_id"`
-	ParentPageId int    `xml:"parent_page_id" json:"parent_page_id"`
-	Position     int    `xml:"position" json:"position"`
-	Hidden       int    `xml:"hidden" json:"hidden"`
-	Created      string `xml:"created" json:"created"`
-	Updated      string `xml:"updated" json:"updated"`
-	Modified     string `xml:"modified" json:"modified"`
-	Boxes        []*Box `xml:"boxes>box" json:"boxes"`
+	Id              int    `xml:"id" json:"id"`
+	Name            string `xml:"name" json:"name"`
+	Description     string `xml:"description" json:"description"`
+	DescriptionText string `xml:"-" json:"description_text,omitempty"`
+	Url             string `xml:"url" json:"url"`
+	Redirect        string `xml:"redirect" json:"redirect"`
+	SourcePageId    int    `xml:"source_page_id" json:"source_page_id"`
+	ParentPageId    int    `xml:"parent_page_id" json:"parent_page_id"`
+	Position        int    `xml:"position" json:"position"`
+	Hidden          int    `xml:"hidden" json:"hidden"`
+	Created         string `xml:"created" json:"created"`
+	Updated         string `xml:"updated" json:"updated"`
+	Modified        string `xml:"modified" json:"modified"`
+	Boxes           []*Box `xml:"boxes>box" json:"boxes"`
 }
 
 type Guide struct {
-	Id          int        `xml:"id" json:"id"`
-	Type        string     `xml:"type" json:"type"`
-	Name        string     `xml:"name" json:"name"`
-	Description string     `xml:"description" json:"description"`
-	Url         string     `xml:"url" json:"url"`
-	Owner       Owner      `xml:"owner" json:"owner"`
-	Group       Group      `xml:"group" json:"group"`
-	Redirect    string     `xml:"redirect" json:"redirect"`
-	Status      string     `xml:"status" json:"status"`
-	Created     string     `xml:"created" json:"created"`
-	Updated     string     `xml:"updated" json:"updated"`
-	Modified    string     `xml:"modified" json:"modified"`
-	Published   string     `xml:"published" json:"published"`
-	Subjects    []*Subject `xml:"subjects>subject" json:"subjects"`
-	Tags        []*Tag     `xml:"tags>tag" json:"tags"`
-	Pages       []*Page    `xml:"pages>page" json:"pages"`
+	Id              int        `xml:"id" json:"id"`
+	Type            string     `xml:"type" json:"type"`
+	Name            string     `xml:"name" json:"name"`
+	Description     string     `xml:"description" json:"description"`
+	DescriptionText string     `xml:"-" json:"description_text,omitempty"`
+	Url             string     `xml:"url" json:"url"`
+	Owner           Owner      `xml:"owner" json:"owner"`
+	Group           Group      `xml:"group" json:"group"`
+	Redirect        string     `xml:"redirect" json:"redirect"`
+	Status          string     `xml:"status" json:"status"`
+	Created         string     `xml:"created" json:"created"`
+	Updated         string     `xml:"updated" json:"updated"`
+	Modified        string     `xml:"modified" json:"modified"`
+	Published       string     `xml:"published" json:"published"`
+	Subjects        []*Subject `xml:"subjects>subject" json:"subjects"`
+	Tags            []*Tag     `xml:"tags>tag" json:"tags"`
+	Pages           []*Page    `xml:"pages>page" json:"pages"`
 }
 
 type LibGuides struct {
@@ -218,3 +222,15 @@ func (lg *LibGuides) ToJSON() ([]byte, error) {
 	}
 	return src, nil
 }
+
+// ToXML takes a LibGuides object and renders it as a LibGuides XML
+// export.
+func (lg *LibGuides) ToXML() ([]byte, error) {
+	out := *lg
+	out.XMLName = xml.Name{Local: "libguides"}
+	src, err := xml.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(src, '\n')...), nil
+}
@@ -171,3 +171,22 @@ func TestLibGuides(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestLibGuidesToXML(t *testing.T) {
+	lg, err := ReadLibGuides("testinput/LibGuides_export_XXXXX.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := lg.ToXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := new(LibGuides)
+	if err := copied.FromXML(src); err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, "libguides", copied.XMLName.Local)
+	expectedInt(t, len(lg.Accounts), len(copied.Accounts))
+	expectedInt(t, len(lg.Guides), len(copied.Guides))
+	expectedString(t, lg.Guides[0].Pages[0].Boxes[0].Assets[0].Description, copied.Guides[0].Pages[0].Boxes[0].Assets[0].Description)
+}
@@ -0,0 +1,378 @@
+// options.go provides the options shared by the reports, conversions and
+// the springytools command line programs.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// SourceExport reads LibGuides from an export file (the default)
+	SourceExport = "export"
+	// SourceAPI reads LibGuides from the LibGuides API
+	SourceAPI = "api"
+)
+
+// HiddenPolicy says how hidden pages and boxes are treated by reports.
+type HiddenPolicy string
+
+const (
+	// HiddenSkip leaves out hidden content (the default)
+	HiddenSkip HiddenPolicy = "skip"
+	// HiddenInclude reports on both hidden and visible content
+	HiddenInclude HiddenPolicy = "include"
+	// HiddenOnly reports on hidden content only
+	HiddenOnly HiddenPolicy = "only"
+)
+
+// Allows returns true if content, hidden or not, should be reported.
+// Content is hidden if it or any of its parents is marked hidden.
+func (h HiddenPolicy) Allows(hidden bool) bool {
+	switch h {
+	case HiddenInclude:
+		return true
+	case HiddenOnly:
+		return hidden
+	}
+	return !hidden
+}
+
+// String returns the name of the policy, an empty policy is "skip".
+func (h *HiddenPolicy) String() string {
+	if h == nil || *h == "" {
+		return string(HiddenSkip)
+	}
+	return string(*h)
+}
+
+// Set implements flag.Value for HiddenPolicy.
+func (h *HiddenPolicy) Set(val string) error {
+	switch policy := HiddenPolicy(strings.ToLower(val)); policy {
+	case HiddenSkip, HiddenInclude, HiddenOnly:
+		*h = policy
+		return nil
+	}
+	return fmt.Errorf("%q is not a hidden content policy, expected skip, include or only", val)
+}
+
+// columnList implements flag.Value for a comma delimited list of columns.
+type columnList struct {
+	columns *[]string
+}
+
+func (c columnList) String() string {
+	if c.columns == nil {
+		return ""
+	}
+	return strings.Join(*c.columns, ",")
+}
+
+func (c columnList) Set(val string) error {
+	*c.columns = SplitColumns(val)
+	return nil
+}
+
+// Options holds the settings shared by the reports, conversions and
+// command line programs. The zero value is ready to use.
+type Options struct {
+	// Input is the source file name, "-" is standard input
+	Input string `json:"input,omitempty"`
+	// Output is the destination file name, "-" is standard output
+	Output string `json:"output,omitempty"`
+	// Format is the report format (csv, json or xml), when empty it
+	// is guessed from the output file name.
+	Format string `json:"format,omitempty"`
+	// Hidden is the policy for hidden pages and boxes
+	Hidden HiddenPolicy `json:"hidden,omitempty"`
+	// Verbose logs progress to standard error
+	Verbose bool `json:"verbose,omitempty"`
+	// SitePrefix is used to build LibGuides links, e.g.
+	// "https://libguides.example.edu", defaults to the export's site domain
+	SitePrefix string `json:"site_prefix,omitempty"`
+	// ProxyPatterns are URL prefixes of a proxy service, e.g.
+	// "https://proxy.example.edu/login?url=". Reports list the proxied URL.
+	ProxyPatterns []string `json:"proxy_patterns,omitempty"`
+	// Ignore holds regular expressions of URLs to leave out of reports
+	Ignore []string `json:"ignore,omitempty"`
+	// Intranet holds regular expressions of host names only reachable
+	// inside the organization, e.g. `\.ad\.example\.edu$`
+	Intranet []string `json:"intranet,omitempty"`
+	// OwnerOverrides maps an owner's email address, in lower case, to the
+	// owner to report (LoadConfig lower cases the addresses)
+	OwnerOverrides map[string]string `json:"owner_overrides,omitempty"`
+	// StaleMonths is the review window of the stale report, zero
+	// means DefaultStaleMonths
+	StaleMonths int `json:"stale_months,omitempty"`
+	// JSONLines has the convert command write a directory of JSON Lines
+	// files, one per entity, rather than one JSON document
+	JSONLines bool `json:"json_lines,omitempty"`
+	// DescriptionText has the convert command add the plain text of
+	// each description as "description_text"
+	DescriptionText bool `json:"description_text,omitempty"`
+	// Markdown has the site command write Markdown with front matter
+	// rather than HTML
+	Markdown bool `json:"markdown,omitempty"`
+	// Summary has the link report count the links per category
+	Summary bool `json:"summary,omitempty"`
+	// Rollup summarizes a report per "owner" or "group"
+	Rollup string `json:"rollup,omitempty"`
+	// Departed is a CSV file of departed staff email addresses, and
+	// optionally their successors, for the owners report
+	Departed string `json:"departed,omitempty"`
+	// Similarity is the Jaccard similarity (0 to 1) above which the
+	// duplicates report lists descriptions as near duplicates, zero
+	// means DefaultSimilarity
+	Similarity float64 `json:"similarity,omitempty"`
+	// Patch has the clean command write a patch list of the cleaned
+	// descriptions rather than a cleaned export
+	Patch bool `json:"patch,omitempty"`
+	// SizeReport is the file the clean command writes the before and
+	// after sizes of the descriptions to
+	SizeReport string `json:"size_report,omitempty"`
+	// VocabularyMap is a CSV file mapping subjects and tags to their
+	// preferred terms for the vocabulary command
+	VocabularyMap string `json:"vocabulary_map,omitempty"`
+
+	// URLMap is a CSV file of old and new URLs for the fixurls command
+	URLMap string `json:"url_map,omitempty"`
+	// Apply has the fixurls command make its changes through the API
+	// rather than only list them
+	Apply bool `json:"-"`
+	// Undo is a rollback file of the fixurls command to undo
+	Undo string `json:"-"`
+	// AuditLog is the file the fixurls command appends the changes it
+	// makes to, empty means DefaultAuditLog
+	AuditLog string `json:"audit_log,omitempty"`
+	// RollbackFile is the file the fixurls command writes the changes
+	// it made to, empty means a file named for the time
+	RollbackFile string `json:"rollback_file,omitempty"`
+
+	// Addr is the host and port the serve command listens on, empty
+	// means DefaultAddr
+	Addr string `json:"addr,omitempty"`
+	// PollSeconds is how often the serve command checks a directory
+	// for a new export, zero means DefaultPollSeconds
+	PollSeconds int `json:"poll_seconds,omitempty"`
+
+	// Source is where reports read LibGuides from, "export" (the
+	// default) for an export file or "api" for the LibGuides API
+	Source string `json:"source,omitempty"`
+	// APIBase is the LibGuides API's base URL, empty means DefaultAPIBase
+	APIBase string `json:"api_base,omitempty"`
+	// ClientId and ClientSecret are the API's client credentials
+	ClientId     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+
+	// Config is the configuration file named on the command line
+	Config string `json:"-"`
+	// ConfigFiles lists the configuration files loaded
+	ConfigFiles []string `json:"-"`
+
+	TableOptions
+	WriteOptions
+
+	ignore   []*regexp.Regexp
+	intranet []*regexp.Regexp
+}
+
+// Validate checks the hidden policy and source, that the Ignore and
+// Intranet patterns are valid regular expressions and the similarity is
+// between 0 and 1.
+func (o *Options) Validate() error {
+	if err := o.Hidden.Set(o.Hidden.String()); err != nil {
+		return err
+	}
+	switch o.Source {
+	case "", SourceExport, SourceAPI:
+	default:
+		return fmt.Errorf("unknown source %q, expected %s or %s", o.Source, SourceExport, SourceAPI)
+	}
+	o.ignore = nil
+	for _, expr := range o.Ignore {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("ignore pattern %q: %s", expr, err)
+		}
+		o.ignore = append(o.ignore, re)
+	}
+	o.intranet = nil
+	for _, expr := range o.Intranet {
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return fmt.Errorf("intranet pattern %q: %s", expr, err)
+		}
+		o.intranet = append(o.intranet, re)
+	}
+	if o.Similarity < 0 || o.Similarity > 1 {
+		return fmt.Errorf("similarity %g is not between 0 and 1", o.Similarity)
+	}
+	return nil
+}
+
+// FromAPI returns true if LibGuides are read from the API rather than
+// an export.
+func (o *Options) FromAPI() bool {
+	return o != nil && o.Source == SourceAPI
+}
+
+// apiBase returns the API's base URL.
+func (o *Options) apiBase() string {
+	if o.APIBase == "" {
+		return DefaultAPIBase
+	}
+	return strings.TrimSuffix(o.APIBase, "/")
+}
+
+// APIClient returns a client for the API using APIBase, ClientId and
+// ClientSecret, logging to Logf.
+func (o *Options) APIClient() (*APIClient, error) {
+	if o.ClientId == "" || o.ClientSecret == "" {
+		return nil, fmt.Errorf("the API needs client_id and client_secret (or %sCLIENT_ID and %sCLIENT_SECRET)", EnvPrefix, EnvPrefix)
+	}
+	c := NewAPIClient(o.apiBase(), o.ClientId, o.ClientSecret)
+	c.Logf = o.Logf
+	return c, nil
+}
+
+// ReadLibGuides reads the export srcName (see ReadLibGuides) or when
+// Source is "api" assembles LibGuides from the API (see
+// AssembleLibGuides), ignoring srcName.
+func (o *Options) ReadLibGuides(srcName string) (*LibGuides, error) {
+	if !o.FromAPI() {
+		return ReadLibGuides(srcName)
+	}
+	c, err := o.APIClient()
+	if err != nil {
+		return nil, err
+	}
+	return AssembleLibGuides(c)
+}
+
+// sitePrefix returns the prefix used to build LibGuides links, the
+// site prefix in opts or the export's site domain. Returns an error if
+// neither is known.
+func (o *Options) sitePrefix(lg *LibGuides) (string, error) {
+	if o != nil && o.SitePrefix != "" {
+		return strings.TrimSuffix(o.SitePrefix, "/"), nil
+	}
+	if lg.Site != nil && lg.Site.Domain != "" {
+		return fmt.Sprintf("https://%s", lg.Site.Domain), nil
+	}
+	return "", fmt.Errorf("the export has no site domain, set site_prefix (or %sSITE_PREFIX)", EnvPrefix)
+}
+
+// reportURL removes any proxy prefix from u. Returns the URL and false
+// if the URL matches an ignore pattern.
+func (o *Options) reportURL(u string) (string, bool) {
+	if o == nil {
+		return u, true
+	}
+	for _, prefix := range o.ProxyPatterns {
+		if prefix != "" && strings.HasPrefix(u, prefix) {
+			u = strings.TrimPrefix(u, prefix)
+			if unescaped, err := url.QueryUnescape(u); err == nil {
+				u = unescaped
+			}
+			break
+		}
+	}
+	if o.ignore == nil && len(o.Ignore) > 0 {
+		// NOTE: Invalid patterns are reported by Validate
+		o.Validate()
+	}
+	for _, re := range o.ignore {
+		if re.MatchString(u) {
+			return u, false
+		}
+	}
+	return u, true
+}
+
+// linkCategory classifies a link applying the Intranet patterns.
+func (o *Options) linkCategory(u string) string {
+	if o == nil {
+		return ClassifyLink(u, nil)
+	}
+	if o.intranet == nil && len(o.Intranet) > 0 {
+		// NOTE: Invalid patterns are reported by Validate
+		o.Validate()
+	}
+	return ClassifyLink(u, o.intranet)
+}
+
+// ownerName formats an owner for reports, applying OwnerOverrides.
+func (o *Options) ownerName(owner Owner) string {
+	if o != nil && owner.Email != "" {
+		if name, ok := o.OwnerOverrides[strings.ToLower(strings.TrimSpace(owner.Email))]; ok {
+			return name
+		}
+	}
+	return ownerName(owner)
+}
+
+// Logf writes a message to standard error when Verbose is true.
+func (o *Options) Logf(format string, args ...interface{}) {
+	if o != nil && o.Verbose {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
+
+// WriteTable applies the table options and writes tbl to destName in
+// the options' format. Returns an error if any encountered.
+func (o *Options) WriteTable(tbl *Table, destName string) error {
+	if o == nil {
+		o = new(Options)
+	}
+	rptFmt, err := ReportFormat(o.Format, destName)
+	if err != nil {
+		return err
+	}
+	if tbl, err = o.TableOptions.Apply(tbl); err != nil {
+		return err
+	}
+	src, err := tbl.ToFormat(rptFmt)
+	if err != nil {
+		return err
+	}
+	if err = WriteDestinationWithOptions(destName, src, &o.WriteOptions); err != nil {
+		return err
+	}
+	o.Logf("wrote %d rows to %q", len(tbl.Body.Rows), destName)
+	return nil
+}
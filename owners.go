@@ -0,0 +1,343 @@
+// owners.go cross references guide and asset owners with the accounts of a
+// LibGuides export and suggests reassignments for departed staff.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Ownership issues reported by OwnershipTable
+const (
+	IssueMissingAccount = "missing account"
+	IssueUnusedAccount  = "unused account"
+	IssueMixedOwnership = "mixed ownership"
+)
+
+// guideAssets returns the assets of all the boxes and panes in a guide,
+// each asset once even when it is mapped into more than one box.
+func guideAssets(guide *Guide) []*Asset {
+	assets := []*Asset{}
+	seen := map[int]bool{}
+	for _, page := range guide.Pages {
+		for _, box := range page.Boxes {
+			boxAssets := append([]*Asset{}, box.Assets...)
+			for _, pane := range box.Panes {
+				boxAssets = append(boxAssets, pane.Assets...)
+			}
+			for _, asset := range boxAssets {
+				if seen[asset.Id] {
+					continue
+				}
+				seen[asset.Id] = true
+				assets = append(assets, asset)
+			}
+		}
+	}
+	return assets
+}
+
+// ownerKey identifies an owner by email address, or by account id when
+// the email is missing.
+func ownerKey(owner Owner) string {
+	if owner.Email != "" {
+		return strings.ToLower(strings.TrimSpace(owner.Email))
+	}
+	if owner.Id != 0 {
+		return fmt.Sprintf("id:%d", owner.Id)
+	}
+	return ""
+}
+
+// accountIndex finds accounts by id and email address.
+type accountIndex struct {
+	byId    map[int]*Account
+	byEmail map[string]*Account
+}
+
+func newAccountIndex(accounts []*Account) *accountIndex {
+	idx := &accountIndex{byId: map[int]*Account{}, byEmail: map[string]*Account{}}
+	for _, account := range accounts {
+		idx.byId[account.Id] = account
+		if account.Email != "" {
+			idx.byEmail[strings.ToLower(strings.TrimSpace(account.Email))] = account
+		}
+	}
+	return idx
+}
+
+// find returns the account for an owner or nil if not found.
+func (idx *accountIndex) find(owner Owner) *Account {
+	if owner.Email != "" {
+		if account, ok := idx.byEmail[strings.ToLower(strings.TrimSpace(owner.Email))]; ok {
+			return account
+		}
+	}
+	if owner.Id != 0 {
+		return idx.byId[owner.Id]
+	}
+	return nil
+}
+
+func accountOwner(account *Account) Owner {
+	return Owner{Id: account.Id, Email: account.Email, FirstName: account.FirstName, LastName: account.LastName}
+}
+
+// OwnershipTable cross references the owners of guides and assets with
+// the accounts in a LibGuides object. It lists guides and assets whose
+// owner is missing from the accounts ("missing account"), accounts that
+// own no guides or assets ("unused account") and assets owned by
+// someone other than the guide's owner ("mixed ownership"). The columns
+// are "Issue", "Object Type", "Id", "Name", "Guide Id", "Owner" and
+// "Guide Owner".
+func OwnershipTable(lg *LibGuides, caption string, opts *Options) *Table {
+	idx := newAccountIndex(lg.Accounts)
+	used := map[int]bool{}
+	tbl := new(Table)
+	tbl.SetCaption(caption)
+	tbl.AppendHeadings("Issue", "Object Type", "Id", "Name", "Guide Id", "Owner", "Guide Owner")
+	for _, guide := range lg.Guides {
+		guideOwner := opts.ownerName(guide.Owner)
+		if account := idx.find(guide.Owner); account != nil {
+			used[account.Id] = true
+		} else if ownerKey(guide.Owner) != "" {
+			tbl.AppendRow(IssueMissingAccount, "Guide", strInt(guide.Id), guide.Name, strInt(guide.Id), guideOwner, guideOwner)
+		}
+		for _, asset := range guideAssets(guide) {
+			if ownerKey(asset.Owner) == "" {
+				continue
+			}
+			owner := opts.ownerName(asset.Owner)
+			if account := idx.find(asset.Owner); account != nil {
+				used[account.Id] = true
+			} else {
+				tbl.AppendRow(IssueMissingAccount, "Asset", strInt(asset.Id), asset.Name, strInt(guide.Id), owner, guideOwner)
+			}
+			if ownerKey(asset.Owner) != ownerKey(guide.Owner) {
+				tbl.AppendRow(IssueMixedOwnership, "Asset", strInt(asset.Id), asset.Name, strInt(guide.Id), owner, guideOwner)
+			}
+		}
+	}
+	for _, account := range lg.Accounts {
+		if !used[account.Id] {
+			tbl.AppendRow(IssueUnusedAccount, "Account", strInt(account.Id), "", "", opts.ownerName(accountOwner(account)), "")
+		}
+	}
+	return tbl
+}
+
+// Departed maps the lower case email address of a departed staff member
+// to their successor's email address, which may be empty.
+type Departed map[string]string
+
+// ParseDeparted reads a CSV of departed staff. The first column is an
+// email address, an optional second column is the email address of a
+// successor. A header row is skipped when its first column doesn't
+// look like an email address.
+func ParseDeparted(src []byte) (Departed, error) {
+	r := csv.NewReader(bytes.NewReader(src))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	departed := Departed{}
+	for i := 0; ; i++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) == 0 {
+			continue
+		}
+		email := strings.ToLower(strings.TrimSpace(row[0]))
+		if email == "" || (i == 0 && !strings.Contains(email, "@")) {
+			continue
+		}
+		successor := ""
+		if len(row) > 1 {
+			successor = strings.TrimSpace(row[1])
+		}
+		departed[email] = successor
+	}
+	return departed, nil
+}
+
+// ReadDeparted reads a CSV of departed staff (see ParseDeparted) from a
+// file, "-" reads standard input.
+func ReadDeparted(srcName string) (Departed, error) {
+	src, err := ReadSource(srcName)
+	if err != nil {
+		return nil, err
+	}
+	departed, err := ParseDeparted(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s, %s", srcName, err)
+	}
+	return departed, nil
+}
+
+// Has reports if an owner is in the departed list.
+func (d Departed) Has(owner Owner) bool {
+	_, ok := d[ownerKey(owner)]
+	return ok
+}
+
+// mostOwned returns the name of the owner with the largest count,
+// breaking ties by name.
+func mostOwned(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] == counts[names[j]] {
+			return names[i] < names[j]
+		}
+		return counts[names[i]] > counts[names[j]]
+	})
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+// ReassignmentTable is a worksheet of the guides and assets owned by
+// departed staff with a suggested new owner. The suggestion is the
+// successor named in the departed list, else for a guide the current
+// account owning the most of its assets, else the current account
+// owning the most guides in the same group. An asset on a guide owned
+// by a current account suggests the guide's owner. The columns are
+// "Object Type", "Id", "Name", "Guide Id", "Guide Name", "Group",
+// "Current Owner", "Suggested Owner", "Reason" and "New Owner", the
+// last left blank to be filled in.
+func ReassignmentTable(lg *LibGuides, departed Departed, caption string, opts *Options) *Table {
+	idx := newAccountIndex(lg.Accounts)
+	active := func(owner Owner) bool {
+		return ownerKey(owner) != "" && idx.find(owner) != nil && !departed.Has(owner)
+	}
+	// Count the guides per group owned by current accounts
+	groupOwners := map[string]map[string]int{}
+	for _, guide := range lg.Guides {
+		if active(guide.Owner) {
+			if groupOwners[guide.Group.Name] == nil {
+				groupOwners[guide.Group.Name] = map[string]int{}
+			}
+			groupOwners[guide.Group.Name][opts.ownerName(guide.Owner)]++
+		}
+	}
+	successor := func(owner Owner) string {
+		email := departed[ownerKey(owner)]
+		if email == "" {
+			return ""
+		}
+		if account, ok := idx.byEmail[strings.ToLower(email)]; ok {
+			return opts.ownerName(accountOwner(account))
+		}
+		return opts.ownerName(Owner{Email: email})
+	}
+	tbl := new(Table)
+	tbl.SetCaption(caption)
+	tbl.AppendHeadings("Object Type", "Id", "Name", "Guide Id", "Guide Name", "Group", "Current Owner", "Suggested Owner", "Reason", "New Owner")
+	for _, guide := range lg.Guides {
+		assets := guideAssets(guide)
+		suggested, reason := "", ""
+		if departed.Has(guide.Owner) {
+			assetOwners := map[string]int{}
+			for _, asset := range assets {
+				if active(asset.Owner) {
+					assetOwners[opts.ownerName(asset.Owner)]++
+				}
+			}
+			if suggested = successor(guide.Owner); suggested != "" {
+				reason = "successor"
+			} else if suggested = mostOwned(assetOwners); suggested != "" {
+				reason = "owns most assets in guide"
+			} else if suggested = mostOwned(groupOwners[guide.Group.Name]); suggested != "" {
+				reason = "owns most guides in group"
+			} else {
+				reason = "no suggestion"
+			}
+			tbl.AppendRow("Guide", strInt(guide.Id), guide.Name, strInt(guide.Id), guide.Name, guide.Group.Name,
+				opts.ownerName(guide.Owner), suggested, reason, "")
+		}
+		for _, asset := range assets {
+			if !departed.Has(asset.Owner) {
+				continue
+			}
+			assetSuggested, assetReason := suggested, reason
+			if s := successor(asset.Owner); s != "" {
+				assetSuggested, assetReason = s, "successor"
+			} else if active(guide.Owner) {
+				assetSuggested, assetReason = opts.ownerName(guide.Owner), "guide owner"
+			} else if assetSuggested == "" {
+				assetReason = "no suggestion"
+			}
+			tbl.AppendRow("Asset", strInt(asset.Id), asset.Name, strInt(guide.Id), guide.Name, guide.Group.Name,
+				opts.ownerName(asset.Owner), assetSuggested, assetReason, "")
+		}
+	}
+	return tbl
+}
+
+// OwnershipReport reads a LibGuides export and writes the ownership
+// issues to destName. When opts.Departed names a CSV of departed staff
+// the reassignment worksheet is written instead.
+func OwnershipReport(srcName string, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	lg, err := opts.ReadLibGuides(srcName)
+	if err != nil {
+		return err
+	}
+	var tbl *Table
+	if opts.Departed != "" {
+		departed, err := ReadDeparted(opts.Departed)
+		if err != nil {
+			return err
+		}
+		opts.Logf("read %d departed staff from %s", len(departed), opts.Departed)
+		tbl = ReassignmentTable(lg, departed, fmt.Sprintf("Reassignments for %q", srcName), opts)
+	} else {
+		tbl = OwnershipTable(lg, fmt.Sprintf("Ownership of %q", srcName), opts)
+	}
+	opts.Logf("found %d rows", len(tbl.Body.Rows))
+	return opts.WriteTable(tbl, destName)
+}
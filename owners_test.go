@@ -0,0 +1,139 @@
+// owners_test.go tests the ownership and reassignment reports.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+)
+
+func ownersFixture() *LibGuides {
+	shrimps := Owner{Id: 1, Email: "shrimps@engineering.example.edu", FirstName: "Crusty", LastName: "Anthropod"}
+	whales := Owner{Id: 2, Email: "whales@telescopes.example.edu", FirstName: "Micro", LastName: "Nanometer"}
+	gone := Owner{Id: 9, Email: "gone@example.edu", FirstName: "Long", LastName: "Gone"}
+	return &LibGuides{
+		Accounts: []*Account{
+			{Id: 1, Email: "shrimps@engineering.example.edu", FirstName: "Crusty", LastName: "Anthropod"},
+			{Id: 2, Email: "whales@telescopes.example.edu", FirstName: "Micro", LastName: "Nanometer"},
+			{Id: 3, Email: "idle@example.edu", FirstName: "Idle", LastName: "Hands"},
+		},
+		Guides: []*Guide{
+			{Id: 1, Name: "Engineering", Group: Group{Name: "Science"}, Owner: shrimps, Pages: []*Page{
+				{Id: 10, Boxes: []*Box{{Id: 100, Assets: []*Asset{
+					{Id: 1000, Name: "Web of Science", Owner: shrimps},
+					{Id: 1001, Name: "Telescopes", Owner: whales},
+				}}}},
+			}},
+			{Id: 2, Name: "Old Guide", Group: Group{Name: "Science"}, Owner: gone, Pages: []*Page{
+				{Id: 20, Boxes: []*Box{{Id: 200, Assets: []*Asset{
+					{Id: 2000, Name: "Old Link", Owner: gone},
+					{Id: 2001, Name: "New Link", Owner: whales},
+				}}, {Id: 201, Assets: []*Asset{
+					{Id: 2000, Name: "Old Link", Owner: gone},
+					{Id: 2001, Name: "New Link", Owner: whales},
+				}}}},
+			}},
+			{Id: 3, Name: "Orphan", Group: Group{Name: "Science"}, Owner: gone},
+		},
+	}
+}
+
+func TestOwnershipTable(t *testing.T) {
+	lg := ownersFixture()
+	tbl := OwnershipTable(lg, "owners", nil)
+	rows := map[string]int{}
+	for _, row := range tbl.Body.Rows {
+		rows[row[0]+":"+row[1]+":"+row[2]]++
+	}
+	for _, key := range []string{
+		"missing account:Guide:2",
+		"missing account:Guide:3",
+		"missing account:Asset:2000",
+		"mixed ownership:Asset:1001",
+		"mixed ownership:Asset:2001",
+		"unused account:Account:3",
+	} {
+		if rows[key] != 1 {
+			t.Errorf("expected one %q row, got %d", key, rows[key])
+		}
+	}
+	expectedInt(t, 6, len(tbl.Body.Rows))
+}
+
+func TestReassignmentTable(t *testing.T) {
+	lg := ownersFixture()
+	departed, err := ParseDeparted([]byte("Email,Successor\nGone@example.edu\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 1, len(departed))
+	tbl := ReassignmentTable(lg, departed, "reassign", nil)
+	expectedInt(t, 3, len(tbl.Body.Rows))
+	expectedString(t, "Guide,2,Old Guide,2,Old Guide,Science,Long Gone <gone@example.edu>,Micro Nanometer <whales@telescopes.example.edu>,owns most assets in guide,",
+		joinRow(tbl.Body.Rows[0]))
+	expectedString(t, "Asset,2000", joinRow(tbl.Body.Rows[1][0:2]))
+	expectedString(t, "Micro Nanometer <whales@telescopes.example.edu>", tbl.Body.Rows[1][7])
+	expectedString(t, "Guide,3", joinRow(tbl.Body.Rows[2][0:2]))
+	expectedString(t, "Crusty Anthropod <shrimps@engineering.example.edu>", tbl.Body.Rows[2][7])
+	expectedString(t, "owns most guides in group", tbl.Body.Rows[2][8])
+
+	// A successor takes precedence
+	departed, err = ParseDeparted([]byte("gone@example.edu, idle@example.edu\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl = ReassignmentTable(lg, departed, "reassign", nil)
+	for _, row := range tbl.Body.Rows {
+		expectedString(t, "Idle Hands <idle@example.edu>", row[7])
+		expectedString(t, "successor", row[8])
+	}
+}
+
+func TestOwnershipReport(t *testing.T) {
+	srcName := path.Join("testinput", "LibGuides_export_XXXXX.xml")
+	departedName := path.Join("testout", "departed.csv")
+	if err := ioutil.WriteFile(departedName, []byte("email\nshrimps@engineering.example.edu\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := OwnershipReport(srcName, path.Join("testout", "owners.csv"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := OwnershipReport(srcName, path.Join("testout", "reassign.csv"), &Options{Departed: departedName}); err != nil {
+		t.Fatal(err)
+	}
+	if err := OwnershipReport(srcName, path.Join("testout", "reassign.csv"), &Options{Departed: path.Join("testout", "no-such-file.csv")}); err == nil {
+		t.Errorf("expected an error for a missing departed file")
+	}
+}
@@ -0,0 +1,706 @@
+// rdf.go serializes a LibGuides object as RDF (Turtle and N-Triples) using
+// FOAF, SKOS and Dublin Core terms, and reads N-Triples back.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Namespaces of the vocabularies used by the RDF export
+const (
+	RDFNamespace      = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	XSDNamespace      = "http://www.w3.org/2001/XMLSchema#"
+	FOAFNamespace     = "http://xmlns.com/foaf/0.1/"
+	SKOSNamespace     = "http://www.w3.org/2004/02/skos/core#"
+	DCTermsNamespace  = "http://purl.org/dc/terms/"
+	DCMITypeNamespace = "http://purl.org/dc/dcmitype/"
+)
+
+// RDFTerm is an IRI, a blank node or a literal with an optional
+// datatype IRI or language tag.
+type RDFTerm struct {
+	Value    string
+	Literal  bool
+	Blank    bool
+	Datatype string
+	Lang     string
+}
+
+func rdfIRI(iri string) RDFTerm {
+	return RDFTerm{Value: iri}
+}
+
+func rdfLiteral(s string) RDFTerm {
+	return RDFTerm{Value: s, Literal: true}
+}
+
+func rdfTyped(s string, datatype string) RDFTerm {
+	return RDFTerm{Value: s, Literal: true, Datatype: datatype}
+}
+
+// Triple is an RDF statement.
+type Triple struct {
+	Subject   RDFTerm
+	Predicate RDFTerm
+	Object    RDFTerm
+}
+
+// rdfEscapeIRI percent encodes the characters not allowed in an IRI
+// of N-Triples or Turtle.
+func rdfEscapeIRI(iri string) string {
+	sb := new(strings.Builder)
+	for i := 0; i < len(iri); i++ {
+		c := iri[i]
+		if c <= ' ' || strings.IndexByte("<>\"{}|^`\\", c) >= 0 {
+			fmt.Fprintf(sb, "%%%02X", c)
+		} else {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// rdfEscapeLiteral escapes a string for a quoted N-Triples or Turtle
+// literal.
+func rdfEscapeLiteral(s string) string {
+	s = strings.ToValidUTF8(s, "�")
+	sb := new(strings.Builder)
+	for _, r := range s {
+		switch r {
+		case '\\':
+			sb.WriteString(`\\`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if r < ' ' || r == 0x7f {
+				fmt.Fprintf(sb, `\u%04X`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// String renders the term as in N-Triples.
+func (t RDFTerm) String() string {
+	switch {
+	case t.Blank:
+		return "_:" + t.Value
+	case !t.Literal:
+		return "<" + rdfEscapeIRI(t.Value) + ">"
+	case t.Lang != "":
+		return fmt.Sprintf(`"%s"@%s`, rdfEscapeLiteral(t.Value), t.Lang)
+	case t.Datatype != "":
+		return fmt.Sprintf(`"%s"^^<%s>`, rdfEscapeLiteral(t.Value), rdfEscapeIRI(t.Datatype))
+	}
+	return fmt.Sprintf(`"%s"`, rdfEscapeLiteral(t.Value))
+}
+
+// String renders the triple as an N-Triples line without the newline.
+func (t *Triple) String() string {
+	return fmt.Sprintf("%s %s %s .", t.Subject, t.Predicate, t.Object)
+}
+
+// RDFGraph is a set of triples kept in the order added.
+type RDFGraph struct {
+	// Base is the IRI the ids are minted under, e.g.
+	// "https://libguides.example.edu/id/"
+	Base    string
+	Triples []*Triple
+
+	seen map[string]bool
+}
+
+// Add adds a triple unless already in the graph.
+func (g *RDFGraph) Add(subject string, predicate string, object RDFTerm) {
+	t := &Triple{Subject: rdfIRI(subject), Predicate: rdfIRI(predicate), Object: object}
+	key := t.String()
+	if g.seen == nil {
+		g.seen = map[string]bool{}
+	}
+	if !g.seen[key] {
+		g.seen[key] = true
+		g.Triples = append(g.Triples, t)
+	}
+}
+
+// addLiteral adds a triple with a literal object unless s is empty.
+func (g *RDFGraph) addLiteral(subject string, predicate string, s string) {
+	if s = strings.TrimSpace(s); s != "" {
+		g.Add(subject, predicate, rdfLiteral(s))
+	}
+}
+
+// addDate adds a triple with an xsd:dateTime object for a valid
+// LibGuides timestamp.
+func (g *RDFGraph) addDate(subject string, predicate string, ts string) {
+	if t, ok := ParseTimestamp(ts); ok {
+		g.Add(subject, predicate, rdfTyped(t.Format("2006-01-02T15:04:05"), XSDNamespace+"dateTime"))
+	}
+}
+
+// addLink adds a triple with an IRI object if link is an absolute URL.
+func (g *RDFGraph) addLink(subject string, predicate string, link string) {
+	link = strings.TrimSpace(link)
+	if u, err := url.Parse(link); err == nil && u.Scheme != "" && (u.Host != "" || u.Opaque != "") {
+		g.Add(subject, predicate, rdfIRI(link))
+	}
+}
+
+// ID returns the IRI of an object, e.g. ID("guide", 512671) is
+// Base + "guide/512671".
+func (g *RDFGraph) ID(kind string, id int) string {
+	return fmt.Sprintf("%s%s/%d", g.Base, kind, id)
+}
+
+// NewRDFGraph describes a LibGuides object in RDF. IRIs are minted
+// from the site's domain (or opts.SitePrefix) and the ids, e.g.
+// https://libguides.example.edu/id/guide/512671. Accounts and owners
+// are foaf:Persons, subjects and tags skos:Concepts in the schemes
+// .../id/subjects and .../id/tags, guides, pages, boxes and assets are
+// described with Dublin Core terms, nested with dcterms:hasPart and
+// dcterms:isPartOf. Assets reference (dcterms:references) the URL they
+// link to and the links in their descriptions. Hidden pages and boxes
+// are included according to the hidden policy in opts. Returns an
+// error if neither the site's domain nor opts.SitePrefix is known.
+func NewRDFGraph(lg *LibGuides, opts *Options) (*RDFGraph, error) {
+	hidden := HiddenSkip
+	if opts != nil {
+		hidden = opts.Hidden
+	}
+	prefix, err := opts.sitePrefix(lg)
+	if err != nil {
+		return nil, err
+	}
+	g := &RDFGraph{Base: prefix + "/id/"}
+	const (
+		rdfType    = RDFNamespace + "type"
+		title      = DCTermsNamespace + "title"
+		isPartOf   = DCTermsNamespace + "isPartOf"
+		hasPart    = DCTermsNamespace + "hasPart"
+		identifier = DCTermsNamespace + "identifier"
+	)
+	siteId, customerId := g.Base+"site", g.Base+"customer"
+	subjectScheme, tagScheme := g.Base+"subjects", g.Base+"tags"
+
+	if lg.Customer != nil && (lg.Customer.Name != "" || lg.Customer.Url != "") {
+		g.Add(customerId, rdfType, rdfIRI(FOAFNamespace+"Organization"))
+		g.addLiteral(customerId, FOAFNamespace+"name", lg.Customer.Name)
+		g.addLink(customerId, FOAFNamespace+"homepage", lg.Customer.Url)
+	}
+	g.Add(siteId, rdfType, rdfIRI(DCMITypeNamespace+"Collection"))
+	if lg.Site != nil {
+		g.addLiteral(siteId, title, lg.Site.Name)
+		g.addLink(siteId, FOAFNamespace+"homepage", prefix)
+		g.addDate(siteId, DCTermsNamespace+"created", lg.Site.Created)
+		g.addDate(siteId, DCTermsNamespace+"modified", lg.Site.Updated)
+	}
+	if lg.Customer != nil && (lg.Customer.Name != "" || lg.Customer.Url != "") {
+		g.Add(siteId, DCTermsNamespace+"publisher", rdfIRI(customerId))
+	}
+
+	person := func(id string, email, firstName, lastName string) {
+		g.Add(id, rdfType, rdfIRI(FOAFNamespace+"Person"))
+		g.addLiteral(id, FOAFNamespace+"name", strings.TrimSpace(firstName+" "+lastName))
+		g.addLiteral(id, FOAFNamespace+"givenName", firstName)
+		g.addLiteral(id, FOAFNamespace+"familyName", lastName)
+		if email = strings.TrimSpace(email); email != "" {
+			g.Add(id, FOAFNamespace+"mbox", rdfIRI("mailto:"+email))
+		}
+	}
+	accounts := map[int]bool{}
+	for _, account := range lg.Accounts {
+		id := g.ID("account", account.Id)
+		accounts[account.Id] = true
+		person(id, account.Email, account.FirstName, account.LastName)
+		g.addLiteral(id, FOAFNamespace+"nick", account.Nickname)
+		g.addLink(id, FOAFNamespace+"homepage", account.Website)
+		g.addLink(id, FOAFNamespace+"img", account.Image)
+		g.addLiteral(id, FOAFNamespace+"phone", account.Phone)
+	}
+	// owner returns the IRI of an owner, describing owners missing
+	// from the accounts
+	owner := func(o Owner) string {
+		if o.Id == 0 {
+			return ""
+		}
+		id := g.ID("account", o.Id)
+		if !accounts[o.Id] {
+			person(id, o.Email, o.FirstName, o.LastName)
+		}
+		return id
+	}
+	for _, group := range lg.Groups {
+		id := g.ID("group", group.Id)
+		g.Add(id, rdfType, rdfIRI(FOAFNamespace+"Group"))
+		g.addLiteral(id, FOAFNamespace+"name", group.Name)
+		g.addLink(id, FOAFNamespace+"page", group.Url)
+		g.Add(id, isPartOf, rdfIRI(siteId))
+	}
+	g.Add(subjectScheme, rdfType, rdfIRI(SKOSNamespace+"ConceptScheme"))
+	g.addLiteral(subjectScheme, title, "Subjects")
+	g.Add(tagScheme, rdfType, rdfIRI(SKOSNamespace+"ConceptScheme"))
+	g.addLiteral(tagScheme, title, "Tags")
+	concept := func(id string, scheme string, label string) {
+		g.Add(id, rdfType, rdfIRI(SKOSNamespace+"Concept"))
+		g.addLiteral(id, SKOSNamespace+"prefLabel", label)
+		g.Add(id, SKOSNamespace+"inScheme", rdfIRI(scheme))
+	}
+	for _, subject := range lg.Subjects {
+		id := g.ID("subject", subject.Id)
+		concept(id, subjectScheme, subject.Name)
+		g.addLink(id, FOAFNamespace+"page", subject.Url)
+	}
+	for _, tag := range lg.Tags {
+		concept(g.ID("tag", tag.Id), tagScheme, tag.Name)
+	}
+	for _, vendor := range lg.Vendors {
+		id := g.ID("vendor", vendor.Id)
+		g.Add(id, rdfType, rdfIRI(FOAFNamespace+"Organization"))
+		g.addLiteral(id, FOAFNamespace+"name", vendor.Name)
+	}
+
+	describe := func(id string, kind string, name string, description string, created string, modified string) {
+		g.Add(id, rdfType, rdfIRI(DCMITypeNamespace+kind))
+		g.addLiteral(id, title, name)
+		g.addLiteral(id, DCTermsNamespace+"description", descriptionText(description))
+		g.addDate(id, DCTermsNamespace+"created", created)
+		g.addDate(id, DCTermsNamespace+"modified", modified)
+	}
+	addAsset := func(boxId string, asset *Asset) {
+		id := g.ID("asset", asset.Id)
+		describe(id, "Text", asset.Name, asset.Description, asset.Created, asset.Updated)
+		g.Add(boxId, hasPart, rdfIRI(id))
+		g.Add(id, isPartOf, rdfIRI(boxId))
+		g.Add(id, identifier, rdfLiteral(strInt(asset.Id)))
+		g.addLiteral(id, DCTermsNamespace+"type", asset.Type)
+		if creator := owner(asset.Owner); creator != "" {
+			g.Add(id, DCTermsNamespace+"creator", rdfIRI(creator))
+		}
+		g.addLink(id, DCTermsNamespace+"references", asset.Url)
+		links, _ := ExtractHTTPLinks(decodeDescription(asset.Description))
+		for _, link := range links {
+			g.addLink(id, DCTermsNamespace+"references", link)
+		}
+	}
+	for _, guide := range lg.Guides {
+		id := g.ID("guide", guide.Id)
+		describe(id, "Collection", guide.Name, guide.Description, guide.Created, latestTimestamp(guide.Modified, guide.Updated))
+		g.Add(id, identifier, rdfLiteral(strInt(guide.Id)))
+		g.addLiteral(id, DCTermsNamespace+"type", guide.Type)
+		g.addDate(id, DCTermsNamespace+"issued", guide.Published)
+		g.addLink(id, FOAFNamespace+"page", guide.Url)
+		g.Add(id, isPartOf, rdfIRI(siteId))
+		if guide.Group.Id != 0 {
+			g.Add(id, isPartOf, rdfIRI(g.ID("group", guide.Group.Id)))
+		}
+		if creator := owner(guide.Owner); creator != "" {
+			g.Add(id, DCTermsNamespace+"creator", rdfIRI(creator))
+		}
+		for _, subject := range guide.Subjects {
+			g.Add(id, DCTermsNamespace+"subject", rdfIRI(g.ID("subject", subject.Id)))
+		}
+		for _, tag := range guide.Tags {
+			g.Add(id, DCTermsNamespace+"subject", rdfIRI(g.ID("tag", tag.Id)))
+		}
+		pageIds := map[int]bool{}
+		for _, page := range guide.Pages {
+			pageIds[page.Id] = true
+		}
+		for _, page := range guide.Pages {
+			if !hidden.Allows(page.Hidden != 0) {
+				continue
+			}
+			pageId := g.ID("page", page.Id)
+			parent := id
+			if page.ParentPageId != 0 && page.ParentPageId != page.Id && pageIds[page.ParentPageId] {
+				parent = g.ID("page", page.ParentPageId)
+			}
+			describe(pageId, "Text", page.Name, page.Description, page.Created, latestTimestamp(page.Modified, page.Updated))
+			g.Add(parent, hasPart, rdfIRI(pageId))
+			g.Add(pageId, isPartOf, rdfIRI(parent))
+			g.Add(pageId, identifier, rdfLiteral(strInt(page.Id)))
+			g.addLink(pageId, FOAFNamespace+"page", page.Url)
+			if page.SourcePageId != 0 && page.SourcePageId != page.Id {
+				g.Add(pageId, DCTermsNamespace+"source", rdfIRI(g.ID("page", page.SourcePageId)))
+			}
+			for _, box := range page.Boxes {
+				if !hidden.Allows(page.Hidden != 0 || box.Hidden != 0) {
+					continue
+				}
+				boxId := g.ID("box", box.Id)
+				describe(boxId, "Collection", box.Name, "", box.Created, box.Updated)
+				g.Add(pageId, hasPart, rdfIRI(boxId))
+				g.Add(boxId, isPartOf, rdfIRI(pageId))
+				g.Add(boxId, identifier, rdfLiteral(strInt(box.Id)))
+				g.addLiteral(boxId, DCTermsNamespace+"type", box.Type)
+				for _, asset := range box.Assets {
+					addAsset(boxId, asset)
+				}
+				for _, pane := range box.Panes {
+					for _, asset := range pane.Assets {
+						addAsset(boxId, asset)
+					}
+				}
+			}
+		}
+	}
+	return g, nil
+}
+
+// ToNTriples renders the graph as N-Triples.
+func (g *RDFGraph) ToNTriples() []byte {
+	buf := new(bytes.Buffer)
+	for _, t := range g.Triples {
+		buf.WriteString(t.String())
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// reTurtleLocal matches the local names written with a prefix in
+// Turtle, others are written as full IRIs.
+var reTurtleLocal = regexp.MustCompile(`^[A-Za-z0-9_]([A-Za-z0-9_.-]*[A-Za-z0-9_-])?$`)
+
+// turtlePrefixes returns the prefixes and namespaces used in Turtle,
+// the ids under Base get a prefix per kind, e.g. guide:512671.
+func (g *RDFGraph) turtlePrefixes() [][2]string {
+	prefixes := [][2]string{
+		{"rdf", RDFNamespace}, {"xsd", XSDNamespace}, {"foaf", FOAFNamespace},
+		{"skos", SKOSNamespace}, {"dcterms", DCTermsNamespace}, {"dcmitype", DCMITypeNamespace},
+		{"lg", g.Base},
+	}
+	for _, kind := range []string{"account", "group", "subject", "tag", "vendor", "guide", "page", "box", "asset"} {
+		prefixes = append(prefixes, [2]string{kind, g.Base + kind + "/"})
+	}
+	return prefixes
+}
+
+// turtleTerm renders a term in Turtle, using a prefix when one fits.
+func turtleTerm(t RDFTerm, prefixes [][2]string) string {
+	if t.Literal && t.Lang == "" && t.Datatype != "" {
+		return fmt.Sprintf(`"%s"^^%s`, rdfEscapeLiteral(t.Value), turtleTerm(rdfIRI(t.Datatype), prefixes))
+	}
+	if t.Literal || t.Blank {
+		return t.String()
+	}
+	best, name := "", ""
+	for _, p := range prefixes {
+		local := strings.TrimPrefix(t.Value, p[1])
+		if len(p[1]) > len(best) && strings.HasPrefix(t.Value, p[1]) && reTurtleLocal.MatchString(local) {
+			best, name = p[1], p[0]+":"+local
+		}
+	}
+	if name != "" {
+		return name
+	}
+	return t.String()
+}
+
+// ToTurtle renders the graph as Turtle, grouping the triples by
+// subject in the order the subjects were added.
+func (g *RDFGraph) ToTurtle() []byte {
+	prefixes := g.turtlePrefixes()
+	subjects := []string{}
+	bySubject := map[string][]*Triple{}
+	for _, t := range g.Triples {
+		key := t.Subject.String()
+		if _, ok := bySubject[key]; !ok {
+			subjects = append(subjects, key)
+		}
+		bySubject[key] = append(bySubject[key], t)
+	}
+	buf := new(bytes.Buffer)
+	for _, p := range prefixes {
+		fmt.Fprintf(buf, "@prefix %s: <%s> .\n", p[0], rdfEscapeIRI(p[1]))
+	}
+	for _, key := range subjects {
+		triples := bySubject[key]
+		fmt.Fprintf(buf, "\n%s", turtleTerm(triples[0].Subject, prefixes))
+		predicate := ""
+		for i, t := range triples {
+			p := turtleTerm(t.Predicate, prefixes)
+			if t.Predicate.Value == RDFNamespace+"type" {
+				p = "a"
+			}
+			switch {
+			case i == 0:
+				fmt.Fprintf(buf, " %s %s", p, turtleTerm(t.Object, prefixes))
+			case p == predicate:
+				fmt.Fprintf(buf, ",\n        %s", turtleTerm(t.Object, prefixes))
+			default:
+				fmt.Fprintf(buf, " ;\n    %s %s", p, turtleTerm(t.Object, prefixes))
+			}
+			predicate = p
+		}
+		buf.WriteString(" .\n")
+	}
+	return buf.Bytes()
+}
+
+// ntriplesParser reads the terms of an N-Triples line.
+type ntriplesParser struct {
+	line string
+	pos  int
+}
+
+func (p *ntriplesParser) skipSpace() {
+	for p.pos < len(p.line) && (p.line[p.pos] == ' ' || p.line[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// unescape reads the escape sequence at pos (after the backslash).
+func (p *ntriplesParser) unescape(sb *strings.Builder) error {
+	if p.pos >= len(p.line) {
+		return fmt.Errorf("unterminated escape")
+	}
+	c := p.line[p.pos]
+	p.pos++
+	switch c {
+	case 't':
+		sb.WriteByte('\t')
+	case 'b':
+		sb.WriteByte('\b')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'r':
+		sb.WriteByte('\r')
+	case 'f':
+		sb.WriteByte('\f')
+	case '"', '\'', '\\':
+		sb.WriteByte(c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.line) {
+			return fmt.Errorf("short \\%c escape", c)
+		}
+		r, err := strconv.ParseUint(p.line[p.pos:p.pos+n], 16, 32)
+		if err != nil {
+			return fmt.Errorf("invalid \\%c escape", c)
+		}
+		sb.WriteRune(rune(r))
+		p.pos += n
+	default:
+		return fmt.Errorf("unknown escape \\%c", c)
+	}
+	return nil
+}
+
+// iri reads an <IRI>.
+func (p *ntriplesParser) iri() (string, error) {
+	if p.pos >= len(p.line) || p.line[p.pos] != '<' {
+		return "", fmt.Errorf("expected an IRI at %d", p.pos+1)
+	}
+	p.pos++
+	sb := new(strings.Builder)
+	for p.pos < len(p.line) {
+		c := p.line[p.pos]
+		p.pos++
+		switch c {
+		case '>':
+			return sb.String(), nil
+		case '\\':
+			if err := p.unescape(sb); err != nil {
+				return "", err
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated IRI")
+}
+
+// term reads an IRI, a blank node or, if literal is true, a literal.
+func (p *ntriplesParser) term(literal bool) (RDFTerm, error) {
+	p.skipSpace()
+	if p.pos >= len(p.line) {
+		return RDFTerm{}, fmt.Errorf("missing term")
+	}
+	switch {
+	case p.line[p.pos] == '<':
+		iri, err := p.iri()
+		return rdfIRI(iri), err
+	case strings.HasPrefix(p.line[p.pos:], "_:"):
+		start := p.pos + 2
+		p.pos = start
+		for p.pos < len(p.line) && p.line[p.pos] != ' ' && p.line[p.pos] != '\t' {
+			p.pos++
+		}
+		label := strings.TrimSuffix(p.line[start:p.pos], ".")
+		if label == "" {
+			return RDFTerm{}, fmt.Errorf("empty blank node label")
+		}
+		p.pos = start + len(label)
+		return RDFTerm{Value: label, Blank: true}, nil
+	case literal && p.line[p.pos] == '"':
+		p.pos++
+		sb := new(strings.Builder)
+		for {
+			if p.pos >= len(p.line) {
+				return RDFTerm{}, fmt.Errorf("unterminated literal")
+			}
+			c := p.line[p.pos]
+			p.pos++
+			if c == '"' {
+				break
+			}
+			if c == '\\' {
+				if err := p.unescape(sb); err != nil {
+					return RDFTerm{}, err
+				}
+				continue
+			}
+			sb.WriteByte(c)
+		}
+		t := rdfLiteral(sb.String())
+		if !utf8.ValidString(t.Value) {
+			return RDFTerm{}, fmt.Errorf("literal is not valid UTF-8")
+		}
+		switch {
+		case strings.HasPrefix(p.line[p.pos:], "^^"):
+			p.pos += 2
+			datatype, err := p.iri()
+			if err != nil {
+				return RDFTerm{}, err
+			}
+			t.Datatype = datatype
+		case strings.HasPrefix(p.line[p.pos:], "@"):
+			start := p.pos + 1
+			p.pos = start
+			for p.pos < len(p.line) && (p.line[p.pos] == '-' || (p.line[p.pos] >= 'a' && p.line[p.pos] <= 'z') ||
+				(p.line[p.pos] >= 'A' && p.line[p.pos] <= 'Z') || (p.line[p.pos] >= '0' && p.line[p.pos] <= '9')) {
+				p.pos++
+			}
+			t.Lang = p.line[start:p.pos]
+		}
+		return t, nil
+	}
+	return RDFTerm{}, fmt.Errorf("unexpected %q at %d", p.line[p.pos], p.pos+1)
+}
+
+// ParseNTriples reads N-Triples, skipping blank lines and comments.
+func ParseNTriples(src []byte) ([]*Triple, error) {
+	triples := []*Triple{}
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	scanner.Buffer(make([]byte, 64*1024), len(src)+1)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p := &ntriplesParser{line: line}
+		t := new(Triple)
+		var err error
+		if t.Subject, err = p.term(false); err == nil {
+			if t.Predicate, err = p.term(false); err == nil && t.Predicate.Blank {
+				err = fmt.Errorf("a predicate can't be a blank node")
+			}
+		}
+		if err == nil {
+			t.Object, err = p.term(true)
+		}
+		if err == nil {
+			p.skipSpace()
+			if rest := strings.TrimSpace(p.line[p.pos:]); rest != "." && !strings.HasPrefix(rest, ". #") && !strings.HasPrefix(rest, ".#") {
+				err = fmt.Errorf("expected \".\" at %d", p.pos+1)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d, %s", lineNo, err)
+		}
+		triples = append(triples, t)
+	}
+	return triples, scanner.Err()
+}
+
+// isNTriplesFormat returns true if the format (or destName's extension
+// when format is empty) asks for N-Triples rather than Turtle.
+func isNTriplesFormat(format string, destName string) (bool, error) {
+	name := formatName(format, destName)
+	switch name {
+	case "nt", "ntriples", "n-triples":
+		return true, nil
+	case "", "ttl", "turtle":
+		return false, nil
+	}
+	return false, fmt.Errorf("unsupported RDF format %q, expected turtle or ntriples", name)
+}
+
+// RDFReport reads a LibGuides export and writes it to destName as
+// Turtle or, when the format is "ntriples" (or destName ends in
+// ".nt"), N-Triples (see NewRDFGraph).
+func RDFReport(srcName string, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	ntriples, err := isNTriplesFormat(opts.Format, destName)
+	if err != nil {
+		return err
+	}
+	lg, err := opts.ReadLibGuides(srcName)
+	if err != nil {
+		return err
+	}
+	g, err := NewRDFGraph(lg, opts)
+	if err != nil {
+		return err
+	}
+	opts.Logf("described %d guides in %d triples", len(lg.Guides), len(g.Triples))
+	if ntriples {
+		return WriteDestinationWithOptions(destName, g.ToNTriples(), &opts.WriteOptions)
+	}
+	return WriteDestinationWithOptions(destName, g.ToTurtle(), &opts.WriteOptions)
+}
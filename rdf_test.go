@@ -0,0 +1,187 @@
+// rdf_test.go provides tests for rdf.go
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestRDFRoundTrip(t *testing.T) {
+	lg, err := ReadLibGuides(filepath.Join("testinput", "api", "LibGuides_export_api.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Literals needing escapes and a link which must be encoded
+	lg.Guides[0].Name = "Line one\nLine \"two\"\t\\ and \x01"
+	lg.Guides[0].Pages[0].Boxes[0].Assets[0].Url = "https://example.edu/a b<c>"
+	g, err := NewRDFGraph(lg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, "https://libguides.example.edu/id/", g.Base)
+	expectedString(t, "https://libguides.example.edu/id/guide/512671", g.ID("guide", 512671))
+
+	triples, err := ParseNTriples(g.ToNTriples())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, len(g.Triples), len(triples))
+	expected := map[string]bool{}
+	for _, triple := range g.Triples {
+		expected[triple.String()] = true
+	}
+	for _, triple := range triples {
+		if !expected[triple.String()] {
+			t.Errorf("unexpected triple %s", triple)
+		}
+	}
+	found := map[string]bool{}
+	for _, triple := range triples {
+		found[triple.Subject.Value+" "+triple.Predicate.Value+" "+triple.Object.Value] = true
+	}
+	for _, s := range []string{
+		g.Base + "account/1001 " + RDFNamespace + "type " + FOAFNamespace + "Person",
+		g.Base + "account/1001 " + FOAFNamespace + "mbox mailto:jdoe@example.edu",
+		g.Base + "subject/72 " + SKOSNamespace + "prefLabel Engineering",
+		g.Base + "tag/502 " + SKOSNamespace + "inScheme " + g.Base + "tags",
+		g.Base + "guide/512671 " + DCTermsNamespace + "subject " + g.Base + "tag/502",
+		g.Base + "guide/512671 " + DCTermsNamespace + "creator " + g.Base + "account/1001",
+		g.Base + "guide/512671 " + DCTermsNamespace + "title Line one\nLine \"two\"\t\\ and \x01",
+		g.Base + "guide/512671 " + DCTermsNamespace + "issued 2016-09-20T08:00:00",
+		g.Base + "page/3502869 " + DCTermsNamespace + "isPartOf " + g.Base + "page/3502868",
+		g.Base + "page/3600001 " + DCTermsNamespace + "source " + g.Base + "page/3502868",
+		g.Base + "asset/21001 " + DCTermsNamespace + "references https://example.edu/a%20b%3Cc%3E",
+		g.Base + "asset/21004 " + DCTermsNamespace + "references https://patents.google.com/",
+	} {
+		if !found[s] {
+			t.Errorf("expected triple %q", s)
+		}
+	}
+	// Hidden pages are left out by default
+	if found[g.Base+"page/3502870 "+RDFNamespace+"type "+DCMITypeNamespace+"Text"] {
+		t.Errorf("expected the hidden page to be left out")
+	}
+	g, err = NewRDFGraph(lg, &Options{Hidden: HiddenInclude, SitePrefix: "https://guides.example.edu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, "https://guides.example.edu/id/page/3502870", g.ID("page", 3502870))
+	triples, _ = ParseNTriples(g.ToNTriples())
+	hidden := false
+	for _, triple := range triples {
+		hidden = hidden || triple.Subject.Value == g.ID("page", 3502870)
+	}
+	if !hidden {
+		t.Errorf("expected the hidden page with -hidden include")
+	}
+	// Without a site domain or prefix there is nothing to mint IRIs from
+	lg.Site = nil
+	if _, err := NewRDFGraph(lg, nil); err == nil {
+		t.Errorf("expected an error without a site domain or prefix")
+	}
+}
+
+func TestParseNTriples(t *testing.T) {
+	src := []byte(`# a comment
+<http://example.org/s> <http://example.org/p> "café"@fr .
+
+_:b1 <http://example.org/p> "42"^^<http://www.w3.org/2001/XMLSchema#integer> . # trailing
+<http://example.org/s> <http://example.org/p> _:b1 .
+`)
+	triples, err := ParseNTriples(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 3, len(triples))
+	if len(triples) != 3 {
+		t.FailNow()
+	}
+	expectedString(t, "café", triples[0].Object.Value)
+	expectedString(t, "fr", triples[0].Object.Lang)
+	expectedString(t, "b1", triples[1].Subject.Value)
+	expectedString(t, XSDNamespace+"integer", triples[1].Object.Datatype)
+	if !triples[2].Object.Blank {
+		t.Errorf("expected a blank node object")
+	}
+	for _, bad := range []string{
+		`<http://example.org/s> <http://example.org/p> "open .`,
+		`<http://example.org/s> "p" "o" .`,
+		`<http://example.org/s> <http://example.org/p> <http://example.org/o>`,
+		`<http://example.org/s> _:p <http://example.org/o> .`,
+	} {
+		if _, err := ParseNTriples([]byte(bad)); err == nil {
+			t.Errorf("expected an error for %s", bad)
+		}
+	}
+}
+
+func TestRDFReport(t *testing.T) {
+	srcName := filepath.Join("testinput", "api", "LibGuides_export_api.xml")
+	destName := filepath.Join("testout", "libguides.ttl")
+	if err := RDFReport(srcName, destName, nil); err != nil {
+		t.Fatal(err)
+	}
+	src, err := ioutil.ReadFile(destName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"@prefix foaf: <http://xmlns.com/foaf/0.1/> .\n",
+		"@prefix guide: <https://libguides.example.edu/id/guide/> .\n",
+		"\nguide:512671 a dcmitype:Collection ;\n",
+		"    dcterms:subject subject:72,\n        tag:502,\n",
+		`"2016-09-20T08:00:00"^^xsd:dateTime`,
+	} {
+		if !bytes.Contains(src, []byte(s)) {
+			t.Errorf("expected %q in %s", s, destName)
+		}
+	}
+	destName = filepath.Join("testout", "libguides.nt")
+	if err := RDFReport(srcName, destName, nil); err != nil {
+		t.Fatal(err)
+	}
+	src, err = ioutil.ReadFile(destName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseNTriples(src); err != nil {
+		t.Error(err)
+	}
+	if err := RDFReport(srcName, destName, &Options{Format: "rdfxml"}); err == nil {
+		t.Errorf("expected an error for an unsupported format")
+	}
+}
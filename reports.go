@@ -35,9 +35,7 @@
 package springytools
 
 import (
-	"encoding/xml"
 	"fmt"
-	"io/ioutil"
 )
 
 func strInt(i int) string {
@@ -46,41 +44,18 @@ func strInt(i int) string {
 
 // LibGuidesXMLFileToJSONFile reads in a LibGuides XML export file and writes
 // a JSON version of the file. It expects the name of the XML file in srcName
-// the name of the JSON file in destName. It will return an error if any
-// encountered.
+// the name of the JSON file in destName. Either name may be "-" for standard
+// input or output. It will return an error if any encountered.
 func LibGuidesXMLFileToJSONFile(srcName, destName string) error {
-	var (
-		src []byte
-		err error
-	)
-	lg := LibGuides{
-		XMLName:  xml.Name{},
-		Customer: &Customer{},
-		Site:     &Site{},
-		Accounts: []*Account{},
-		Groups:   []*Group{},
-		Subjects: []*Subject{},
-		Tags:     []*Tag{},
-		Vendors:  []*Vendor{},
-		Guides:   []*Guide{},
-	}
-	src, err = ioutil.ReadFile(srcName)
-	if err != nil {
-		return err
-	}
-	err = lg.FromXML(src)
+	lg, err := ReadLibGuides(srcName)
 	if err != nil {
 		return err
 	}
-	src, err = lg.ToJSON()
+	src, err := lg.ToJSON()
 	if err != nil {
 		return err
 	}
-	err = ioutil.WriteFile(destName, src, 0777)
-	if err != nil {
-		return err
-	}
-	return nil
+	return WriteDestination(destName, src)
 }
 
 func ownerName(owner Owner) string {
@@ -89,195 +64,251 @@ func ownerName(owner Owner) string {
 
 // LinkReport reads in a LibGuides XML export and generates a link report
 // encoded in JSON. Accepts a srcName (LibGuides XML export), destName, format
-// (i.e. csv, json, xml). Returns an error if any encountered.
+// (i.e. csv, json, xml). If format is an empty string it is guessed from
+// destName's extension. Either name may be "-" for standard input or output.
+// Returns an error if any encountered.
 func LinkReport(srcName, destName, format string) error {
-	var (
-		src    []byte
-		err    error
-		rptFmt string
-	)
-	// This maps variations for the three supported formats of CSV, JSON and XML (as HTML)
-	formats := map[string]string{
-		"CSV":   "csv",
-		"csv":   "csv",
-		"JSON":  "json",
-		"json":  "json",
-		"HTML":  "xml",
-		"html":  "xml",
-		"XML":   "xml",
-		"xml":   "xml",
-		".csv":  "csv",
-		".json": "json",
-		".html": "xml",
-		".xml":  "xml",
-	}
-	rptFmt = "csv"
-	if val, ok := formats[format]; ok {
-		rptFmt = val
-	} else {
-		return fmt.Errorf("%q is not a supported format", format)
-	}
+	return LinkReportWithOptions(srcName, destName, &Options{Format: format})
+}
 
-	lg := LibGuides{
-		XMLName:  xml.Name{},
-		Customer: &Customer{},
-		Site:     &Site{},
-		Accounts: []*Account{},
-		Groups:   []*Group{},
-		Subjects: []*Subject{},
-		Tags:     []*Tag{},
-		Vendors:  []*Vendor{},
-		Guides:   []*Guide{},
+// LinkReportWithOptions works like LinkReport but takes the format,
+// hidden content policy, table options (where, group by, sort and
+// columns) and write options from opts.
+func LinkReportWithOptions(srcName, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
 	}
-	src, err = ioutil.ReadFile(srcName)
+	lg, err := opts.ReadLibGuides(srcName)
 	if err != nil {
 		return err
 	}
-	err = lg.FromXML(src)
-	if err != nil {
+	opts.Logf("read %d guides from %q", len(lg.Guides), srcName)
+	if err := opts.Validate(); err != nil {
 		return err
 	}
-	sitePrefix := "https://libguides.example.edu"
-	if lg.Site != nil {
-		sitePrefix = fmt.Sprintf("https://%s", lg.Site.Domain)
+	tbl := LinkReportTable(lg, fmt.Sprintf("Link report for %q", srcName), opts)
+	summary := LinkSummaryTable(tbl, fmt.Sprintf("Link summary for %q", srcName))
+	opts.Logf("found %d links, %s non-public", len(tbl.Body.Rows), summary.Body.Rows[len(summary.Body.Rows)-1][2])
+	if opts.Summary {
+		tbl = summary
+	}
+	return opts.WriteTable(tbl, destName)
+}
+
+// LinkSummaryTable counts the links in a link report by Category. The
+// columns are "Category", "Count" and "Non-public", the last row is
+// the total.
+func LinkSummaryTable(links *Table, caption string) *Table {
+	counts := map[string]int{}
+	if col := links.ColumnIndex("Category"); col >= 0 {
+		for _, row := range links.Body.Rows {
+			counts[row[col]]++
+		}
+	}
+	tbl := new(Table)
+	tbl.SetCaption(caption)
+	tbl.AppendHeadings("Category", "Count", "Non-public")
+	nonPublic := 0
+	for _, category := range LinkCategories {
+		cnt := counts[category]
+		if category == LinkPublic {
+			tbl.AppendRow(category, strInt(cnt), "0")
+		} else {
+			tbl.AppendRow(category, strInt(cnt), strInt(cnt))
+			nonPublic += cnt
+		}
+	}
+	tbl.AppendRow("Total", strInt(len(links.Body.Rows)), strInt(nonPublic))
+	return tbl
+}
+
+// LinkReportTable traverses a LibGuides object and returns a Table
+// listing the links found and where they were found. Hidden pages
+// and boxes are reported according to the hidden policy in opts. The
+// site prefix, proxy patterns, ignore patterns, intranet patterns and
+// owner overrides in opts are applied. Each link's Category is
+// "public" or why patrons can't follow it (see ClassifyLink). opts
+// may be nil.
+func LinkReportTable(lg *LibGuides, caption string, opts *Options) *Table {
+	sitePrefix, err := opts.sitePrefix(lg)
+	if err != nil {
+		// The link report has always assumed a site for the LibGuides links
+		sitePrefix = "https://libguides.example.edu"
+	}
+	hidden := HiddenSkip
+	if opts != nil {
+		hidden = opts.Hidden
 	}
+	ownerOf := opts.ownerName
 
 	// Prep our reporting datastructure
 	tbl := new(Table)
-	tbl.SetCaption(fmt.Sprintf("Link report for %q", srcName))
+	tbl.SetCaption(caption)
 	tbl.AppendHeadings([]string{"URL", "Owner",
 		"Object Type", "Id",
 		"Guide Id", "Page Id",
-		"LibGuides Link", "Embedded URL"}...)
-
-	// Traverse over each section of the export before finally analyziing the
-	// data in the "guides" element. (tags and vendors are skipped, no URL data)
-	for _, account := range lg.Accounts {
-		if account.Website != "" {
-			tbl.AppendRow(account.Website, "",
-				"Account", strInt(account.Id),
-				"", "",
-				"", "false")
+		"LibGuides Link", "Embedded URL", "Category"}...)
+	appendRow := func(cells ...string) {
+		if u, ok := opts.reportURL(cells[0]); ok {
+			cells[0] = u
+			tbl.AppendRow(append(cells, opts.linkCategory(u))...)
 		}
 	}
-	for _, group := range lg.Groups {
-		if group.Url != "" {
-			tbl.AppendRow(group.Url, "",
-				"Group", strInt(group.Id),
-				"", "",
-				group.Url, "false")
+
+	// Accounts, groups, subjects and guides are never hidden
+	if hidden.Allows(false) {
+		// Traverse over each section of the export before finally analyziing the
+		// data in the "guides" element. (tags and vendors are skipped, no URL data)
+		for _, account := range lg.Accounts {
+			if account.Website != "" {
+				appendRow(account.Website, "",
+					"Account", strInt(account.Id),
+					"", "",
+					"", "false")
+			}
 		}
-	}
-	for _, subject := range lg.Subjects {
-		if subject.Url != "" {
-			tbl.AppendRow(subject.Url, "",
-				"Subject", strInt(subject.Id),
-				"", "",
-				fmt.Sprintf("%s/sb.php?subject_id=%d", sitePrefix, subject.Id), "false")
+		for _, group := range lg.Groups {
+			if group.Url != "" {
+				appendRow(group.Url, "",
+					"Group", strInt(group.Id),
+					"", "",
+					group.Url, "false")
+			}
+		}
+		for _, subject := range lg.Subjects {
+			if subject.Url != "" {
+				appendRow(subject.Url, "",
+					"Subject", strInt(subject.Id),
+					"", "",
+					fmt.Sprintf("%s/sb.php?subject_id=%d", sitePrefix, subject.Id), "false")
+			}
 		}
 	}
 	// Now process the guides, pages and assets
 	for _, guide := range lg.Guides {
-		if guide.Url != "" {
-			// Note this is the Lib Guide URL
-			tbl.AppendRow(guide.Url, ownerName(guide.Owner),
-				"Guide", strInt(guide.Id),
-				strInt(guide.Id), "",
-				guide.Url, "false")
-		}
-		group := guide.Group
-		if group.Url != "" {
-			tbl.AppendRow(group.Url, ownerName(guide.Owner),
-				"Guide/Group", strInt(guide.Id),
-				strInt(group.Id), "",
-				group.Url, "false")
-		}
-
-		for _, subject := range guide.Subjects {
-			if subject.Url != "" {
-				tbl.AppendRow(subject.Url, ownerName(guide.Owner),
-					"Guide/Subject", strInt(subject.Id),
+		if hidden.Allows(false) {
+			if guide.Url != "" {
+				// Note this is the Lib Guide URL
+				appendRow(guide.Url, ownerOf(guide.Owner),
+					"Guide", strInt(guide.Id),
 					strInt(guide.Id), "",
-					subject.Url, "false")
+					guide.Url, "false")
+			}
+			group := guide.Group
+			if group.Url != "" {
+				appendRow(group.Url, ownerOf(guide.Owner),
+					"Guide/Group", strInt(guide.Id),
+					strInt(group.Id), "",
+					group.Url, "false")
+			}
+
+			for _, subject := range guide.Subjects {
+				if subject.Url != "" {
+					appendRow(subject.Url, ownerOf(guide.Owner),
+						"Guide/Subject", strInt(subject.Id),
+						strInt(guide.Id), "",
+						subject.Url, "false")
+				}
 			}
 		}
 		for _, page := range guide.Pages {
-			// NOTE: Only report the page if it is not hidden
-			if page.Hidden == 0 {
+			pageHidden := page.Hidden != 0
+			pageLink := fmt.Sprintf("%s/c.php?g=%d&p=%d", sitePrefix, guide.Id, page.Id)
+			if hidden.Allows(pageHidden) {
 				if page.Url != "" {
-					tbl.AppendRow(page.Url, ownerName(guide.Owner),
+					appendRow(page.Url, ownerOf(guide.Owner),
 						"Page", strInt(page.Id),
 						strInt(guide.Id), strInt(page.Id),
 						page.Url, "false")
 				}
 				if page.Description != "" {
 					// NOTE: Scan for embedded URLs in the description
-					if urlList, cnt := ExtractHTTPLinks(page.Description); cnt > 0 {
+					if urlList, cnt := extractLinks(page.Description); cnt > 0 {
 						for i := 0; i < cnt; i++ {
-							tbl.AppendRow(urlList[i], ownerName(guide.Owner),
+							appendRow(urlList[i], ownerOf(guide.Owner),
 								"Page/Description", fmt.Sprintf("%d of %d", i+1, cnt),
 								strInt(guide.Id), strInt(page.Id),
-								fmt.Sprintf("%s/c.php?g=%d&p=%d", sitePrefix, guide.Id, page.Id), "true")
+								pageLink, "true")
 						}
 					}
 				}
-				for _, box := range page.Boxes {
-					// Process box Assets
-					if box.Hidden == 0 {
-						for _, asset := range box.Assets {
-							if asset.Url != "" {
-								tbl.AppendRow(asset.Url, ownerName(asset.Owner),
-									"Asset", strInt(asset.Id),
-									strInt(guide.Id), strInt(page.Id),
-									fmt.Sprintf("%s/c.php?g=%d&p=%d", sitePrefix, guide.Id, page.Id), "false")
-							}
-							if asset.Description != "" {
-								// NOTE: Scan for embedded URLs in the description
-								if urlList, cnt := ExtractHTTPLinks(asset.Description); cnt > 0 {
-									for i := 0; i < cnt; i++ {
-										tbl.AppendRow(urlList[i], ownerName(asset.Owner),
-											"Asset/Description", fmt.Sprintf("%d of %d", i+1, cnt),
-											strInt(guide.Id), strInt(page.Id),
-											fmt.Sprintf("%s/c.php?g=%d&p=%d", sitePrefix, guide.Id, page.Id), "true")
-									}
-								}
-							}
-						}
-						for _, pane := range box.Panes {
-							for _, asset := range pane.Assets {
-								if asset.Url != "" {
-									tbl.AppendRow(asset.Url, ownerName(asset.Owner),
-										"Pane/Asset", strInt(asset.Id),
-										strInt(guide.Id), strInt(page.Id),
-										fmt.Sprintf("%s/c.php?g=%d&p=%d", sitePrefix, guide.Id, page.Id), "false")
-								}
-								if asset.Description != "" {
-									// NOTE: Scan for embedded URLs in the description
-									if urlList, cnt := ExtractHTTPLinks(asset.Description); cnt > 0 {
-										for k := 0; k < cnt; k++ {
-											tbl.AppendRow(urlList[k], ownerName(asset.Owner),
-												"Pane/Asset/Description", fmt.Sprintf("%d of %d", k+1, cnt),
-												strInt(guide.Id), strInt(page.Id),
-												fmt.Sprintf("%s/c.php?g=%d&p=%d", sitePrefix, guide.Id, page.Id), "true")
-										}
-									}
-								}
-							}
-						}
+			}
+			for _, box := range page.Boxes {
+				// Process box Assets, a box is hidden if its page is hidden
+				if !hidden.Allows(pageHidden || box.Hidden != 0) {
+					continue
+				}
+				for _, asset := range box.Assets {
+					appendAssetLinks(appendRow, ownerOf, "Asset", asset, guide, page, pageLink)
+				}
+				for _, pane := range box.Panes {
+					for _, asset := range pane.Assets {
+						appendAssetLinks(appendRow, ownerOf, "Pane/Asset", asset, guide, page, pageLink)
 					}
 				}
 			}
 		}
 	}
 
-	// Format output
-	switch rptFmt {
-	case "csv":
-		err = tbl.ToCSVFile(destName, true)
-	case "xml":
-		err = tbl.ToXMLFile(destName)
-	case "json":
-		err = tbl.ToJSONFile(destName)
+	return tbl
+}
+
+// appendAssetLinks adds the asset's URL and any URLs embedded in its
+// description to a link report table.
+func appendAssetLinks(appendRow func(...string), ownerOf func(Owner) string, objType string, asset *Asset, guide *Guide, page *Page, pageLink string) {
+	if asset.Url != "" {
+		appendRow(asset.Url, ownerOf(asset.Owner),
+			objType, strInt(asset.Id),
+			strInt(guide.Id), strInt(page.Id),
+			pageLink, "false")
+	}
+	if asset.Description != "" {
+		// NOTE: Scan for embedded URLs in the description
+		if urlList, cnt := extractLinks(asset.Description); cnt > 0 {
+			for i := 0; i < cnt; i++ {
+				appendRow(urlList[i], ownerOf(asset.Owner),
+					objType+"/Description", fmt.Sprintf("%d of %d", i+1, cnt),
+					strInt(guide.Id), strInt(page.Id),
+					pageLink, "true")
+			}
+		}
+	}
+}
+
+// StatsTable returns a Table counting the accounts, groups, subjects,
+// tags, vendors, guides, pages, boxes and assets in a LibGuides object.
+// Pages, boxes and assets are counted according to the hidden policy.
+func StatsTable(lg *LibGuides, caption string, hidden HiddenPolicy) *Table {
+	pages, boxes, assets := 0, 0, 0
+	for _, guide := range lg.Guides {
+		for _, page := range guide.Pages {
+			pageHidden := page.Hidden != 0
+			if hidden.Allows(pageHidden) {
+				pages++
+			}
+			for _, box := range page.Boxes {
+				if !hidden.Allows(pageHidden || box.Hidden != 0) {
+					continue
+				}
+				boxes++
+				assets += len(box.Assets)
+				for _, pane := range box.Panes {
+					assets += len(pane.Assets)
+				}
+			}
+		}
 	}
-	return err
+	tbl := new(Table)
+	tbl.SetCaption(caption)
+	tbl.AppendHeadings("Object Type", "Count")
+	tbl.AppendRow("Account", strInt(len(lg.Accounts)))
+	tbl.AppendRow("Group", strInt(len(lg.Groups)))
+	tbl.AppendRow("Subject", strInt(len(lg.Subjects)))
+	tbl.AppendRow("Tag", strInt(len(lg.Tags)))
+	tbl.AppendRow("Vendor", strInt(len(lg.Vendors)))
+	tbl.AppendRow("Guide", strInt(len(lg.Guides)))
+	tbl.AppendRow("Page", strInt(pages))
+	tbl.AppendRow("Box", strInt(boxes))
+	tbl.AppendRow("Asset", strInt(assets))
+	return tbl
 }
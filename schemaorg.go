@@ -0,0 +1,230 @@
+// schemaorg.go describes the guides and pages of a LibGuides export as
+// schema.org CreativeWork and WebPage JSON-LD.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SchemaContext is the JSON-LD context of schema.org
+const SchemaContext = "https://schema.org"
+
+// SchemaThing is a schema.org Person, Organization or Thing, or with
+// only an Id a reference to another node.
+type SchemaThing struct {
+	Type string `json:"@type,omitempty"`
+	Id   string `json:"@id,omitempty"`
+	Name string `json:"name,omitempty"`
+	Url  string `json:"url,omitempty"`
+}
+
+// SchemaWork is a schema.org CreativeWork (a guide) or WebPage (a
+// page of a guide).
+type SchemaWork struct {
+	Type          string         `json:"@type"`
+	Id            string         `json:"@id,omitempty"`
+	Url           string         `json:"url,omitempty"`
+	Name          string         `json:"name"`
+	Description   string         `json:"description,omitempty"`
+	Author        *SchemaThing   `json:"author,omitempty"`
+	Publisher     *SchemaThing   `json:"publisher,omitempty"`
+	Keywords      []string       `json:"keywords,omitempty"`
+	About         []*SchemaThing `json:"about,omitempty"`
+	DateCreated   string         `json:"dateCreated,omitempty"`
+	DateModified  string         `json:"dateModified,omitempty"`
+	DatePublished string         `json:"datePublished,omitempty"`
+	IsPartOf      *SchemaThing   `json:"isPartOf,omitempty"`
+	HasPart       []*SchemaWork  `json:"hasPart,omitempty"`
+}
+
+// SchemaGraph is a JSON-LD document of schema.org works.
+type SchemaGraph struct {
+	Context string        `json:"@context"`
+	Graph   []*SchemaWork `json:"@graph"`
+}
+
+// isPublishedGuide returns true if a guide is published, i.e. its
+// status is "Published" or not given (older exports) and it doesn't
+// redirect elsewhere.
+func isPublishedGuide(guide *Guide) bool {
+	status := strings.TrimSpace(guide.Status)
+	return (status == "" || strings.EqualFold(status, "Published")) && guide.Redirect == ""
+}
+
+// isPublicPage returns true if a page of a published guide is shown to
+// patrons, i.e. it isn't hidden and doesn't redirect elsewhere.
+func isPublicPage(page *Page) bool {
+	return page.Hidden == 0 && page.Redirect == ""
+}
+
+// publicGuideURL returns a guide's URL, built from the site prefix
+// (e.g. the site's domain) when the export doesn't have one. Returns an
+// empty string for a guide without a URL or id.
+func publicGuideURL(guide *Guide, prefix string) string {
+	if u := strings.TrimSpace(guide.Url); u != "" {
+		return u
+	}
+	if guide.Id == 0 || prefix == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/c.php?g=%d", prefix, guide.Id)
+}
+
+// publicPageURL returns a page's URL, built from the site prefix when
+// the export doesn't have one.
+func publicPageURL(guide *Guide, page *Page, prefix string) string {
+	if u := strings.TrimSpace(page.Url); u != "" {
+		return u
+	}
+	if prefix == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/c.php?g=%d&p=%d", prefix, guide.Id, page.Id)
+}
+
+// schemaDate converts a LibGuides timestamp to an ISO 8601 date and
+// time, an empty string if invalid.
+func schemaDate(ts string) string {
+	t, ok := ParseTimestamp(ts)
+	if !ok {
+		return ""
+	}
+	return t.Format("2006-01-02T15:04:05")
+}
+
+// schemaAuthor returns the owner as a schema.org Person, nil without
+// a name.
+func schemaAuthor(owner Owner) *SchemaThing {
+	name := strings.TrimSpace(owner.FirstName + " " + owner.LastName)
+	if name == "" {
+		return nil
+	}
+	return &SchemaThing{Type: "Person", Name: name}
+}
+
+// SchemaGuides describes the published guides as schema.org
+// CreativeWorks with their public pages as WebPages in hasPart. The
+// author is the owner, the keywords are the tags, the subjects are
+// what it is about and the publisher is the customer. Hidden and
+// unpublished content is always left out.
+func SchemaGuides(lg *LibGuides, opts *Options) []*SchemaWork {
+	// Without a known site, URLs missing from the export are left out
+	prefix, _ := opts.sitePrefix(lg)
+	var publisher *SchemaThing
+	if lg.Customer != nil && lg.Customer.Name != "" {
+		publisher = &SchemaThing{Type: "Organization", Name: lg.Customer.Name, Url: lg.Customer.Url}
+	}
+	works := []*SchemaWork{}
+	for _, guide := range lg.Guides {
+		if !isPublishedGuide(guide) {
+			continue
+		}
+		guideURL := publicGuideURL(guide, prefix)
+		work := &SchemaWork{
+			Type: "CreativeWork", Id: guideURL, Url: guideURL, Name: guide.Name,
+			Description:   descriptionText(guide.Description),
+			Author:        schemaAuthor(guide.Owner),
+			Publisher:     publisher,
+			DateCreated:   schemaDate(guide.Created),
+			DateModified:  schemaDate(latestTimestamp(guide.Modified, guide.Updated)),
+			DatePublished: schemaDate(guide.Published),
+		}
+		for _, tag := range guide.Tags {
+			work.Keywords = append(work.Keywords, tag.Name)
+		}
+		for _, subject := range guide.Subjects {
+			work.About = append(work.About, &SchemaThing{Type: "Thing", Name: subject.Name, Url: subject.Url})
+		}
+		for _, page := range guide.Pages {
+			if !isPublicPage(page) {
+				continue
+			}
+			pageURL := publicPageURL(guide, page, prefix)
+			part := &SchemaWork{
+				Type: "WebPage", Id: pageURL, Url: pageURL, Name: page.Name,
+				Description:  descriptionText(page.Description),
+				Author:       work.Author,
+				DateCreated:  schemaDate(page.Created),
+				DateModified: schemaDate(latestTimestamp(page.Modified, page.Updated)),
+			}
+			if guideURL != "" {
+				part.IsPartOf = &SchemaThing{Id: guideURL}
+			}
+			work.HasPart = append(work.HasPart, part)
+		}
+		works = append(works, work)
+	}
+	return works
+}
+
+// ToJSONLD renders a guide's work as a JSON-LD document, e.g. for a
+// <script type="application/ld+json"> element of the guide. "<", ">"
+// and "&" are escaped so it can't close the element.
+func (w *SchemaWork) ToJSONLD() ([]byte, error) {
+	doc := struct {
+		Context string `json:"@context"`
+		*SchemaWork
+	}{SchemaContext, w}
+	return json.MarshalIndent(doc, "", "    ")
+}
+
+// JSONLDReport reads a LibGuides export and writes its published
+// guides and pages to destName as a schema.org JSON-LD document (see
+// SchemaGuides).
+func JSONLDReport(srcName string, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	lg, err := opts.ReadLibGuides(srcName)
+	if err != nil {
+		return err
+	}
+	graph := &SchemaGraph{Context: SchemaContext, Graph: SchemaGuides(lg, opts)}
+	opts.Logf("described %d of %d guides", len(graph.Graph), len(lg.Guides))
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(graph); err != nil {
+		return err
+	}
+	return WriteDestinationWithOptions(destName, buf.Bytes(), &opts.WriteOptions)
+}
@@ -0,0 +1,107 @@
+// schemaorg_test.go tests the schema.org JSON-LD in schemaorg.go.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSchemaGuides(t *testing.T) {
+	lg, err := ReadLibGuides(filepath.Join("testinput", "api", "LibGuides_export_api.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	works := SchemaGuides(lg, nil)
+	// The private guide is left out
+	expectedInt(t, 2, len(works))
+	if len(works) != 2 {
+		t.FailNow()
+	}
+	work := works[0]
+	expectedString(t, "CreativeWork", work.Type)
+	expectedString(t, "https://libguides.example.edu/patents", work.Id)
+	expectedString(t, "Patents and Standards", work.Name)
+	expectedString(t, "Finding patents and technical standards.", work.Description)
+	expectedString(t, "Jane Doe", work.Author.Name)
+	expectedString(t, "Tiny Institute of Small Things", work.Publisher.Name)
+	expectedString(t, "patents,standards", strings.Join(work.Keywords, ","))
+	expectedInt(t, 1, len(work.About))
+	expectedString(t, "Engineering", work.About[0].Name)
+	expectedString(t, "2016-09-07T10:11:12", work.DateCreated)
+	expectedString(t, "2021-03-01T09:00:00", work.DateModified)
+	expectedString(t, "2016-09-20T08:00:00", work.DatePublished)
+	// The hidden page is left out
+	expectedInt(t, 2, len(work.HasPart))
+	if len(work.HasPart) == 2 {
+		page := work.HasPart[1]
+		expectedString(t, "WebPage", page.Type)
+		expectedString(t, "https://libguides.example.edu/c.php?g=512671&p=3502869", page.Url)
+		expectedString(t, work.Id, page.IsPartOf.Id)
+	}
+
+	src, err := work.ToJSONLD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(src, &doc); err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, SchemaContext, doc["@context"].(string))
+	expectedString(t, "CreativeWork", doc["@type"].(string))
+	if strings.Contains(string(src), "&p=") {
+		t.Errorf("expected & to be escaped in %s", src)
+	}
+}
+
+func TestJSONLDReport(t *testing.T) {
+	destName := filepath.Join("testout", "guides.jsonld")
+	if err := JSONLDReport(filepath.Join("testinput", "api", "LibGuides_export_api.xml"), destName, nil); err != nil {
+		t.Fatal(err)
+	}
+	src, err := ioutil.ReadFile(destName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph := new(SchemaGraph)
+	if err := json.Unmarshal(src, graph); err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, SchemaContext, graph.Context)
+	expectedInt(t, 2, len(graph.Graph))
+}
@@ -0,0 +1,494 @@
+// search.go provides a full-text index of the guides, pages, boxes and assets
+// in a LibGuides export.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// searchIndexMagic starts a search index file, it is followed by the
+// gzip compressed gob encoding of the SearchIndex.
+const searchIndexMagic = "springytools search index 1\n"
+
+// SearchFields are the fields a query can filter on, e.g. tag:patents.
+var SearchFields = []string{"owner", "tag", "subject", "type"}
+
+// SearchDoc is an indexed guide, page, box or asset. Pages, boxes and
+// assets have the tags and subjects of their guide.
+type SearchDoc struct {
+	ObjectType string
+	Id         int
+	GuideId    int
+	PageId     int
+	BoxId      int
+	Name       string
+	// Type is the guide, box or asset type
+	Type     string
+	Owner    string
+	Tags     []string
+	Subjects []string
+}
+
+// SearchPosting lists the positions of a term in a document.
+type SearchPosting struct {
+	Doc       int
+	Positions []int
+}
+
+// SearchIndex is an inverted index of the names and the plain text of
+// the descriptions in a LibGuides export.
+type SearchIndex struct {
+	Docs     []*SearchDoc
+	Postings map[string][]*SearchPosting
+}
+
+// SearchResult is a document matching a query.
+type SearchResult struct {
+	Doc   *SearchDoc
+	Score float64
+}
+
+// add indexes a document, the name and text are kept apart so phrases
+// don't match across them.
+func (idx *SearchIndex) add(doc *SearchDoc, text string) {
+	n := len(idx.Docs)
+	idx.Docs = append(idx.Docs, doc)
+	words := textWords(doc.Name)
+	if text != "" {
+		words = append(append(words, ""), textWords(text)...)
+	}
+	for pos, word := range words {
+		if word == "" {
+			continue
+		}
+		postings := idx.Postings[word]
+		if len(postings) == 0 || postings[len(postings)-1].Doc != n {
+			postings = append(postings, &SearchPosting{Doc: n})
+		}
+		p := postings[len(postings)-1]
+		p.Positions = append(p.Positions, pos)
+		idx.Postings[word] = postings
+	}
+}
+
+// NewSearchIndex indexes the guides, pages, boxes and assets of a
+// LibGuides object. Hidden pages and boxes are indexed according to
+// the hidden policy in opts, opts may be nil.
+func NewSearchIndex(lg *LibGuides, opts *Options) *SearchIndex {
+	hidden := HiddenSkip
+	if opts != nil {
+		hidden = opts.Hidden
+	}
+	idx := &SearchIndex{Postings: map[string][]*SearchPosting{}}
+	for _, guide := range lg.Guides {
+		tags, subjects := []string{}, []string{}
+		for _, tag := range guide.Tags {
+			tags = append(tags, tag.Name)
+		}
+		for _, subject := range guide.Subjects {
+			subjects = append(subjects, subject.Name)
+		}
+		if hidden.Allows(false) {
+			idx.add(&SearchDoc{
+				ObjectType: "Guide", Id: guide.Id, GuideId: guide.Id,
+				Name: guide.Name, Type: guide.Type, Owner: opts.ownerName(guide.Owner),
+				Tags: tags, Subjects: subjects,
+			}, descriptionText(guide.Description))
+		}
+		for _, page := range guide.Pages {
+			pageHidden := page.Hidden != 0
+			if hidden.Allows(pageHidden) {
+				idx.add(&SearchDoc{
+					ObjectType: "Page", Id: page.Id, GuideId: guide.Id, PageId: page.Id,
+					Name: page.Name, Owner: opts.ownerName(guide.Owner),
+					Tags: tags, Subjects: subjects,
+				}, descriptionText(page.Description))
+			}
+			for _, box := range page.Boxes {
+				if !hidden.Allows(pageHidden || box.Hidden != 0) {
+					continue
+				}
+				idx.add(&SearchDoc{
+					ObjectType: "Box", Id: box.Id, GuideId: guide.Id, PageId: page.Id, BoxId: box.Id,
+					Name: box.Name, Type: box.Type, Owner: opts.ownerName(guide.Owner),
+					Tags: tags, Subjects: subjects,
+				}, "")
+				assets := append([]*Asset{}, box.Assets...)
+				for _, pane := range box.Panes {
+					assets = append(assets, pane.Assets...)
+				}
+				for _, asset := range assets {
+					idx.add(&SearchDoc{
+						ObjectType: "Asset", Id: asset.Id, GuideId: guide.Id, PageId: page.Id, BoxId: box.Id,
+						Name: asset.Name, Type: asset.Type, Owner: opts.ownerName(asset.Owner),
+						Tags: tags, Subjects: subjects,
+					}, descriptionText(asset.Description))
+				}
+			}
+		}
+	}
+	return idx
+}
+
+// searchClause is a term, phrase or field filter of a query.
+type searchClause struct {
+	Field string
+	Words []string
+	Value string
+}
+
+// parseSearchQuery splits a query into clauses. Clauses are words,
+// "quoted phrases" or field:value filters where the value may be
+// quoted, e.g. tag:"web of science".
+func parseSearchQuery(query string) ([]*searchClause, error) {
+	clauses := []*searchClause{}
+	r := []rune(query)
+	for i := 0; i < len(r); {
+		if unicode.IsSpace(r[i]) {
+			i++
+			continue
+		}
+		field := ""
+		if r[i] != '"' {
+			j := i
+			for j < len(r) && !unicode.IsSpace(r[j]) && r[j] != ':' && r[j] != '"' {
+				j++
+			}
+			if j < len(r) && r[j] == ':' {
+				field = strings.ToLower(string(r[i:j]))
+				i = j + 1
+			}
+		}
+		value := ""
+		if i < len(r) && r[i] == '"' {
+			j := i + 1
+			for j < len(r) && r[j] != '"' {
+				j++
+			}
+			if j >= len(r) {
+				return nil, fmt.Errorf("unterminated quote in %q", query)
+			}
+			value, i = string(r[i+1:j]), j+1
+		} else {
+			j := i
+			for j < len(r) && !unicode.IsSpace(r[j]) {
+				j++
+			}
+			value, i = string(r[i:j]), j
+		}
+		if field != "" {
+			known := false
+			for _, name := range SearchFields {
+				known = known || name == field
+			}
+			if !known {
+				return nil, fmt.Errorf("unknown search field %q, expected one of %s", field, strings.Join(SearchFields, ", "))
+			}
+			if value = strings.TrimSpace(value); value == "" {
+				return nil, fmt.Errorf("missing value for %s:", field)
+			}
+			clauses = append(clauses, &searchClause{Field: field, Value: value})
+		} else if words := textWords(value); len(words) > 0 {
+			clauses = append(clauses, &searchClause{Words: words})
+		}
+	}
+	return clauses, nil
+}
+
+// matchField reports if a document passes a field filter. Owners
+// match on a case insensitive substring, tags, subjects and types on
+// the case insensitive value (the type matches the object type too).
+func matchField(doc *SearchDoc, clause *searchClause) bool {
+	equals := func(values ...string) bool {
+		for _, value := range values {
+			if strings.EqualFold(value, clause.Value) {
+				return true
+			}
+		}
+		return false
+	}
+	switch clause.Field {
+	case "owner":
+		return strings.Contains(strings.ToLower(doc.Owner), strings.ToLower(clause.Value))
+	case "tag":
+		return equals(doc.Tags...)
+	case "subject":
+		return equals(doc.Subjects...)
+	case "type":
+		return equals(doc.ObjectType, doc.Type)
+	}
+	return false
+}
+
+// matchWords returns the number of times a word or phrase occurs in
+// each matching document.
+func (idx *SearchIndex) matchWords(words []string) map[int]int {
+	counts := map[int]int{}
+	first := idx.Postings[words[0]]
+	if len(words) == 1 {
+		for _, p := range first {
+			counts[p.Doc] = len(p.Positions)
+		}
+		return counts
+	}
+	// Positions of the following words, by document
+	rest := make([]map[int]map[int]bool, len(words)-1)
+	for i, word := range words[1:] {
+		rest[i] = map[int]map[int]bool{}
+		for _, p := range idx.Postings[word] {
+			positions := map[int]bool{}
+			for _, pos := range p.Positions {
+				positions[pos] = true
+			}
+			rest[i][p.Doc] = positions
+		}
+	}
+	for _, p := range first {
+		for _, pos := range p.Positions {
+			found := true
+			for i := range rest {
+				if !rest[i][p.Doc][pos+i+1] {
+					found = false
+					break
+				}
+			}
+			if found {
+				counts[p.Doc]++
+			}
+		}
+	}
+	return counts
+}
+
+// Search returns the documents matching all the clauses of a query,
+// highest score first. Words and phrases are scored by their
+// frequency weighted by how rare they are, a query of only field
+// filters lists the matching documents in index order.
+func (idx *SearchIndex) Search(query string) ([]*SearchResult, error) {
+	clauses, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(clauses) == 0 {
+		return nil, fmt.Errorf("empty search query")
+	}
+	var scores map[int]float64
+	for _, clause := range clauses {
+		if clause.Field != "" {
+			continue
+		}
+		counts := idx.matchWords(clause.Words)
+		idf := math.Log(1 + float64(len(idx.Docs))/float64(len(counts)+1))
+		next := map[int]float64{}
+		for doc, cnt := range counts {
+			if scores == nil {
+				next[doc] = float64(cnt) * idf
+			} else if score, ok := scores[doc]; ok {
+				next[doc] = score + float64(cnt)*idf
+			}
+		}
+		scores = next
+	}
+	if scores == nil {
+		scores = map[int]float64{}
+		for doc := range idx.Docs {
+			scores[doc] = 0
+		}
+	}
+	results := []*SearchResult{}
+	docs := []int{}
+	for doc := range scores {
+		docs = append(docs, doc)
+	}
+	sort.Ints(docs)
+	for _, doc := range docs {
+		matched := true
+		for _, clause := range clauses {
+			if clause.Field != "" && !matchField(idx.Docs[doc], clause) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			results = append(results, &SearchResult{Doc: idx.Docs[doc], Score: scores[doc]})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results, nil
+}
+
+// SearchTable returns the search results as a table with the columns
+// "Score", "Object Type", "Id", "Guide Id", "Page Id", "Box Id",
+// "Name", "Type" and "Owner".
+func SearchTable(results []*SearchResult, caption string) *Table {
+	tbl := new(Table)
+	tbl.SetCaption(caption)
+	tbl.AppendHeadings("Score", "Object Type", "Id", "Guide Id", "Page Id", "Box Id", "Name", "Type", "Owner")
+	id := func(i int) string {
+		if i == 0 {
+			return ""
+		}
+		return strInt(i)
+	}
+	for _, result := range results {
+		doc := result.Doc
+		tbl.AppendRow(fmt.Sprintf("%.3f", result.Score), doc.ObjectType, strInt(doc.Id),
+			strInt(doc.GuideId), id(doc.PageId), id(doc.BoxId), doc.Name, doc.Type, doc.Owner)
+	}
+	return tbl
+}
+
+// Encode writes the index as gzip compressed gob after a magic line.
+func (idx *SearchIndex) Encode(w io.Writer) error {
+	if _, err := io.WriteString(w, searchIndexMagic); err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(w)
+	if err := gob.NewEncoder(zw).Encode(idx); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// DecodeSearchIndex reads an index written by Encode.
+func DecodeSearchIndex(r io.Reader) (*SearchIndex, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(searchIndexMagic))
+	if err != nil || string(magic) != searchIndexMagic {
+		return nil, fmt.Errorf("not a springytools search index")
+	}
+	br.Discard(len(searchIndexMagic))
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	idx := new(SearchIndex)
+	if err := gob.NewDecoder(zr).Decode(idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// isSearchIndex reports if a file starts with the search index magic.
+func isSearchIndex(fName string) bool {
+	f, err := os.Open(fName)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, len(searchIndexMagic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return string(magic) == searchIndexMagic
+}
+
+// ReadSearchIndex reads a search index file. If the file is a LibGuides
+// export, or opts reads from the API, the index is built from it
+// instead.
+func ReadSearchIndex(fName string, opts *Options) (*SearchIndex, error) {
+	if !opts.FromAPI() && fName != StdIO && isSearchIndex(fName) {
+		f, err := os.Open(fName)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		idx, err := DecodeSearchIndex(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", fName, err)
+		}
+		return idx, nil
+	}
+	lg, err := opts.ReadLibGuides(fName)
+	if err != nil {
+		return nil, err
+	}
+	return NewSearchIndex(lg, opts), nil
+}
+
+// SearchIndexReport builds a search index from a LibGuides export and
+// writes it to destName.
+func SearchIndexReport(srcName, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	lg, err := opts.ReadLibGuides(srcName)
+	if err != nil {
+		return err
+	}
+	opts.Logf("read %d guides from %q", len(lg.Guides), srcName)
+	idx := NewSearchIndex(lg, opts)
+	opts.Logf("indexed %d documents, %d terms", len(idx.Docs), len(idx.Postings))
+	buf := new(bytes.Buffer)
+	if err := idx.Encode(buf); err != nil {
+		return err
+	}
+	return WriteDestinationWithOptions(destName, buf.Bytes(), &opts.WriteOptions)
+}
+
+// SearchReport searches an index (or a LibGuides export) and writes
+// the results table to destName.
+func SearchReport(srcName, query, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	idx, err := ReadSearchIndex(srcName, opts)
+	if err != nil {
+		return err
+	}
+	results, err := idx.Search(query)
+	if err != nil {
+		return err
+	}
+	opts.Logf("found %d of %d documents", len(results), len(idx.Docs))
+	return opts.WriteTable(SearchTable(results, fmt.Sprintf("Search %q in %q", query, srcName)), destName)
+}
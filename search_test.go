@@ -0,0 +1,139 @@
+// search_test.go provides tests for search.go
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func searchFixture() *LibGuides {
+	jane := Owner{FirstName: "Jane", LastName: "Doe", Email: "jane@example.edu"}
+	return &LibGuides{
+		Guides: []*Guide{
+			{Id: 1, Name: "Chemistry", Type: "Subject Guide", Owner: jane,
+				Tags:        []*Tag{{Name: "databases"}},
+				Subjects:    []*Subject{{Name: "Chemical Engineering"}},
+				Description: "&lt;p&gt;Start with Web of Science.&lt;/p&gt;",
+				Pages: []*Page{{Id: 10, Name: "Home", Boxes: []*Box{{Id: 20, Name: "Databases", Type: "Tabbed", Assets: []*Asset{
+					{Id: 30, Name: "Web of Science", Type: "Database", Owner: jane},
+					{Id: 31, Name: "Scopus", Type: "Database", Description: "<p>An alternative to Web of <b>Science</b>, see the web.</p>"},
+				}, Panes: []*Pane{{Assets: []*Asset{{Id: 32, Name: "Science Direct", Type: "Link"}}}}}}}},
+			},
+			{Id: 2, Name: "Patents", Type: "Topic Guide",
+				Pages: []*Page{{Id: 11, Name: "Science of patents", Hidden: 1}}},
+		},
+	}
+}
+
+func searchIds(t *testing.T, idx *SearchIndex, query string) string {
+	results, err := idx.Search(query)
+	if err != nil {
+		t.Fatalf("%q: %s", query, err)
+	}
+	ids := []string{}
+	for _, result := range results {
+		ids = append(ids, result.Doc.ObjectType+strInt(result.Doc.Id))
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestSearchIndex(t *testing.T) {
+	idx := NewSearchIndex(searchFixture(), nil)
+	expectedInt(t, 7, len(idx.Docs))
+	expectedString(t, "Guide1,Asset30,Asset31", searchIds(t, idx, `"web of science"`))
+	expectedString(t, "Asset31,Guide1,Asset30", searchIds(t, idx, `web`))
+	expectedString(t, "Guide1,Asset30,Asset31,Asset32", searchIds(t, idx, `science`))
+	expectedString(t, "", searchIds(t, idx, `"science web"`))
+	expectedString(t, "Asset30,Asset31", searchIds(t, idx, `"web of science" type:asset`))
+	expectedString(t, "Asset30,Asset31", searchIds(t, idx, `science type:Database`))
+	expectedString(t, "Asset30", searchIds(t, idx, `science owner:JANE type:asset`))
+	expectedString(t, "Guide1,Page10,Box20,Asset30,Asset31,Asset32", searchIds(t, idx, `subject:"chemical engineering"`))
+	expectedString(t, "Guide1,Page10,Box20,Asset30,Asset31,Asset32", searchIds(t, idx, `tag:databases`))
+	expectedString(t, "Guide2", searchIds(t, idx, `patents`))
+	expectedString(t, "Guide2,Page11", searchIds(t, NewSearchIndex(searchFixture(), &Options{Hidden: HiddenInclude}), `patents`))
+
+	for _, query := range []string{``, `author:jane`, `"web of`, `tag:`} {
+		if _, err := idx.Search(query); err == nil {
+			t.Errorf("expected an error for %q", query)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := idx.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeSearchIndex(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, searchIds(t, idx, `"web of science"`), searchIds(t, decoded, `"web of science"`))
+	if _, err := DecodeSearchIndex(strings.NewReader("<libguides/>")); err == nil {
+		t.Errorf("expected an error decoding XML as an index")
+	}
+}
+
+func TestSearchReport(t *testing.T) {
+	src := filepath.Join("testinput", "LibGuides_export_XXXXX.xml")
+	idxName := filepath.Join("testout", "libguides.idx")
+	if err := SearchIndexReport(src, idxName, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !isSearchIndex(idxName) || isSearchIndex(src) {
+		t.Errorf("expected %q to be an index and %q not", idxName, src)
+	}
+	fromIndex := filepath.Join("testout", "search-index.csv")
+	fromExport := filepath.Join("testout", "search-export.csv")
+	if err := SearchReport(idxName, "lectures", fromIndex, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := SearchReport(src, "lectures", fromExport, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ioutil.ReadFile(fromExport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(fromIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bytes.Split(got, []byte("\n"))) < 3 {
+		t.Errorf("expected results for lectures, got %s", got)
+	}
+	expectedBytes(t, expected, got)
+}
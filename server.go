@@ -0,0 +1,663 @@
+// server.go serves a LibGuides export as a read-only JSON REST service.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAddr is the address the serve command listens on
+	DefaultAddr = "localhost:8080"
+	// DefaultPollSeconds is how often a watched directory is checked
+	// for a new export
+	DefaultPollSeconds = 60
+	// DefaultPerPage is the number of items in a page of results
+	DefaultPerPage = 50
+	// MaxPerPage is the largest per_page accepted
+	MaxPerPage = 1000
+)
+
+// LinkStatusPublic and LinkStatusNonPublic are the statuses of a link
+// served by /links. The status comes from the link's Category alone, no
+// requests are made, so a non-public link is one patrons can't follow
+// from outside the campus network, not one known to be broken.
+const (
+	LinkStatusPublic    = "public"
+	LinkStatusNonPublic = "nonpublic"
+)
+
+// linkStatusAliases are the other names accepted by /links?status=,
+// "broken" being the non-public links.
+var linkStatusAliases = map[string]string{
+	"ok":     LinkStatusPublic,
+	"broken": LinkStatusNonPublic,
+}
+
+// ServerGuide is a guide listed by /guides, without its pages.
+type ServerGuide struct {
+	*GuideRecord
+	Subjects  []*Subject `json:"subjects"`
+	Tags      []*Tag     `json:"tags"`
+	PageCount int        `json:"page_count"`
+}
+
+// AssetLocation is a box (and pane) an asset is found in.
+type AssetLocation struct {
+	GuideId int `json:"guide_id"`
+	PageId  int `json:"page_id"`
+	BoxId   int `json:"box_id"`
+	Pane    int `json:"pane"`
+}
+
+// ServerAsset is an asset served by /assets/{id}, the record is of its
+// first location, Locations lists every box it is mapped into.
+type ServerAsset struct {
+	*AssetRecord
+	Locations []*AssetLocation `json:"locations"`
+}
+
+// ServerLink is a row of the link report served by /links.
+type ServerLink struct {
+	Url           string `json:"url"`
+	Owner         string `json:"owner"`
+	ObjectType    string `json:"object_type"`
+	Id            string `json:"id"`
+	GuideId       string `json:"guide_id"`
+	PageId        string `json:"page_id"`
+	LibGuidesLink string `json:"libguides_link"`
+	Embedded      bool   `json:"embedded"`
+	Category      string `json:"category"`
+	Status        string `json:"status"`
+}
+
+// ServerPage is a page of results.
+type ServerPage struct {
+	Total   int         `json:"total"`
+	Page    int         `json:"page"`
+	PerPage int         `json:"per_page"`
+	Items   interface{} `json:"items"`
+}
+
+// serverData is an export with the lookups used by the endpoints.
+type serverData struct {
+	source   string
+	modified time.Time
+	loaded   time.Time
+	lg       *LibGuides
+	guides   map[int]*Guide
+	assets   map[int]*ServerAsset
+	subjects map[int]bool
+	links    []*ServerLink
+}
+
+// Server serves a LibGuides export as read-only JSON. The export can
+// be replaced while serving (see Load and Watch).
+type Server struct {
+	opts *Options
+	mu   sync.RWMutex
+	data *serverData
+}
+
+// NewServer returns a Server for an export, lg may be nil until an
+// export is loaded. Hidden pages and boxes are served according to the
+// hidden policy in opts, the link settings in opts apply to /links.
+func NewServer(lg *LibGuides, opts *Options) *Server {
+	if opts == nil {
+		opts = new(Options)
+	}
+	s := &Server{opts: opts}
+	if lg != nil {
+		s.Load(lg, "", time.Time{})
+	}
+	return s
+}
+
+// linkStatus returns LinkStatusPublic for links in the LinkPublic
+// category, otherwise LinkStatusNonPublic.
+func linkStatus(category string) string {
+	if category != LinkPublic {
+		return LinkStatusNonPublic
+	}
+	return LinkStatusPublic
+}
+
+// Load replaces the export being served. source and modified
+// describe where the export came from.
+func (s *Server) Load(lg *LibGuides, source string, modified time.Time) {
+	hidden := s.opts.Hidden
+	data := &serverData{
+		source:   source,
+		modified: modified,
+		loaded:   time.Now(),
+		lg:       lg,
+		guides:   map[int]*Guide{},
+		assets:   map[int]*ServerAsset{},
+		subjects: map[int]bool{},
+	}
+	for _, subject := range lg.Subjects {
+		data.subjects[subject.Id] = true
+	}
+	for _, guide := range lg.Guides {
+		data.guides[guide.Id] = guide
+		for _, subject := range guide.Subjects {
+			data.subjects[subject.Id] = true
+		}
+		for _, page := range guide.Pages {
+			pageHidden := page.Hidden != 0
+			for _, box := range page.Boxes {
+				if !hidden.Allows(pageHidden || box.Hidden != 0) {
+					continue
+				}
+				addAsset := func(asset *Asset, pane int) {
+					sa, ok := data.assets[asset.Id]
+					if !ok {
+						sa = &ServerAsset{AssetRecord: newAssetRecord(guide.Id, page.Id, box.Id, pane, asset)}
+						data.assets[asset.Id] = sa
+					}
+					sa.Locations = append(sa.Locations, &AssetLocation{GuideId: guide.Id, PageId: page.Id, BoxId: box.Id, Pane: pane})
+				}
+				for _, asset := range box.Assets {
+					addAsset(asset, 0)
+				}
+				for i, pane := range box.Panes {
+					for _, asset := range pane.Assets {
+						addAsset(asset, i+1)
+					}
+				}
+			}
+		}
+	}
+	tbl := LinkReportTable(lg, "links", s.opts)
+	for _, row := range tbl.Body.Rows {
+		data.links = append(data.links, &ServerLink{
+			Url: row[0], Owner: row[1], ObjectType: row[2], Id: row[3],
+			GuideId: row[4], PageId: row[5], LibGuidesLink: row[6],
+			Embedded: row[7] == "true", Category: row[8],
+			Status: linkStatus(row[8]),
+		})
+	}
+	s.mu.Lock()
+	s.data = data
+	s.mu.Unlock()
+	s.opts.Logf("serving %d guides from %q", len(lg.Guides), source)
+}
+
+// LoadFile reads an export and serves it.
+func (s *Server) LoadFile(fName string) error {
+	info, err := os.Stat(fName)
+	if err != nil {
+		return err
+	}
+	lg, err := ReadLibGuides(fName)
+	if err != nil {
+		return err
+	}
+	s.Load(lg, fName, info.ModTime())
+	return nil
+}
+
+// LoadAPI assembles LibGuides from the API and serves them.
+func (s *Server) LoadAPI() error {
+	c, err := s.opts.APIClient()
+	if err != nil {
+		return err
+	}
+	lg, err := AssembleLibGuides(c)
+	if err != nil {
+		return err
+	}
+	s.Load(lg, c.BaseURL, time.Now())
+	return nil
+}
+
+// isExportName reports if a file name looks like an export, i.e. XML
+// possibly gzip or zip compressed.
+func isExportName(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range []string{".xml", ".xml.gz", ".zip"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// latestExport returns the most recently modified export in dir.
+func latestExport(dir string) (string, time.Time, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	name, modified := "", time.Time{}
+	for _, info := range infos {
+		if info.IsDir() || !isExportName(info.Name()) {
+			continue
+		}
+		if name == "" || info.ModTime().After(modified) ||
+			(info.ModTime().Equal(modified) && info.Name() > filepath.Base(name)) {
+			name, modified = filepath.Join(dir, info.Name()), info.ModTime()
+		}
+	}
+	if name == "" {
+		return "", time.Time{}, fmt.Errorf("no exports found in %q", dir)
+	}
+	return name, modified, nil
+}
+
+// Reload loads the latest export in dir if it differs from the one
+// being served. Returns true if an export was loaded.
+func (s *Server) Reload(dir string) (bool, error) {
+	name, modified, err := latestExport(dir)
+	if err != nil {
+		return false, err
+	}
+	s.mu.RLock()
+	data := s.data
+	s.mu.RUnlock()
+	if data != nil && data.source == name && data.modified.Equal(modified) {
+		return false, nil
+	}
+	if err := s.LoadFile(name); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Watch checks dir for a new export every interval until stop is
+// closed. Errors are logged and the current export kept.
+func (s *Server) Watch(dir string, interval time.Duration, stop <-chan struct{}) {
+	s.poll(interval, stop, func() error {
+		_, err := s.Reload(dir)
+		return err
+	})
+}
+
+// poll calls reload every interval until stop is closed, logging
+// errors.
+func (s *Server) poll(interval time.Duration, stop <-chan struct{}, reload func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := reload(); err != nil {
+				s.opts.Logf("%s", err)
+			}
+		}
+	}
+}
+
+// serverError is the body of an error response.
+type serverError struct {
+	Error string `json:"error"`
+}
+
+// writeJSON writes v as JSON with an ETag of the body, answering 304
+// Not Modified when it matches If-None-Match.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if status == http.StatusOK {
+		sum := sha256.Sum256(buf.Bytes())
+		etag := fmt.Sprintf(`"%x"`, sum[0:16])
+		w.Header().Set("ETag", etag)
+		for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write(buf.Bytes())
+	}
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, format string, args ...interface{}) {
+	writeJSON(w, r, status, &serverError{Error: fmt.Sprintf(format, args...)})
+}
+
+// paginate returns the page of items asked for by the page and
+// per_page parameters.
+func paginate(q url.Values, total int) (int, int, int, int, error) {
+	page, perPage := 1, DefaultPerPage
+	if s := q.Get("page"); s != "" {
+		i, err := strconv.Atoi(s)
+		if err != nil || i < 1 {
+			return 0, 0, 0, 0, fmt.Errorf("page %q is not a positive number", s)
+		}
+		page = i
+	}
+	if s := q.Get("per_page"); s != "" {
+		i, err := strconv.Atoi(s)
+		if err != nil || i < 1 || i > MaxPerPage {
+			return 0, 0, 0, 0, fmt.Errorf("per_page %q is not between 1 and %d", s, MaxPerPage)
+		}
+		perPage = i
+	}
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+	return page, perPage, start, end, nil
+}
+
+// matches reports if value matches a filter parameter, case
+// insensitive. An empty filter matches everything.
+func matches(filter string, values ...string) bool {
+	if filter == "" {
+		return true
+	}
+	for _, value := range values {
+		if strings.EqualFold(filter, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// guideMatches applies the /guides filters: status, type, owner
+// (email), group (id or name), subject (id or name), tag and q (a
+// substring of the name).
+func guideMatches(guide *Guide, q url.Values) bool {
+	subjects, tags := []string{}, []string{}
+	for _, subject := range guide.Subjects {
+		subjects = append(subjects, strInt(subject.Id), subject.Name)
+	}
+	for _, tag := range guide.Tags {
+		tags = append(tags, tag.Name)
+	}
+	return matches(q.Get("status"), guide.Status) &&
+		matches(q.Get("type"), guide.Type) &&
+		matches(q.Get("owner"), guide.Owner.Email) &&
+		matches(q.Get("group"), strInt(guide.Group.Id), guide.Group.Name) &&
+		matches(q.Get("subject"), subjects...) &&
+		matches(q.Get("tag"), tags...) &&
+		strings.Contains(strings.ToLower(guide.Name), strings.ToLower(q.Get("q")))
+}
+
+// newServerGuide lists a guide, counting the pages allowed by the
+// hidden policy as /guides/{id}/pages does.
+func newServerGuide(guide *Guide, hidden HiddenPolicy) *ServerGuide {
+	pageCount := 0
+	for _, page := range guide.Pages {
+		if hidden.Allows(page.Hidden != 0) {
+			pageCount++
+		}
+	}
+	return &ServerGuide{
+		GuideRecord: newGuideRecord(guide),
+		Subjects:    guide.Subjects,
+		Tags:        guide.Tags,
+		PageCount:   pageCount,
+	}
+}
+
+// visibleGuide returns a copy of a guide with the pages and boxes
+// allowed by the hidden policy.
+func visibleGuide(guide *Guide, hidden HiddenPolicy) *Guide {
+	g := *guide
+	g.Pages = []*Page{}
+	for _, page := range guide.Pages {
+		pageHidden := page.Hidden != 0
+		p := *page
+		p.Boxes = []*Box{}
+		for _, box := range page.Boxes {
+			if hidden.Allows(pageHidden || box.Hidden != 0) {
+				p.Boxes = append(p.Boxes, box)
+			}
+		}
+		if hidden.Allows(pageHidden) || len(p.Boxes) > 0 {
+			g.Pages = append(g.Pages, &p)
+		}
+	}
+	return &g
+}
+
+// ServeHTTP routes the requests, only GET and HEAD are allowed.
+//
+//	/                       what is being served
+//	/guides                 guides (filters status, type, owner, group, subject, tag, q)
+//	/guides/{id}            a guide with its pages, boxes and assets
+//	/guides/{id}/pages      the pages of a guide
+//	/assets/{id}            an asset and the boxes it is in
+//	/accounts               accounts (filter q on name or email)
+//	/subjects/{id}/guides   the guides with a subject (filters as /guides)
+//	/links                  the link report (filters status, category, guide_id, page_id)
+//
+// Lists are paginated with page and per_page.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, r, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	s.mu.RLock()
+	data := s.data
+	s.mu.RUnlock()
+	if data == nil {
+		writeError(w, r, http.StatusServiceUnavailable, "no export loaded")
+		return
+	}
+	q := r.URL.Query()
+	list := func(items []interface{}) {
+		page, perPage, start, end, err := paginate(q, len(items))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "%s", err)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, &ServerPage{Total: len(items), Page: page, PerPage: perPage, Items: items[start:end]})
+	}
+	id := func(s string) (int, bool) {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, "%q is not an id", s)
+			return 0, false
+		}
+		return i, true
+	}
+	listGuides := func(subjectId int) {
+		items := []interface{}{}
+		for _, guide := range data.lg.Guides {
+			if subjectId != 0 {
+				found := false
+				for _, subject := range guide.Subjects {
+					found = found || subject.Id == subjectId
+				}
+				if !found {
+					continue
+				}
+			}
+			if guideMatches(guide, q) {
+				items = append(items, newServerGuide(guide, s.opts.Hidden))
+			}
+		}
+		list(items)
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "":
+		writeJSON(w, r, http.StatusOK, map[string]interface{}{
+			"source":   data.source,
+			"modified": data.modified,
+			"loaded":   data.loaded,
+			"guides":   len(data.lg.Guides),
+			"endpoints": []string{"/guides", "/guides/{id}", "/guides/{id}/pages",
+				"/assets/{id}", "/accounts", "/subjects/{id}/guides", "/links"},
+		})
+	case len(parts) == 1 && parts[0] == "guides":
+		listGuides(0)
+	case len(parts) >= 2 && len(parts) <= 3 && parts[0] == "guides":
+		guideId, ok := id(parts[1])
+		if !ok {
+			return
+		}
+		guide, ok := data.guides[guideId]
+		if !ok {
+			writeError(w, r, http.StatusNotFound, "guide %d not found", guideId)
+			return
+		}
+		guide = visibleGuide(guide, s.opts.Hidden)
+		if len(parts) == 2 {
+			writeJSON(w, r, http.StatusOK, guide)
+			return
+		}
+		if parts[2] != "pages" {
+			writeError(w, r, http.StatusNotFound, "%s not found", r.URL.Path)
+			return
+		}
+		items := []interface{}{}
+		for _, page := range guide.Pages {
+			if s.opts.Hidden.Allows(page.Hidden != 0) {
+				items = append(items, newPageRecord(guide.Id, page))
+			}
+		}
+		list(items)
+	case len(parts) == 2 && parts[0] == "assets":
+		assetId, ok := id(parts[1])
+		if !ok {
+			return
+		}
+		asset, ok := data.assets[assetId]
+		if !ok {
+			writeError(w, r, http.StatusNotFound, "asset %d not found", assetId)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, asset)
+	case len(parts) == 1 && parts[0] == "accounts":
+		items := []interface{}{}
+		filter := strings.ToLower(q.Get("q"))
+		for _, account := range data.lg.Accounts {
+			name := strings.ToLower(account.FirstName + " " + account.LastName + " " + account.Email)
+			if strings.Contains(name, filter) {
+				items = append(items, account)
+			}
+		}
+		list(items)
+	case len(parts) == 3 && parts[0] == "subjects" && parts[2] == "guides":
+		subjectId, ok := id(parts[1])
+		if !ok {
+			return
+		}
+		if !data.subjects[subjectId] {
+			writeError(w, r, http.StatusNotFound, "subject %d not found", subjectId)
+			return
+		}
+		listGuides(subjectId)
+	case len(parts) == 1 && parts[0] == "links":
+		status := q.Get("status")
+		if alias, ok := linkStatusAliases[status]; ok {
+			status = alias
+		}
+		if status != "" && status != LinkStatusPublic && status != LinkStatusNonPublic {
+			writeError(w, r, http.StatusBadRequest, "status %q is not %s or %s", q.Get("status"), LinkStatusPublic, LinkStatusNonPublic)
+			return
+		}
+		items := []interface{}{}
+		for _, link := range data.links {
+			if matches(status, link.Status) && matches(q.Get("category"), link.Category) &&
+				matches(q.Get("guide_id"), link.GuideId) && matches(q.Get("page_id"), link.PageId) {
+				items = append(items, link)
+			}
+		}
+		list(items)
+	default:
+		writeError(w, r, http.StatusNotFound, "%s not found", r.URL.Path)
+	}
+}
+
+// Serve serves an export, the latest export in a directory which is
+// checked for new exports every opts.PollSeconds, or with opts.Source
+// "api" the LibGuides API refreshed every opts.PollSeconds, on opts.Addr.
+func Serve(srcName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	addr := opts.Addr
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	poll := opts.PollSeconds
+	if poll <= 0 {
+		poll = DefaultPollSeconds
+	}
+	s := NewServer(nil, opts)
+	if opts.FromAPI() {
+		// Refreshed from the API every poll, keeping the last good copy
+		if err := s.LoadAPI(); err != nil {
+			return err
+		}
+		go s.poll(time.Duration(poll)*time.Second, nil, s.LoadAPI)
+	} else if info, err := os.Stat(srcName); err == nil && info.IsDir() {
+		// Requests get 503 Service Unavailable until an export arrives
+		if _, err := s.Reload(srcName); err != nil {
+			opts.Logf("%s", err)
+		}
+		go s.Watch(srcName, time.Duration(poll)*time.Second, nil)
+	} else if err := s.LoadFile(srcName); err != nil {
+		return err
+	}
+	opts.Logf("listening on http://%s", addr)
+	return http.ListenAndServe(addr, s)
+}
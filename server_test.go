@@ -0,0 +1,258 @@
+// server_test.go provides tests for server.go
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func serverFixture() *LibGuides {
+	jane := Owner{Id: 1, FirstName: "Jane", LastName: "Doe", Email: "jane@example.edu"}
+	shared := &Asset{Id: 40, Name: "Web of Science", Url: "https://www.webofscience.com"}
+	return &LibGuides{
+		Accounts: []*Account{
+			{Id: 1, FirstName: "Jane", LastName: "Doe", Email: "jane@example.edu"},
+			{Id: 2, FirstName: "John", LastName: "Roe", Email: "john@example.edu"},
+		},
+		Subjects: []*Subject{{Id: 5, Name: "Chemistry"}, {Id: 6, Name: "Physics"}},
+		Guides: []*Guide{
+			{Id: 1, Name: "Chemistry Databases", Status: "Published", Owner: jane,
+				Subjects: []*Subject{{Id: 5, Name: "Chemistry"}},
+				Tags:     []*Tag{{Id: 7, Name: "databases"}},
+				Pages: []*Page{
+					{Id: 10, Name: "Home", Boxes: []*Box{{Id: 20, Assets: []*Asset{
+						shared,
+						{Id: 41, Name: "Notes", Url: "file:///C:/notes.docx"},
+					}}}},
+					{Id: 11, Name: "Draft", Hidden: 1, Boxes: []*Box{{Id: 21, Assets: []*Asset{{Id: 42, Name: "Secret"}}}}},
+				}},
+			{Id: 2, Name: "Physics", Status: "Private",
+				Pages: []*Page{{Id: 12, Name: "Home", Boxes: []*Box{{Id: 22, Panes: []*Pane{{Assets: []*Asset{shared}}}}}}}},
+			{Id: 3, Name: "Chemistry Patents", Status: "Published",
+				Subjects: []*Subject{{Id: 5, Name: "Chemistry"}}},
+		},
+	}
+}
+
+// getJSON requests path decoding the JSON response into v.
+func getJSON(t *testing.T, ts *httptest.Server, path string, expectedStatus int, v interface{}) http.Header {
+	res, err := http.Get(ts.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != expectedStatus {
+		t.Errorf("%s: expected status %d, got %d", path, expectedStatus, res.StatusCode)
+	}
+	if v != nil {
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			t.Errorf("%s: %s", path, err)
+		}
+	}
+	return res.Header
+}
+
+type guidesPage struct {
+	Total   int            `json:"total"`
+	Page    int            `json:"page"`
+	PerPage int            `json:"per_page"`
+	Items   []*ServerGuide `json:"items"`
+}
+
+func TestServer(t *testing.T) {
+	ts := httptest.NewServer(NewServer(serverFixture(), nil))
+	defer ts.Close()
+
+	guides := new(guidesPage)
+	getJSON(t, ts, "/guides", http.StatusOK, guides)
+	expectedInt(t, 3, guides.Total)
+	expectedInt(t, DefaultPerPage, guides.PerPage)
+	if len(guides.Items) == 3 {
+		expectedString(t, "Chemistry Databases", guides.Items[0].Name)
+		expectedString(t, "jane@example.edu", guides.Items[0].OwnerEmail)
+		// The hidden Draft page isn't counted
+		expectedInt(t, 1, guides.Items[0].PageCount)
+		expectedString(t, "databases", guides.Items[0].Tags[0].Name)
+	}
+	guides = new(guidesPage)
+	getJSON(t, ts, "/guides?per_page=2&page=2", http.StatusOK, guides)
+	expectedInt(t, 3, guides.Total)
+	expectedInt(t, 1, len(guides.Items))
+	guides = new(guidesPage)
+	getJSON(t, ts, "/guides?page=9", http.StatusOK, guides)
+	expectedInt(t, 0, len(guides.Items))
+	guides = new(guidesPage)
+	getJSON(t, ts, "/guides?status=published&q=chem&tag=DATABASES", http.StatusOK, guides)
+	expectedInt(t, 1, guides.Total)
+	guides = new(guidesPage)
+	getJSON(t, ts, "/subjects/5/guides", http.StatusOK, guides)
+	expectedInt(t, 2, guides.Total)
+	guides = new(guidesPage)
+	getJSON(t, ts, "/subjects/6/guides", http.StatusOK, guides)
+	expectedInt(t, 0, guides.Total)
+
+	guide := new(Guide)
+	getJSON(t, ts, "/guides/1", http.StatusOK, guide)
+	expectedInt(t, 1, len(guide.Pages))
+	pages := &ServerPage{}
+	getJSON(t, ts, "/guides/1/pages", http.StatusOK, pages)
+	expectedInt(t, 1, pages.Total)
+
+	asset := new(ServerAsset)
+	getJSON(t, ts, "/assets/40", http.StatusOK, asset)
+	expectedString(t, "Web of Science", asset.Name)
+	expectedInt(t, 2, len(asset.Locations))
+	if len(asset.Locations) == 2 {
+		expectedInt(t, 22, asset.Locations[1].BoxId)
+		expectedInt(t, 1, asset.Locations[1].Pane)
+	}
+
+	accounts := &ServerPage{}
+	getJSON(t, ts, "/accounts?q=ROE", http.StatusOK, accounts)
+	expectedInt(t, 1, accounts.Total)
+
+	links := struct {
+		Total int           `json:"total"`
+		Items []*ServerLink `json:"items"`
+	}{}
+	getJSON(t, ts, "/links?status=nonpublic", http.StatusOK, &links)
+	expectedInt(t, 1, links.Total)
+	if links.Total == 1 {
+		expectedString(t, "file:///C:/notes.docx", links.Items[0].Url)
+		expectedString(t, LinkLocalFile, links.Items[0].Category)
+	}
+	getJSON(t, ts, "/links?status=broken", http.StatusOK, &links)
+	expectedInt(t, 1, links.Total)
+	getJSON(t, ts, "/links?status=public&guide_id=2", http.StatusOK, &links)
+	expectedInt(t, 1, links.Total)
+	getJSON(t, ts, "/links?status=ok&guide_id=2", http.StatusOK, &links)
+	expectedInt(t, 1, links.Total)
+
+	for _, path := range []string{"/guides/99", "/guides/x", "/guides/1/boxes", "/assets/42", "/subjects/99/guides", "/nothing"} {
+		getJSON(t, ts, path, http.StatusNotFound, nil)
+	}
+	for _, path := range []string{"/guides?page=0", "/guides?per_page=5000", "/links?status=dead"} {
+		e := new(serverError)
+		getJSON(t, ts, path, http.StatusBadRequest, e)
+		if e.Error == "" {
+			t.Errorf("%s: expected an error message", path)
+		}
+	}
+
+	// ETags
+	etag := getJSON(t, ts, "/guides/1", http.StatusOK, nil).Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected an ETag")
+	}
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/guides/1", nil)
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	expectedInt(t, http.StatusNotModified, res.StatusCode)
+	if etag == getJSON(t, ts, "/guides/2", http.StatusOK, nil).Get("ETag") {
+		t.Errorf("expected different ETags for different guides")
+	}
+
+	res, err = http.Post(ts.URL+"/guides", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	expectedInt(t, http.StatusMethodNotAllowed, res.StatusCode)
+
+	// Hidden content is served according to the hidden policy
+	ts2 := httptest.NewServer(NewServer(serverFixture(), &Options{Hidden: HiddenInclude}))
+	defer ts2.Close()
+	getJSON(t, ts2, "/assets/42", http.StatusOK, nil)
+}
+
+func TestServerReload(t *testing.T) {
+	dir := filepath.Join("testout", "serve")
+	os.RemoveAll(dir)
+	if err := os.MkdirAll(dir, 0775); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(nil, nil)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	getJSON(t, ts, "/guides", http.StatusServiceUnavailable, nil)
+	if _, err := s.Reload(dir); err == nil {
+		t.Errorf("expected an error for a directory without exports")
+	}
+
+	lg := serverFixture()
+	src, err := lg.ToXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	older := filepath.Join(dir, "LibGuides_export_1.xml")
+	if err := ioutil.WriteFile(older, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "README.txt"), []byte("not an export"), 0644)
+	if loaded, err := s.Reload(dir); err != nil || !loaded {
+		t.Fatalf("expected the export to be loaded, %v %s", loaded, err)
+	}
+	if loaded, _ := s.Reload(dir); loaded {
+		t.Errorf("expected an unchanged export not to be reloaded")
+	}
+	guides := new(guidesPage)
+	getJSON(t, ts, "/guides", http.StatusOK, guides)
+	expectedInt(t, 3, guides.Total)
+
+	lg.Guides = lg.Guides[0:1]
+	src, _ = lg.ToXML()
+	newer := filepath.Join(dir, "LibGuides_export_2.xml")
+	if err := ioutil.WriteFile(newer, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(newer, later, later)
+	if loaded, err := s.Reload(dir); err != nil || !loaded {
+		t.Fatalf("expected the newer export to be loaded, %v %s", loaded, err)
+	}
+	guides = new(guidesPage)
+	getJSON(t, ts, "/guides", http.StatusOK, guides)
+	expectedInt(t, 1, guides.Total)
+}
@@ -0,0 +1,731 @@
+// site.go renders a LibGuides export as a static HTML or Markdown site for
+// archiving.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmlTemplate "html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	textTemplate "text/template"
+
+	// 3rd Party Packages
+	"golang.org/x/net/html"
+)
+
+// siteCSS lays out the box columns of the HTML site
+const siteCSS = `body { font-family: sans-serif; margin: 1em 2em; }
+nav ul { padding-left: 1.2em; }
+.layout { display: flex; gap: 2em; }
+.layout nav { flex: 0 0 14em; }
+.layout main { flex: 1; }
+.columns { display: flex; gap: 1em; align-items: flex-start; }
+.column { flex: 1; min-width: 0; }
+.box { border: 1px solid #ccc; padding: 0 1em; margin-bottom: 1em; }
+`
+
+// sitePage is a page in the navigation tree of a guide.
+type sitePage struct {
+	Page     *Page
+	Href     string
+	Current  bool
+	Children []*sitePage
+}
+
+// siteAsset is an asset with its description and URL rewritten.
+type siteAsset struct {
+	Name        string
+	Url         string
+	Description string
+}
+
+// siteBox is a box with the assets of its panes in order.
+type siteBox struct {
+	Box    *Box
+	Assets []*siteAsset
+}
+
+// siteColumn is a column of boxes on a page.
+type siteColumn struct {
+	Column int
+	Boxes  []*siteBox
+}
+
+// siteGuides lists guides under a subject or tag name.
+type siteGuides struct {
+	Name   string
+	Guides []*Guide
+}
+
+// siteTree is a level of the page tree in a Markdown page.
+type siteTree struct {
+	Nodes []*sitePage
+	Depth int
+}
+
+// siteBuilder renders a LibGuides object into a directory.
+type siteBuilder struct {
+	lg       *LibGuides
+	opts     *Options
+	destDir  string
+	markdown bool
+	ext      string
+	guides   map[int]*Guide
+	pages    map[int]*Page
+	// pageGuides maps a page id to the id of its guide
+	pageGuides map[int]int
+	hosts      map[string]bool
+	// paths maps a normalized LibGuides URL to the local file
+	paths map[string]string
+}
+
+// normalizeURL returns a key for matching URLs ignoring the scheme,
+// case of the host, trailing slash and fragment.
+func normalizeURL(u *url.URL) string {
+	key := strings.ToLower(u.Host) + strings.TrimSuffix(u.Path, "/")
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return key
+}
+
+func (b *siteBuilder) guidePath(guide *Guide) string {
+	return fmt.Sprintf("%d/index%s", guide.Id, b.ext)
+}
+
+func (b *siteBuilder) pagePath(guideId int, page *Page) string {
+	return fmt.Sprintf("%d/%d%s", guideId, page.Id, b.ext)
+}
+
+// localPath returns the local file for a LibGuides link, relative to
+// the site's root.
+func (b *siteBuilder) localPath(link string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	if p, ok := b.paths[normalizeURL(u)]; ok {
+		return p, true
+	}
+	if b.hosts[strings.ToLower(u.Host)] && strings.HasSuffix(u.Path, "/c.php") {
+		q := u.Query()
+		if pageId, err := strconv.Atoi(q.Get("p")); err == nil {
+			if page, ok := b.pages[pageId]; ok {
+				return b.pagePath(b.pageGuides[pageId], page), true
+			}
+		}
+		if guideId, err := strconv.Atoi(q.Get("g")); err == nil {
+			if guide, ok := b.guides[guideId]; ok {
+				return b.guidePath(guide), true
+			}
+		}
+	}
+	return "", false
+}
+
+// rewriteLink returns a local link for a LibGuides URL from a file at
+// the given depth below the site's root, other links are unchanged.
+func (b *siteBuilder) rewriteLink(link string, depth int) string {
+	if p, ok := b.localPath(link); ok {
+		return strings.Repeat("../", depth) + p
+	}
+	return link
+}
+
+// isScriptURL reports if a link runs script or carries a document
+// (javascript:, vbscript: and data: other than images), browsers
+// ignore the white space and control characters in a scheme.
+func isScriptURL(link string) bool {
+	scheme := strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, link))
+	return strings.HasPrefix(scheme, "javascript:") || strings.HasPrefix(scheme, "vbscript:") ||
+		(strings.HasPrefix(scheme, "data:") && !strings.HasPrefix(scheme, "data:image/"))
+}
+
+// unsafeElements are left out of the site with their content, they run
+// script, embed other documents or change how links resolve.
+var unsafeElements = map[string]bool{
+	"script": true, "object": true, "embed": true, "applet": true,
+	"base": true, "meta": true, "link": true, "frame": true, "frameset": true,
+}
+
+// unsafeAttributes are left out of the site along with the event
+// handlers (on...), srcdoc holds a whole document.
+var unsafeAttributes = map[string]bool{
+	"srcdoc": true, "formaction": true, "xlink:href": true,
+}
+
+// urlAttributes hold links which are checked with isScriptURL.
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "action": true, "data": true,
+	"poster": true, "background": true, "cite": true,
+}
+
+// isUnsafeElement reports if a node is one of the unsafeElements.
+func isUnsafeElement(n *html.Node) bool {
+	return n.Type == html.ElementNode && unsafeElements[strings.ToLower(n.Data)]
+}
+
+// rewriteDescription rewrites the LibGuides links in a description.
+// The site's templates don't escape descriptions so the unsafeElements,
+// unsafeAttributes, event handlers (on...) and script links are removed.
+func (b *siteBuilder) rewriteDescription(description string, depth int) string {
+	description = decodeDescription(description)
+	if strings.TrimSpace(description) == "" {
+		return ""
+	}
+	nodes, err := parseDescription(description)
+	if err != nil {
+		return htmlTemplate.HTMLEscapeString(description)
+	}
+	changed := false
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			attrs := n.Attr[:0]
+			for _, a := range n.Attr {
+				key := strings.ToLower(a.Key)
+				if strings.HasPrefix(key, "on") || unsafeAttributes[key] || (urlAttributes[key] && isScriptURL(a.Val)) {
+					changed = true
+					continue
+				}
+				if key == "href" || key == "src" {
+					if s := b.rewriteLink(a.Val, depth); s != a.Val {
+						a.Val = s
+						changed = true
+					}
+				}
+				attrs = append(attrs, a)
+			}
+			n.Attr = attrs
+		}
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			if isUnsafeElement(c) {
+				n.RemoveChild(c)
+				changed = true
+			} else {
+				walk(c)
+			}
+			c = next
+		}
+	}
+	kept := nodes[:0]
+	for _, n := range nodes {
+		if isUnsafeElement(n) {
+			changed = true
+			continue
+		}
+		walk(n)
+		kept = append(kept, n)
+	}
+	nodes = kept
+	if !changed {
+		return description
+	}
+	buf := new(bytes.Buffer)
+	for _, n := range nodes {
+		if err := html.Render(buf, n); err != nil {
+			return htmlTemplate.HTMLEscapeString(description)
+		}
+	}
+	return buf.String()
+}
+
+// visiblePages returns the pages of a guide allowed by the hidden policy.
+func (b *siteBuilder) visiblePages(guide *Guide) []*Page {
+	pages := []*Page{}
+	for _, page := range guide.Pages {
+		if b.opts.Hidden.Allows(page.Hidden != 0) {
+			pages = append(pages, page)
+		}
+	}
+	return pages
+}
+
+// pageTree builds the page hierarchy of a guide from the parent page
+// ids, ordered by position. current marks the page being rendered.
+func (b *siteBuilder) pageTree(guide *Guide, current *Page, depth int) []*sitePage {
+	pages := b.visiblePages(guide)
+	nodes := map[int]*sitePage{}
+	for _, page := range pages {
+		nodes[page.Id] = &sitePage{
+			Page:    page,
+			Href:    strings.Repeat("../", depth) + b.pagePath(guide.Id, page),
+			Current: page == current,
+		}
+	}
+	roots := []*sitePage{}
+	for _, page := range pages {
+		if parent, ok := nodes[page.ParentPageId]; ok && page.ParentPageId != page.Id {
+			parent.Children = append(parent.Children, nodes[page.Id])
+		} else {
+			roots = append(roots, nodes[page.Id])
+		}
+	}
+	var order func([]*sitePage)
+	order = func(nodes []*sitePage) {
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[i].Page.Position < nodes[j].Page.Position
+		})
+		for _, node := range nodes {
+			order(node.Children)
+		}
+	}
+	order(roots)
+	return roots
+}
+
+// columns lays out the boxes of a page by column and position.
+func (b *siteBuilder) columns(page *Page, depth int) []*siteColumn {
+	byColumn := map[int]*siteColumn{}
+	columns := []*siteColumn{}
+	for _, box := range page.Boxes {
+		if !b.opts.Hidden.Allows(page.Hidden != 0 || box.Hidden != 0) {
+			continue
+		}
+		col := box.Column
+		if col < 1 {
+			col = 1
+		}
+		column, ok := byColumn[col]
+		if !ok {
+			column = &siteColumn{Column: col}
+			byColumn[col] = column
+			columns = append(columns, column)
+		}
+		sb := &siteBox{Box: box}
+		assets := append([]*Asset{}, box.Assets...)
+		for _, pane := range box.Panes {
+			assets = append(assets, pane.Assets...)
+		}
+		for _, asset := range assets {
+			sb.Assets = append(sb.Assets, &siteAsset{
+				Name:        asset.Name,
+				Url:         b.rewriteLink(asset.Url, depth),
+				Description: b.rewriteDescription(asset.Description, depth),
+			})
+		}
+		column.Boxes = append(column.Boxes, sb)
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Column < columns[j].Column })
+	for _, column := range columns {
+		sort.SliceStable(column.Boxes, func(i, j int) bool {
+			return column.Boxes[i].Box.Position < column.Boxes[j].Box.Position
+		})
+	}
+	return columns
+}
+
+// indexes groups the guides by subject and by tag.
+func (b *siteBuilder) indexes() ([]*siteGuides, []*siteGuides) {
+	group := func(names func(*Guide) []string) []*siteGuides {
+		byName := map[string]*siteGuides{}
+		list := []*siteGuides{}
+		for _, guide := range b.lg.Guides {
+			for _, name := range names(guide) {
+				sg, ok := byName[name]
+				if !ok {
+					sg = &siteGuides{Name: name}
+					byName[name] = sg
+					list = append(list, sg)
+				}
+				sg.Guides = append(sg.Guides, guide)
+			}
+		}
+		sort.Slice(list, func(i, j int) bool { return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name) })
+		return list
+	}
+	subjects := group(func(guide *Guide) []string {
+		names := []string{}
+		for _, subject := range guide.Subjects {
+			names = append(names, subject.Name)
+		}
+		return names
+	})
+	tags := group(func(guide *Guide) []string {
+		names := []string{}
+		for _, tag := range guide.Tags {
+			names = append(names, tag.Name)
+		}
+		return names
+	})
+	return subjects, tags
+}
+
+// yamlString quotes a string for front matter, a JSON string is a
+// valid YAML string.
+func yamlString(s string) string {
+	src, _ := json.Marshal(s)
+	return string(src)
+}
+
+var siteFuncs = map[string]interface{}{
+	"yaml": yamlString,
+	// safe is only used for descriptions from rewriteDescription
+	"safe": func(s string) htmlTemplate.HTML { return htmlTemplate.HTML(s) },
+	"indent": func(depth int) string {
+		return strings.Repeat("  ", depth)
+	},
+	"inc": func(i int) int { return i + 1 },
+	"subtree": func(nodes []*sitePage, depth int) *siteTree {
+		return &siteTree{Nodes: nodes, Depth: depth}
+	},
+	"guidePath": func(guide *Guide) string {
+		return fmt.Sprintf("%d/index", guide.Id)
+	},
+	"names": func(v interface{}) string {
+		names := []string{}
+		switch items := v.(type) {
+		case []*Subject:
+			for _, item := range items {
+				names = append(names, yamlString(item.Name))
+			}
+		case []*Tag:
+			for _, item := range items {
+				names = append(names, yamlString(item.Name))
+			}
+		}
+		return "[" + strings.Join(names, ", ") + "]"
+	},
+}
+
+var siteHTML = htmlTemplate.Must(htmlTemplate.New("site").Funcs(siteFuncs).Parse(`
+{{- define "tree" }}<ul>
+{{- range . }}
+<li>{{ if .Current }}<strong>{{ .Page.Name }}</strong>{{ else }}<a href="{{ .Href }}">{{ .Page.Name }}</a>{{ end }}
+{{- if .Children }}{{ template "tree" .Children }}{{ end }}</li>
+{{- end }}
+</ul>{{ end }}
+{{- define "head" }}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{ . }}</title>
+{{ end }}
+{{- define "index" }}{{ template "head" .Title }}<link rel="stylesheet" href="site.css">
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+<h2>Guides by subject</h2>
+{{ range .Subjects }}<h3>{{ .Name }}</h3>
+<ul>{{ range .Guides }}<li><a href="{{ guidePath . }}.html">{{ .Name }}</a></li>{{ end }}</ul>
+{{ end }}
+<h2>Guides by tag</h2>
+{{ range .Tags }}<h3>{{ .Name }}</h3>
+<ul>{{ range .Guides }}<li><a href="{{ guidePath . }}.html">{{ .Name }}</a></li>{{ end }}</ul>
+{{ end }}
+<h2>All guides</h2>
+<ul>{{ range .Guides }}<li><a href="{{ guidePath . }}.html">{{ .Name }}</a> ({{ .Status }})</li>{{ end }}</ul>
+</body>
+</html>
+{{ end }}
+{{- define "guide" }}{{ template "head" .Guide.Name }}<link rel="stylesheet" href="../site.css">
+</head>
+<body>
+<p><a href="../index.html">All guides</a></p>
+<h1>{{ .Guide.Name }}</h1>
+{{ if .Description }}<div class="description">{{ safe .Description }}</div>{{ end }}
+<dl>
+<dt>Owner</dt><dd>{{ .Owner }}</dd>
+<dt>Status</dt><dd>{{ .Guide.Status }}</dd>
+{{ if .Guide.Group.Name }}<dt>Group</dt><dd>{{ .Guide.Group.Name }}</dd>{{ end }}
+<dt>Subjects</dt><dd>{{ range $i, $s := .Guide.Subjects }}{{ if $i }}, {{ end }}{{ $s.Name }}{{ end }}</dd>
+<dt>Tags</dt><dd>{{ range $i, $t := .Guide.Tags }}{{ if $i }}, {{ end }}{{ $t.Name }}{{ end }}</dd>
+<dt>Modified</dt><dd>{{ .Guide.Modified }}</dd>
+<dt>Original</dt><dd><a href="{{ .Guide.Url }}">{{ .Guide.Url }}</a></dd>
+</dl>
+<h2>Pages</h2>
+<nav>{{ template "tree" .Tree }}</nav>
+</body>
+</html>
+{{ end }}
+{{- define "page" }}{{ template "head" (printf "%s: %s" .Guide.Name .Page.Name) }}<link rel="stylesheet" href="../site.css">
+</head>
+<body>
+<p><a href="../index.html">All guides</a> / <a href="index.html">{{ .Guide.Name }}</a></p>
+<h1>{{ .Guide.Name }}</h1>
+<div class="layout">
+<nav>{{ template "tree" .Tree }}</nav>
+<main>
+<h2>{{ .Page.Name }}</h2>
+{{ if .Page.Redirect }}<p>This page redirects to <a href="{{ .Redirect }}">{{ .Page.Redirect }}</a></p>{{ end }}
+{{ if .Description }}<div class="description">{{ safe .Description }}</div>{{ end }}
+<div class="columns">
+{{- range .Columns }}
+<div class="column">
+{{- range .Boxes }}
+<section class="box">
+<h3>{{ .Box.Name }}</h3>
+{{- range .Assets }}
+<div class="asset">
+{{ if .Url }}<p><a href="{{ .Url }}">{{ or .Name .Url }}</a></p>{{ end }}
+{{ safe .Description }}
+</div>
+{{- end }}
+</section>
+{{- end }}
+</div>
+{{- end }}
+</div>
+</main>
+</div>
+{{ if .Page.Url }}<p>Archived from <a href="{{ .Page.Url }}">{{ .Page.Url }}</a>, last modified {{ .Page.Modified }}</p>{{ end }}
+</body>
+</html>
+{{ end }}`))
+
+var siteMarkdown = textTemplate.Must(textTemplate.New("site").Funcs(siteFuncs).Parse(`
+{{- define "tree" }}{{ $depth := .Depth }}{{ range .Nodes }}{{ indent $depth }}- {{ if .Current }}**{{ .Page.Name }}**{{ else }}[{{ .Page.Name }}]({{ .Href }}){{ end }}
+{{ if .Children }}{{ template "tree" (subtree .Children (inc $depth)) }}{{ end }}{{ end }}{{ end }}
+{{- define "index" }}---
+title: {{ yaml .Title }}
+---
+
+# {{ .Title }}
+
+## Guides by subject
+{{ range .Subjects }}
+### {{ .Name }}
+
+{{ range .Guides }}- [{{ .Name }}]({{ guidePath . }}.md)
+{{ end }}{{ end }}
+## Guides by tag
+{{ range .Tags }}
+### {{ .Name }}
+
+{{ range .Guides }}- [{{ .Name }}]({{ guidePath . }}.md)
+{{ end }}{{ end }}
+## All guides
+
+{{ range .Guides }}- [{{ .Name }}]({{ guidePath . }}.md) ({{ .Status }})
+{{ end }}{{ end }}
+{{- define "guide" }}---
+title: {{ yaml .Guide.Name }}
+guide_id: {{ .Guide.Id }}
+owner: {{ yaml .Owner }}
+status: {{ yaml .Guide.Status }}
+group: {{ yaml .Guide.Group.Name }}
+subjects: {{ names .Guide.Subjects }}
+tags: {{ names .Guide.Tags }}
+url: {{ yaml .Guide.Url }}
+modified: {{ yaml .Guide.Modified }}
+---
+
+[All guides](../index.md)
+
+# {{ .Guide.Name }}
+{{ if .Description }}
+{{ .Description }}
+{{ end }}
+## Pages
+
+{{ template "tree" (subtree .Tree 0) }}{{ end }}
+{{- define "page" }}---
+title: {{ yaml .Page.Name }}
+guide: {{ yaml .Guide.Name }}
+guide_id: {{ .Guide.Id }}
+page_id: {{ .Page.Id }}
+parent_page_id: {{ .Page.ParentPageId }}
+position: {{ .Page.Position }}
+url: {{ yaml .Page.Url }}
+modified: {{ yaml .Page.Modified }}
+---
+
+[All guides](../index.md) / [{{ .Guide.Name }}](index.md)
+
+# {{ .Page.Name }}
+
+{{ template "tree" (subtree .Tree 0) }}
+{{- if .Page.Redirect }}
+This page redirects to <{{ .Redirect }}>
+{{ end }}
+{{- if .Description }}
+{{ .Description }}
+{{ end }}
+{{- range .Columns }}{{ $column := .Column }}
+{{- range .Boxes }}
+## {{ .Box.Name }}
+
+<!-- column {{ $column }}, position {{ .Box.Position }} -->
+{{ range .Assets }}
+{{ if .Url }}[{{ or .Name .Url }}]({{ .Url }})
+{{ end }}
+{{- if .Description }}
+{{ .Description }}
+{{ end }}{{ end }}{{ end }}{{ end }}{{ end }}`))
+
+// render executes the named template writing the file relative to
+// the site's root.
+func (b *siteBuilder) render(name string, fName string, data interface{}) error {
+	buf := new(bytes.Buffer)
+	var err error
+	if b.markdown {
+		err = siteMarkdown.ExecuteTemplate(buf, name, data)
+	} else {
+		err = siteHTML.ExecuteTemplate(buf, name, data)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", fName, err)
+	}
+	destName := filepath.Join(b.destDir, filepath.FromSlash(fName))
+	if err := os.MkdirAll(filepath.Dir(destName), 0775); err != nil {
+		return err
+	}
+	return WriteDestinationWithOptions(destName, buf.Bytes(), &b.opts.WriteOptions)
+}
+
+// GenerateSite renders a LibGuides object into destDir as a static
+// site for archiving. Each guide is a directory named by its id with an
+// index of its pages and a file per page. Pages keep their hierarchy
+// (parent page id and position) and boxes their column layout. An index
+// of the guides by subject and tag is written to the root. Links to
+// guides and pages in the export, by URL or as c.php?g=...&p=... links,
+// are rewritten to the local files. Pages and boxes are included
+// according to the hidden policy in opts. When opts.Markdown is true
+// the pages are Markdown files with front matter rather than HTML.
+func GenerateSite(lg *LibGuides, destDir string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	b := &siteBuilder{
+		lg:         lg,
+		opts:       opts,
+		destDir:    destDir,
+		markdown:   opts.Markdown,
+		ext:        ".html",
+		guides:     map[int]*Guide{},
+		pages:      map[int]*Page{},
+		pageGuides: map[int]int{},
+		hosts:      map[string]bool{},
+		paths:      map[string]string{},
+	}
+	if b.markdown {
+		b.ext = ".md"
+	}
+	if prefix, err := opts.sitePrefix(lg); err == nil {
+		if u, err := url.Parse(prefix); err == nil && u.Host != "" {
+			b.hosts[strings.ToLower(u.Host)] = true
+		}
+	}
+	addURL := func(link string, p string) {
+		if u, err := url.Parse(strings.TrimSpace(link)); err == nil && u.Host != "" {
+			b.hosts[strings.ToLower(u.Host)] = true
+			b.paths[normalizeURL(u)] = p
+		}
+	}
+	for _, guide := range lg.Guides {
+		b.guides[guide.Id] = guide
+		addURL(guide.Url, b.guidePath(guide))
+		for _, page := range b.visiblePages(guide) {
+			b.pages[page.Id] = page
+			b.pageGuides[page.Id] = guide.Id
+			addURL(page.Url, b.pagePath(guide.Id, page))
+		}
+	}
+	if err := os.MkdirAll(destDir, 0775); err != nil {
+		return err
+	}
+	title := "LibGuides"
+	if lg.Site != nil && lg.Site.Name != "" {
+		title = lg.Site.Name
+	}
+	subjects, tags := b.indexes()
+	guides := append([]*Guide{}, lg.Guides...)
+	sort.SliceStable(guides, func(i, j int) bool { return strings.ToLower(guides[i].Name) < strings.ToLower(guides[j].Name) })
+	if err := b.render("index", "index"+b.ext, map[string]interface{}{
+		"Title": title, "Subjects": subjects, "Tags": tags, "Guides": guides,
+	}); err != nil {
+		return err
+	}
+	if !b.markdown {
+		if err := WriteDestinationWithOptions(filepath.Join(destDir, "site.css"), []byte(siteCSS), &opts.WriteOptions); err != nil {
+			return err
+		}
+	}
+	for _, guide := range lg.Guides {
+		if err := b.render("guide", b.guidePath(guide), map[string]interface{}{
+			"Guide":       guide,
+			"Owner":       opts.ownerName(guide.Owner),
+			"Description": b.rewriteDescription(guide.Description, 1),
+			"Tree":        b.pageTree(guide, nil, 1),
+		}); err != nil {
+			return err
+		}
+		for _, page := range b.visiblePages(guide) {
+			if err := b.render("page", b.pagePath(guide.Id, page), map[string]interface{}{
+				"Guide":       guide,
+				"Page":        page,
+				"Redirect":    b.rewriteLink(page.Redirect, 1),
+				"Description": b.rewriteDescription(page.Description, 1),
+				"Tree":        b.pageTree(guide, page, 1),
+				"Columns":     b.columns(page, 1),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	opts.Logf("wrote %d guides to %q", len(lg.Guides), destDir)
+	return nil
+}
+
+// SiteReport reads a LibGuides export and renders it as a static site
+// in destDir, see GenerateSite.
+func SiteReport(srcName string, destDir string, opts *Options) error {
+	if destDir == StdIO || destDir == "" {
+		return fmt.Errorf("a site is written to a directory, not standard output")
+	}
+	if opts == nil {
+		opts = new(Options)
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	lg, err := opts.ReadLibGuides(srcName)
+	if err != nil {
+		return err
+	}
+	return GenerateSite(lg, destDir, opts)
+}
@@ -0,0 +1,167 @@
+// site_test.go provides tests for site.go
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func siteFixture() *LibGuides {
+	return &LibGuides{
+		Site: &Site{Name: "Example Library", Domain: "libguides.example.edu"},
+		Guides: []*Guide{
+			{
+				Id: 1, Name: "Engineering", Status: "Published",
+				Url:      "https://libguides.example.edu/engineering",
+				Subjects: []*Subject{{Id: 5, Name: "Engineering"}},
+				Tags:     []*Tag{{Id: 7, Name: "patents"}},
+				Pages: []*Page{
+					{Id: 12, Name: "Patents", ParentPageId: 10, Position: 2,
+						Url: "https://libguides.example.edu/engineering/patents"},
+					{Id: 11, Name: "Standards", ParentPageId: 10, Position: 1},
+					{Id: 10, Name: "Home", Position: 1, Boxes: []*Box{
+						{Id: 21, Name: "Right", Column: 2, Position: 1},
+						{Id: 22, Name: "Second", Column: 1, Position: 2, Assets: []*Asset{
+							{Id: 31, Name: "Patents page", Url: "http://libguides.example.edu/c.php?g=1&p=12"},
+						}},
+						{Id: 23, Name: "First", Column: 1, Position: 1, Assets: []*Asset{
+							{Id: 32, Description: `<p>See <a href="https://libguides.example.edu/chemistry">Chemistry</a> and <a href="https://example.org/">elsewhere</a>.</p>`},
+						}},
+					}},
+					{Id: 13, Name: "Draft", Hidden: 1, Position: 3},
+				},
+			},
+			{
+				Id: 2, Name: "Chemistry", Status: "Published",
+				Url:      "https://libguides.example.edu/chemistry",
+				Subjects: []*Subject{{Id: 5, Name: "Engineering"}, {Id: 6, Name: "Chemistry"}},
+				Pages:    []*Page{{Id: 20, Name: "Home"}},
+			},
+		},
+	}
+}
+
+func readSiteFile(t *testing.T, name string) string {
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(src)
+}
+
+func expectedContains(t *testing.T, s string, substrings ...string) {
+	for _, substring := range substrings {
+		if !strings.Contains(s, substring) {
+			t.Errorf("expected %q in\n%s", substring, s)
+		}
+	}
+}
+
+func TestGenerateSite(t *testing.T) {
+	lg := siteFixture()
+	destDir := filepath.Join("testout", "site")
+	os.RemoveAll(destDir)
+	if err := GenerateSite(lg, destDir, new(Options)); err != nil {
+		t.Fatal(err)
+	}
+	index := readSiteFile(t, filepath.Join(destDir, "index.html"))
+	expectedContains(t, index, "<h1>Example Library</h1>", `<a href="1/index.html">Engineering</a>`, "<h3>patents</h3>")
+	if strings.Index(index, "<h3>Chemistry</h3>") > strings.Index(index, "<h3>Engineering</h3>") {
+		t.Errorf("expected subjects in alphabetical order\n%s", index)
+	}
+
+	home := readSiteFile(t, filepath.Join(destDir, "1", "10.html"))
+	expectedContains(t, home,
+		`<a href="../1/12.html">Patents page</a>`,
+		`<a href="../2/index.html">Chemistry</a>`,
+		`<a href="https://example.org/">elsewhere</a>`,
+		`<strong>Home</strong>`)
+	// Child pages are nested by position, boxes in column then position order
+	if !(strings.Index(home, "Standards") < strings.Index(home, "Patents</a>")) {
+		t.Errorf("expected Standards before Patents in the page tree\n%s", home)
+	}
+	if !(strings.Index(home, "<h3>First</h3>") < strings.Index(home, "<h3>Second</h3>") &&
+		strings.Index(home, "<h3>Second</h3>") < strings.Index(home, "<h3>Right</h3>")) {
+		t.Errorf("expected boxes First, Second then Right\n%s", home)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "1", "13.html")); err == nil {
+		t.Errorf("expected hidden page to be skipped")
+	}
+	guide := readSiteFile(t, filepath.Join(destDir, "1", "index.html"))
+	expectedContains(t, guide, `<a href="../1/10.html">Home</a>`)
+
+	destDir = filepath.Join("testout", "site-md")
+	os.RemoveAll(destDir)
+	if err := GenerateSite(lg, destDir, &Options{Markdown: true, Hidden: HiddenInclude}); err != nil {
+		t.Fatal(err)
+	}
+	home = readSiteFile(t, filepath.Join(destDir, "1", "10.md"))
+	expectedContains(t, home,
+		"---\ntitle: \"Home\"\nguide: \"Engineering\"\nguide_id: 1\npage_id: 10\n",
+		"- **Home**\n  - [Standards](../1/11.md)\n  - [Patents](../1/12.md)\n",
+		"[Patents page](../1/12.md)",
+		"<!-- column 2, position 1 -->")
+	expectedContains(t, readSiteFile(t, filepath.Join(destDir, "index.md")), "- [Chemistry](2/index.md) (Published)")
+	if _, err := os.Stat(filepath.Join(destDir, "1", "13.md")); err != nil {
+		t.Errorf("expected hidden page to be included, %s", err)
+	}
+}
+
+func TestSiteLocalPath(t *testing.T) {
+	b := &siteBuilder{lg: siteFixture(), opts: new(Options), ext: ".html",
+		guides: map[int]*Guide{}, pages: map[int]*Page{}, pageGuides: map[int]int{},
+		hosts: map[string]bool{"libguides.example.edu": true}, paths: map[string]string{}}
+	for _, guide := range b.lg.Guides {
+		b.guides[guide.Id] = guide
+		for _, page := range guide.Pages {
+			b.pages[page.Id] = page
+			b.pageGuides[page.Id] = guide.Id
+		}
+	}
+	expectedString(t, "../1/index.html", b.rewriteLink("https://libguides.example.edu/c.php?g=1", 1))
+	expectedString(t, "1/11.html", b.rewriteLink("https://LibGuides.example.edu/c.php?g=1&p=11", 0))
+	expectedString(t, "https://other.example.edu/c.php?g=1&p=11", b.rewriteLink("https://other.example.edu/c.php?g=1&p=11", 0))
+	expectedString(t, "mailto:help@example.edu", b.rewriteLink("mailto:help@example.edu", 0))
+
+	// Scripts are removed before a description is rendered unescaped
+	expectedString(t, `<p>Hello <a href="https://example.org/">there</a><img src="a.png"/></p><a>link</a>`,
+		b.rewriteDescription(`<script>alert(1)</script><p>Hello <a href="https://example.org/" onclick="alert(2)">there</a><img src="a.png" OnError="alert(3)"><script>alert(4)</script></p><a href=" java&#9;script:alert(5)">link</a>`, 0))
+	expectedString(t, `<p>Hello</p>`, b.rewriteDescription(`<p>Hello</p>`, 0))
+	expectedString(t, `<iframe src="https://video.example.edu/embed"></iframe><p><img src="data:image/png;base64,AA==" alt="Data: a chart"/></p><a>there</a>`,
+		b.rewriteDescription(`<base href="https://evil.example.com/"><iframe src="https://video.example.edu/embed" srcdoc="<script>alert(1)</script>"></iframe><object data="x.swf"></object><embed src="x.swf"><p><img src="data:image/png;base64,AA==" alt="Data: a chart"></p><a href="data:text/html,<script>alert(2)</script>">there</a>`, 0))
+}
@@ -0,0 +1,140 @@
+// sitemap.go generates an XML sitemap of the published guides and pages of
+// a LibGuides export.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"encoding/xml"
+	"net/url"
+	"strings"
+)
+
+// SitemapNamespace is the XML namespace of the sitemap protocol
+const SitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// SitemapURL is an entry of a sitemap.
+type SitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Sitemap is an XML sitemap, see https://www.sitemaps.org/protocol.html
+type Sitemap struct {
+	XMLName xml.Name      `xml:"urlset"`
+	Xmlns   string        `xml:"xmlns,attr"`
+	URLs    []*SitemapURL `xml:"url"`
+}
+
+// sitemapDate converts a LibGuides timestamp to a W3C date, an empty
+// string if invalid.
+func sitemapDate(ts string) string {
+	t, ok := ParseTimestamp(ts)
+	if !ok {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// NewSitemap lists the URLs of the published guides and their public
+// pages with the date last modified. URLs are built from the site's
+// domain (or opts.SitePrefix) when missing. URLs on other hosts,
+// which a sitemap can't list, and repeated URLs are left out, as is
+// hidden and unpublished content. Returns an error if neither the
+// site's domain nor opts.SitePrefix is known.
+func NewSitemap(lg *LibGuides, opts *Options) (*Sitemap, error) {
+	prefix, err := opts.sitePrefix(lg)
+	if err != nil {
+		return nil, err
+	}
+	host := ""
+	if u, err := url.Parse(prefix); err == nil {
+		host = strings.ToLower(u.Host)
+	}
+	sitemap := &Sitemap{Xmlns: SitemapNamespace, URLs: []*SitemapURL{}}
+	seen := map[string]bool{}
+	add := func(loc string, modified string) {
+		u, err := url.Parse(loc)
+		if loc == "" || err != nil || seen[loc] || (host != "" && strings.ToLower(u.Host) != host) {
+			return
+		}
+		seen[loc] = true
+		sitemap.URLs = append(sitemap.URLs, &SitemapURL{Loc: loc, LastMod: sitemapDate(modified)})
+	}
+	for _, guide := range lg.Guides {
+		if !isPublishedGuide(guide) {
+			continue
+		}
+		add(publicGuideURL(guide, prefix), latestTimestamp(guide.Modified, guide.Updated))
+		for _, page := range guide.Pages {
+			if isPublicPage(page) {
+				add(publicPageURL(guide, page, prefix), latestTimestamp(page.Modified, page.Updated))
+			}
+		}
+	}
+	return sitemap, nil
+}
+
+// ToXML renders the sitemap as XML.
+func (s *Sitemap) ToXML() ([]byte, error) {
+	src, err := xml.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(src, '\n')...), nil
+}
+
+// SitemapReport reads a LibGuides export and writes the sitemap of its
+// published guides and pages to destName (see NewSitemap).
+func SitemapReport(srcName string, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	lg, err := opts.ReadLibGuides(srcName)
+	if err != nil {
+		return err
+	}
+	sitemap, err := NewSitemap(lg, opts)
+	if err != nil {
+		return err
+	}
+	opts.Logf("listed %d URLs of %d guides", len(sitemap.URLs), len(lg.Guides))
+	src, err := sitemap.ToXML()
+	if err != nil {
+		return err
+	}
+	return WriteDestinationWithOptions(destName, src, &opts.WriteOptions)
+}
@@ -0,0 +1,106 @@
+// sitemap_test.go tests the XML sitemap in sitemap.go.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewSitemap(t *testing.T) {
+	lg, err := ReadLibGuides(filepath.Join("testinput", "api", "LibGuides_export_api.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A guide without a URL and a page on another host
+	lg.Guides = append(lg.Guides, &Guide{Id: 512674, Status: "Published", Modified: "2021-05-06 07:08:09", Pages: []*Page{
+		{Id: 3502900, Url: "https://elsewhere.example.edu/page", Modified: "2021-05-06 07:08:09"},
+		{Id: 3502901, Modified: "0000-00-00 00:00:00"},
+	}})
+	sitemap, err := NewSitemap(lg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locs := []string{}
+	for _, u := range sitemap.URLs {
+		locs = append(locs, u.Loc+" "+u.LastMod)
+	}
+	expectedInt(t, 7, len(locs))
+	if len(locs) != 7 {
+		t.Fatalf("%q", locs)
+	}
+	for i, expected := range []string{
+		"https://libguides.example.edu/patents 2021-03-01",
+		"https://libguides.example.edu/patents/home 2021-03-01",
+		"https://libguides.example.edu/c.php?g=512671&p=3502869 2018-04-04",
+		"https://libguides.example.edu/chemistry 2020-12-01",
+		"https://libguides.example.edu/chemistry/home 2020-12-01",
+		"https://libguides.example.edu/c.php?g=512674 2021-05-06",
+		"https://libguides.example.edu/c.php?g=512674&p=3502901 ",
+	} {
+		expectedString(t, expected, locs[i])
+	}
+	// Only the URLs built from the prefix are on its host
+	sitemap, err = NewSitemap(lg, &Options{SitePrefix: "https://guides.example.edu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 2, len(sitemap.URLs))
+	if len(sitemap.URLs) == 2 {
+		expectedString(t, "https://guides.example.edu/c.php?g=512674", sitemap.URLs[0].Loc)
+	}
+	lg.Site = nil
+	if _, err := NewSitemap(lg, nil); err == nil {
+		t.Errorf("expected an error without a site domain or prefix")
+	}
+}
+
+func TestSitemapReport(t *testing.T) {
+	destName := filepath.Join("testout", "sitemap.xml")
+	if err := SitemapReport(filepath.Join("testinput", "api", "LibGuides_export_api.xml"), destName, nil); err != nil {
+		t.Fatal(err)
+	}
+	src, err := ioutil.ReadFile(destName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sitemap := new(Sitemap)
+	if err := xml.Unmarshal(src, sitemap); err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, SitemapNamespace, sitemap.XMLName.Space)
+	expectedInt(t, 5, len(sitemap.URLs))
+}
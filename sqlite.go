@@ -0,0 +1,360 @@
+// sqlite.go exports a LibGuides object to a normalized SQLite database for
+// ad-hoc SQL analysis.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	// 3rd Party Packages, a pure Go driver so the tools cross compile
+	// without cgo
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteDriver is the database/sql driver name of the SQLite driver
+const SQLiteDriver = "sqlite"
+
+// SQLiteDSN returns the data source name opening the SQLite database
+// in the file name with foreign keys enforced on every connection. The
+// name is escaped in a file: URI so a "?" or "#" in the path is kept.
+func SQLiteDSN(name string) string {
+	p := filepath.ToSlash(name)
+	if filepath.VolumeName(name) != "" {
+		p = "/" + p
+	}
+	return "file:" + (&url.URL{Path: p}).EscapedPath() + "?_pragma=foreign_keys(1)"
+}
+
+// SQLiteSchema creates the tables of the SQLite export. Guide and
+// asset owners are kept as columns (with the account id) rather than
+// foreign keys as owners may be missing from the accounts. Boxes,
+// panes and assets have a key column as the same box or asset may be
+// mapped into several pages.
+const SQLiteSchema = `
+CREATE TABLE customer (
+    id INTEGER PRIMARY KEY,
+    type TEXT, name TEXT, url TEXT, city TEXT, state TEXT,
+    country TEXT, time_zone TEXT, created TEXT, updated TEXT
+);
+CREATE TABLE site (
+    id INTEGER PRIMARY KEY,
+    type TEXT, name TEXT, domain TEXT, admin TEXT,
+    created TEXT, updated TEXT
+);
+CREATE TABLE accounts (
+    id INTEGER PRIMARY KEY,
+    email TEXT, first_name TEXT, last_name TEXT, title TEXT,
+    nickname TEXT, signature TEXT, image TEXT, address TEXT,
+    phone TEXT, skype TEXT, website TEXT, created TEXT, updated TEXT
+);
+CREATE INDEX accounts_email ON accounts (email);
+CREATE TABLE groups (
+    id INTEGER PRIMARY KEY,
+    type TEXT, name TEXT, url TEXT, description TEXT,
+    created TEXT, updated TEXT
+);
+CREATE TABLE subjects (
+    id INTEGER PRIMARY KEY,
+    name TEXT, url TEXT
+);
+CREATE TABLE tags (
+    id INTEGER PRIMARY KEY,
+    name TEXT
+);
+CREATE TABLE vendors (
+    id INTEGER PRIMARY KEY,
+    name TEXT
+);
+CREATE TABLE guides (
+    id INTEGER PRIMARY KEY,
+    type TEXT, name TEXT, description TEXT, url TEXT,
+    owner_id INTEGER, owner_email TEXT, owner_first_name TEXT, owner_last_name TEXT,
+    group_id INTEGER REFERENCES groups (id),
+    redirect TEXT, status TEXT, created TEXT, updated TEXT,
+    modified TEXT, published TEXT
+);
+CREATE INDEX guides_owner_email ON guides (owner_email);
+CREATE INDEX guides_group_id ON guides (group_id);
+CREATE TABLE guide_subjects (
+    guide_id INTEGER NOT NULL REFERENCES guides (id),
+    subject_id INTEGER NOT NULL REFERENCES subjects (id),
+    PRIMARY KEY (guide_id, subject_id)
+);
+CREATE INDEX guide_subjects_subject_id ON guide_subjects (subject_id);
+CREATE TABLE guide_tags (
+    guide_id INTEGER NOT NULL REFERENCES guides (id),
+    tag_id INTEGER NOT NULL REFERENCES tags (id),
+    PRIMARY KEY (guide_id, tag_id)
+);
+CREATE INDEX guide_tags_tag_id ON guide_tags (tag_id);
+CREATE TABLE pages (
+    id INTEGER PRIMARY KEY,
+    guide_id INTEGER NOT NULL REFERENCES guides (id),
+    name TEXT, description TEXT, url TEXT, redirect TEXT,
+    source_page_id INTEGER, parent_page_id INTEGER, position INTEGER,
+    hidden INTEGER, created TEXT, updated TEXT, modified TEXT
+);
+CREATE INDEX pages_guide_id ON pages (guide_id);
+CREATE INDEX pages_source_page_id ON pages (source_page_id);
+CREATE TABLE boxes (
+    box_key INTEGER PRIMARY KEY,
+    id INTEGER NOT NULL,
+    page_id INTEGER NOT NULL REFERENCES pages (id),
+    name TEXT, type TEXT, map_id TEXT, "column" INTEGER, position INTEGER,
+    hidden INTEGER, created TEXT, updated TEXT
+);
+CREATE INDEX boxes_id ON boxes (id);
+CREATE INDEX boxes_page_id ON boxes (page_id);
+CREATE TABLE panes (
+    pane_key INTEGER PRIMARY KEY,
+    box_key INTEGER NOT NULL REFERENCES boxes (box_key),
+    position INTEGER
+);
+CREATE INDEX panes_box_key ON panes (box_key);
+CREATE TABLE assets (
+    asset_key INTEGER PRIMARY KEY,
+    id INTEGER NOT NULL,
+    box_key INTEGER NOT NULL REFERENCES boxes (box_key),
+    pane_key INTEGER REFERENCES panes (pane_key),
+    name TEXT, type TEXT, description TEXT, url TEXT,
+    owner_id INTEGER, owner_email TEXT, owner_first_name TEXT, owner_last_name TEXT,
+    map_id TEXT, position INTEGER, created TEXT, updated TEXT
+);
+CREATE INDEX assets_id ON assets (id);
+CREATE INDEX assets_box_key ON assets (box_key);
+CREATE INDEX assets_owner_email ON assets (owner_email);
+CREATE TABLE links (
+    link_key INTEGER PRIMARY KEY,
+    url TEXT, owner TEXT, object_type TEXT, object_id TEXT,
+    guide_id INTEGER, page_id INTEGER, libguides_link TEXT,
+    embedded INTEGER, category TEXT
+);
+CREATE INDEX links_url ON links (url);
+CREATE INDEX links_guide_id ON links (guide_id);
+CREATE INDEX links_category ON links (category);
+`
+
+// nullInt stores a zero id as NULL.
+func nullInt(i int) interface{} {
+	if i == 0 {
+		return nil
+	}
+	return i
+}
+
+// nullString stores an empty string as NULL.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// sqliteWriter inserts LibGuides objects in a transaction.
+type sqliteWriter struct {
+	tx  *sql.Tx
+	err error
+}
+
+// exec runs an insert remembering the first error.
+func (w *sqliteWriter) exec(query string, args ...interface{}) sql.Result {
+	if w.err != nil {
+		return nil
+	}
+	result, err := w.tx.Exec(query, args...)
+	if err != nil {
+		w.err = fmt.Errorf("%s: %s", shorten(query, 40), err)
+		return nil
+	}
+	return result
+}
+
+// insertId runs an insert returning the key of the new row.
+func (w *sqliteWriter) insertId(query string, args ...interface{}) int64 {
+	result := w.exec(query, args...)
+	if result == nil {
+		return 0
+	}
+	id, err := result.LastInsertId()
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return id
+}
+
+// SQLiteExport writes a LibGuides object into an open SQLite database
+// creating the tables of SQLiteSchema. The links table is populated
+// from the link report (see LinkReportTable) using opts, which may be
+// nil. Subjects, tags and groups referenced by guides but missing from
+// the export's lists are added so the foreign keys hold, open the
+// database with SQLiteDSN to have them enforced.
+func SQLiteExport(db *sql.DB, lg *LibGuides, opts *Options) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(SQLiteSchema); err != nil {
+		tx.Rollback()
+		return err
+	}
+	w := &sqliteWriter{tx: tx}
+	if c := lg.Customer; c != nil {
+		w.exec(`INSERT INTO customer (id, type, name, url, city, state, country, time_zone, created, updated) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			c.Id, c.Type, c.Name, c.Url, c.City, c.State, c.Country, c.TimeZone, c.Created, c.Updated)
+	}
+	if s := lg.Site; s != nil {
+		w.exec(`INSERT INTO site (id, type, name, domain, admin, created, updated) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			s.Id, s.Type, s.Name, s.Domain, s.Admin, s.Created, s.Updated)
+	}
+	for _, a := range lg.Accounts {
+		w.exec(`INSERT OR IGNORE INTO accounts (id, email, first_name, last_name, title, nickname, signature, image, address, phone, skype, website, created, updated) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			a.Id, a.Email, a.FirstName, a.LastName, a.Title, a.Nickname, a.Signature, a.Image, a.Address, a.Phone, a.Skype, a.Website, a.Created, a.Updated)
+	}
+	insertGroup := func(g *Group) {
+		w.exec(`INSERT OR IGNORE INTO groups (id, type, name, url, description, created, updated) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			g.Id, g.Type, g.Name, g.Url, g.Description, g.Created, g.Updated)
+	}
+	insertSubject := func(s *Subject) {
+		w.exec(`INSERT OR IGNORE INTO subjects (id, name, url) VALUES (?, ?, ?)`, s.Id, s.Name, s.Url)
+	}
+	insertTag := func(t *Tag) {
+		w.exec(`INSERT OR IGNORE INTO tags (id, name) VALUES (?, ?)`, t.Id, t.Name)
+	}
+	for _, g := range lg.Groups {
+		insertGroup(g)
+	}
+	for _, s := range lg.Subjects {
+		insertSubject(s)
+	}
+	for _, t := range lg.Tags {
+		insertTag(t)
+	}
+	for _, v := range lg.Vendors {
+		w.exec(`INSERT OR IGNORE INTO vendors (id, name) VALUES (?, ?)`, v.Id, v.Name)
+	}
+	for _, guide := range lg.Guides {
+		if guide.Group.Id != 0 {
+			group := guide.Group
+			insertGroup(&group)
+		}
+		o := guide.Owner
+		w.exec(`INSERT INTO guides (id, type, name, description, url, owner_id, owner_email, owner_first_name, owner_last_name, group_id, redirect, status, created, updated, modified, published) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			guide.Id, guide.Type, guide.Name, guide.Description, guide.Url, nullInt(o.Id), o.Email, o.FirstName, o.LastName, nullInt(guide.Group.Id),
+			guide.Redirect, guide.Status, guide.Created, guide.Updated, guide.Modified, guide.Published)
+		for _, s := range guide.Subjects {
+			insertSubject(s)
+			w.exec(`INSERT OR IGNORE INTO guide_subjects (guide_id, subject_id) VALUES (?, ?)`, guide.Id, s.Id)
+		}
+		for _, t := range guide.Tags {
+			insertTag(t)
+			w.exec(`INSERT OR IGNORE INTO guide_tags (guide_id, tag_id) VALUES (?, ?)`, guide.Id, t.Id)
+		}
+		for _, page := range guide.Pages {
+			w.exec(`INSERT INTO pages (id, guide_id, name, description, url, redirect, source_page_id, parent_page_id, position, hidden, created, updated, modified) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				page.Id, guide.Id, page.Name, page.Description, page.Url, page.Redirect, nullInt(page.SourcePageId), nullInt(page.ParentPageId),
+				page.Position, page.Hidden, page.Created, page.Updated, page.Modified)
+			for _, box := range page.Boxes {
+				boxKey := w.insertId(`INSERT INTO boxes (id, page_id, name, type, map_id, "column", position, hidden, created, updated) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+					box.Id, page.Id, box.Name, box.Type, box.MapId, box.Column, box.Position, box.Hidden, box.Created, box.Updated)
+				insertAsset := func(a *Asset, paneKey interface{}) {
+					o := a.Owner
+					w.exec(`INSERT INTO assets (id, box_key, pane_key, name, type, description, url, owner_id, owner_email, owner_first_name, owner_last_name, map_id, position, created, updated) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+						a.Id, boxKey, paneKey, a.Name, a.Type, a.Description, a.Url, nullInt(o.Id), o.Email, o.FirstName, o.LastName, a.MapId, a.Position, a.Created, a.Updated)
+				}
+				for _, asset := range box.Assets {
+					insertAsset(asset, nil)
+				}
+				for i, pane := range box.Panes {
+					paneKey := w.insertId(`INSERT INTO panes (box_key, position) VALUES (?, ?)`, boxKey, i+1)
+					for _, asset := range pane.Assets {
+						insertAsset(asset, paneKey)
+					}
+				}
+			}
+		}
+	}
+	links := LinkReportTable(lg, "links", opts)
+	for _, row := range links.Body.Rows {
+		embedded := 0
+		if row[7] == "true" {
+			embedded = 1
+		}
+		w.exec(`INSERT INTO links (url, owner, object_type, object_id, guide_id, page_id, libguides_link, embedded, category) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			row[0], row[1], row[2], row[3], nullString(row[4]), nullString(row[5]), row[6], embedded, row[8])
+	}
+	if w.err != nil {
+		tx.Rollback()
+		return w.err
+	}
+	return tx.Commit()
+}
+
+// SQLiteReport reads a LibGuides export and writes it to a new SQLite
+// database named destName. The database is built in a temporary file
+// and renamed into place using the write options (see AtomicFile).
+func SQLiteReport(srcName string, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	if destName == StdIO || destName == "" {
+		return fmt.Errorf("a SQLite database can't be written to standard output")
+	}
+	lg, err := opts.ReadLibGuides(srcName)
+	if err != nil {
+		return err
+	}
+	f, err := CreateAtomicFile(destName, &opts.WriteOptions)
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open(SQLiteDriver, SQLiteDSN(f.Name()))
+	if err != nil {
+		f.Abort()
+		return err
+	}
+	if err := SQLiteExport(db, lg, opts); err != nil {
+		db.Close()
+		f.Abort()
+		return err
+	}
+	if err := db.Close(); err != nil {
+		f.Abort()
+		return err
+	}
+	opts.Logf("wrote %d guides to %q", len(lg.Guides), destName)
+	return f.Close()
+}
@@ -0,0 +1,171 @@
+// sqlite_test.go tests the SQLite export.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestSQLiteExport(t *testing.T) {
+	owner := Owner{Id: 1, Email: "shrimps@engineering.example.edu"}
+	gone := Owner{Id: 9, Email: "gone@example.edu"}
+	lg := &LibGuides{
+		Site:     &Site{Id: 1, Domain: "libguides.example.edu"},
+		Accounts: []*Account{{Id: 1, Email: "shrimps@engineering.example.edu"}},
+		Tags:     []*Tag{{Id: 1, Name: "patents"}},
+		Guides: []*Guide{
+			{
+				Id: 1, Name: "Engineering", Owner: owner, Group: Group{Id: 5, Name: "Science"},
+				Subjects: []*Subject{{Id: 2, Name: "Engineering"}},
+				Tags:     []*Tag{{Id: 1, Name: "patents"}},
+				Pages: []*Page{
+					{Id: 10, Boxes: []*Box{
+						{Id: 100, Assets: []*Asset{
+							{Id: 1000, Url: "https://patents.example.edu", Owner: owner},
+							{Id: 1001, Description: `<a href="file:///C:/notes.docx">Notes</a>`, Owner: gone},
+						}},
+						{Id: 101, Panes: []*Pane{
+							{Assets: []*Asset{{Id: 1000, Url: "https://patents.example.edu", Owner: owner}}},
+						}},
+					}},
+					{Id: 11, SourcePageId: 10},
+				},
+			},
+		},
+	}
+	db, err := sql.Open(SQLiteDriver, SQLiteDSN(":memory:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := SQLiteExport(db, lg, nil); err != nil {
+		t.Fatal(err)
+	}
+	count := func(query string, args ...interface{}) int {
+		var n int
+		if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+		return n
+	}
+	for table, expected := range map[string]int{
+		"site":           1,
+		"accounts":       1,
+		"groups":         1,
+		"subjects":       1,
+		"tags":           1,
+		"guides":         1,
+		"guide_subjects": 1,
+		"guide_tags":     1,
+		"pages":          2,
+		"boxes":          2,
+		"panes":          1,
+		"assets":         3,
+		"links":          3,
+	} {
+		expectedInt(t, expected, count("SELECT count(*) FROM "+table))
+	}
+	// Assets by owner in guides tagged "patents"
+	expectedInt(t, 2, count(`SELECT count(*) FROM assets a
+		JOIN boxes b USING (box_key) JOIN pages p ON p.id = b.page_id
+		JOIN guide_tags gt ON gt.guide_id = p.guide_id
+		JOIN tags t ON t.id = gt.tag_id
+		WHERE t.name = 'patents' AND a.owner_email = ?`, owner.Email))
+	// Owners missing from accounts are kept
+	expectedInt(t, 1, count(`SELECT count(*) FROM assets a LEFT JOIN accounts c ON c.id = a.owner_id WHERE c.id IS NULL`))
+	expectedInt(t, 1, count(`SELECT count(*) FROM links WHERE category = ? AND embedded = 1`, LinkLocalFile))
+	expectedInt(t, 1, count(`SELECT count(*) FROM pages WHERE source_page_id = 10`))
+	rows, err := db.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows.Next() {
+		t.Errorf("expected no foreign key violations")
+	}
+	rows.Close()
+}
+
+func TestSQLiteReport(t *testing.T) {
+	srcName := path.Join("testinput", "LibGuides_export_XXXXX.xml")
+	expectedString(t, "file:out/a%3Fb%23c%25/x.db?_pragma=foreign_keys(1)", SQLiteDSN("out/a?b#c%/x.db"))
+	// The directory name needs escaping in the data source name
+	destName := path.Join("testout", "sqlite #1 100%", "libguides.db")
+	os.RemoveAll(path.Dir(destName))
+	if err := os.MkdirAll(path.Dir(destName), 0775); err != nil {
+		t.Fatal(err)
+	}
+	if err := SQLiteReport(srcName, destName, nil); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(SQLiteDriver, SQLiteDSN(destName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var guides int
+	if err := db.QueryRow("SELECT count(*) FROM guides").Scan(&guides); err != nil {
+		t.Fatal(err)
+	}
+	if guides == 0 {
+		t.Errorf("expected guides in %q", destName)
+	}
+	// Foreign keys are enforced on every connection of the pool
+	db.SetMaxOpenConns(2)
+	conns := []*sql.Conn{}
+	for i := 0; i < 2; i++ {
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+		var enforced int
+		if err := conn.QueryRowContext(context.Background(), "PRAGMA foreign_keys").Scan(&enforced); err != nil {
+			t.Fatal(err)
+		}
+		expectedInt(t, 1, enforced)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	db.Close()
+	if err := SQLiteReport(srcName, destName, &Options{WriteOptions: WriteOptions{NoClobber: true}}); err == nil {
+		t.Errorf("expected no clobber to fail")
+	}
+	if err := SQLiteReport(srcName, StdIO, nil); err == nil {
+		t.Errorf("expected standard output to fail")
+	}
+}
@@ -0,0 +1,256 @@
+// stale.go provides a report of guides and pages that have not been modified
+// within a review window (e.g. 18 months) using the Modified and Updated timestamps.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// TimestampLayout is the layout of the timestamps in a LibGuides export
+	TimestampLayout = "2006-01-02 15:04:05"
+
+	// DefaultStaleMonths is the default review window of the stale report
+	DefaultStaleMonths = 18
+)
+
+// ParseTimestamp parses a LibGuides timestamp (e.g. "2019-11-06 20:23:57").
+// Returns the time and false if the timestamp is empty or invalid.
+func ParseTimestamp(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.HasPrefix(s, "0000") {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{TimestampLayout, "2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// latestTimestamp returns the most recent of the timestamps, skipping
+// empty or invalid ones. Returns an empty string if none are valid.
+func latestTimestamp(timestamps ...string) string {
+	latest, latestTime := "", time.Time{}
+	for _, ts := range timestamps {
+		if t, ok := ParseTimestamp(ts); ok && t.After(latestTime) {
+			latest, latestTime = strings.TrimSpace(ts), t
+		}
+	}
+	return latest
+}
+
+// modifiedTimestamp returns the Modified timestamp when valid, falling
+// back to Updated.
+func modifiedTimestamp(modified string, updated string) string {
+	if _, ok := ParseTimestamp(modified); ok {
+		return strings.TrimSpace(modified)
+	}
+	return latestTimestamp(updated)
+}
+
+// StaleCutoff returns the time months before now.
+func StaleCutoff(now time.Time, months int) time.Time {
+	return now.AddDate(0, -months, 0)
+}
+
+// pageNewestAsset returns the latest Updated timestamp of the assets
+// on a page.
+func pageNewestAsset(page *Page) string {
+	timestamps := []string{}
+	for _, box := range page.Boxes {
+		for _, asset := range box.Assets {
+			timestamps = append(timestamps, asset.Updated)
+		}
+		for _, pane := range box.Panes {
+			for _, asset := range pane.Assets {
+				timestamps = append(timestamps, asset.Updated)
+			}
+		}
+	}
+	return latestTimestamp(timestamps...)
+}
+
+// isStale returns true if the timestamp is before the cutoff or is
+// missing, content with no modified date needs reviewing too.
+func isStale(ts string, cutoff time.Time) bool {
+	t, ok := ParseTimestamp(ts)
+	return !ok || t.Before(cutoff)
+}
+
+// StaleReportTable returns a Table of the guides and pages not modified
+// since cutoff. A guide's modified date is its Modified timestamp
+// (falling back to Updated), likewise for pages. Pages are included
+// according to the hidden policy in opts, opts may be nil.
+func StaleReportTable(lg *LibGuides, cutoff time.Time, caption string, opts *Options) *Table {
+	hidden := HiddenSkip
+	if opts != nil {
+		hidden = opts.Hidden
+	}
+	tbl := new(Table)
+	tbl.SetCaption(caption)
+	tbl.AppendHeadings("Object Type", "Id", "Name",
+		"Guide Id", "Owner", "Status", "Group",
+		"Modified", "Page Count", "Newest Asset Update")
+	for _, guide := range lg.Guides {
+		owner := opts.ownerName(guide.Owner)
+		pages, assetUpdates := 0, []string{}
+		for _, page := range guide.Pages {
+			if hidden.Allows(page.Hidden != 0) {
+				pages++
+				assetUpdates = append(assetUpdates, pageNewestAsset(page))
+			}
+		}
+		modified := modifiedTimestamp(guide.Modified, guide.Updated)
+		if isStale(modified, cutoff) && hidden.Allows(false) {
+			tbl.AppendRow("Guide", strInt(guide.Id), guide.Name,
+				strInt(guide.Id), owner, guide.Status, guide.Group.Name,
+				modified, strInt(pages), latestTimestamp(assetUpdates...))
+		}
+		for _, page := range guide.Pages {
+			if !hidden.Allows(page.Hidden != 0) {
+				continue
+			}
+			modified := modifiedTimestamp(page.Modified, page.Updated)
+			if isStale(modified, cutoff) {
+				tbl.AppendRow("Page", strInt(page.Id), page.Name,
+					strInt(guide.Id), owner, guide.Status, guide.Group.Name,
+					modified, "", pageNewestAsset(page))
+			}
+		}
+	}
+	return tbl
+}
+
+// StaleRollupTable summarizes stale guides and pages per owner (by is
+// "owner") or per group (by is "group"). Each row counts the guides,
+// the stale guides and stale pages and the oldest modified date. Guides
+// and pages are counted according to the hidden policy in opts as in
+// StaleReportTable.
+func StaleRollupTable(lg *LibGuides, cutoff time.Time, by string, caption string, opts *Options) (*Table, error) {
+	hidden := HiddenSkip
+	if opts != nil {
+		hidden = opts.Hidden
+	}
+	type rollup struct {
+		name                            string
+		guides, staleGuides, stalePages int
+		oldest                          string
+		oldestTime                      time.Time
+	}
+	by = strings.ToLower(by)
+	if by != "owner" && by != "group" {
+		return nil, fmt.Errorf("can't roll up by %q, expected owner or group", by)
+	}
+	keys := []string{}
+	rollups := map[string]*rollup{}
+	for _, guide := range lg.Guides {
+		key := guide.Group.Name
+		if by == "owner" {
+			key = opts.ownerName(guide.Owner)
+		}
+		r, ok := rollups[key]
+		if !ok {
+			r = &rollup{name: key}
+			rollups[key] = r
+			keys = append(keys, key)
+		}
+		check := func(modified string) bool {
+			if !isStale(modified, cutoff) {
+				return false
+			}
+			if t, ok := ParseTimestamp(modified); ok && (r.oldest == "" || t.Before(r.oldestTime)) {
+				r.oldest, r.oldestTime = modified, t
+			}
+			return true
+		}
+		if hidden.Allows(false) {
+			r.guides++
+			if check(modifiedTimestamp(guide.Modified, guide.Updated)) {
+				r.staleGuides++
+			}
+		}
+		for _, page := range guide.Pages {
+			if hidden.Allows(page.Hidden != 0) && check(modifiedTimestamp(page.Modified, page.Updated)) {
+				r.stalePages++
+			}
+		}
+	}
+	sort.Strings(keys)
+	tbl := new(Table)
+	tbl.SetCaption(caption)
+	heading := "Group"
+	if by == "owner" {
+		heading = "Owner"
+	}
+	tbl.AppendHeadings(heading, "Guides", "Stale Guides", "Stale Pages", "Oldest Modified")
+	for _, key := range keys {
+		r := rollups[key]
+		tbl.AppendRow(r.name, strInt(r.guides), strInt(r.staleGuides), strInt(r.stalePages), r.oldest)
+	}
+	return tbl, nil
+}
+
+// StaleReport reads a LibGuides export and writes the guides and pages
+// not modified in the last opts.StaleMonths (default 18) months, see
+// StaleReportTable. If opts.Rollup is "owner" or "group" a summary per
+// owner or group is written instead.
+func StaleReport(srcName string, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	months := opts.StaleMonths
+	if months <= 0 {
+		months = DefaultStaleMonths
+	}
+	lg, err := opts.ReadLibGuides(srcName)
+	if err != nil {
+		return err
+	}
+	cutoff := StaleCutoff(time.Now(), months)
+	caption := fmt.Sprintf("Content in %q not modified since %s", srcName, cutoff.Format("2006-01-02"))
+	var tbl *Table
+	if opts.Rollup == "" {
+		tbl = StaleReportTable(lg, cutoff, caption, opts)
+	} else if tbl, err = StaleRollupTable(lg, cutoff, opts.Rollup, caption, opts); err != nil {
+		return err
+	}
+	opts.Logf("found %d stale rows since %s", len(tbl.Body.Rows), cutoff.Format("2006-01-02"))
+	return opts.WriteTable(tbl, destName)
+}
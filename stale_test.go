@@ -0,0 +1,117 @@
+// stale_test.go provides tests for stale.go
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	if ts, ok := ParseTimestamp("2019-11-06 20:23:57"); !ok {
+		t.Errorf("expected timestamp to parse")
+	} else {
+		expectedInt(t, 2019, ts.Year())
+		expectedInt(t, 20, ts.Hour())
+	}
+	for _, s := range []string{"", "0000-00-00 00:00:00", "yesterday"} {
+		if _, ok := ParseTimestamp(s); ok {
+			t.Errorf("expected %q not to parse", s)
+		}
+	}
+	expectedString(t, "2020-03-03 18:06:49", latestTimestamp("2016-07-09 00:00:05", "", "2020-03-03 18:06:49", "2017-09-19 17:56:15"))
+}
+
+func TestStaleReport(t *testing.T) {
+	alice := Owner{FirstName: "Alice", LastName: "Smith", Email: "alice@example.edu"}
+	bob := Owner{FirstName: "Bob", LastName: "Jones", Email: "bob@example.edu"}
+	lg := &LibGuides{
+		Guides: []*Guide{
+			{Id: 1, Name: "Fresh", Owner: alice, Group: Group{Name: "Science"}, Modified: "2021-06-01 00:00:00", Pages: []*Page{
+				{Id: 10, Name: "Home", Modified: "2021-06-01 00:00:00"},
+				{Id: 11, Name: "Old page", Modified: "2018-01-01 00:00:00", Boxes: []*Box{
+					{Assets: []*Asset{{Updated: "2019-01-01 00:00:00"}, {Updated: "2020-05-05 00:00:00"}}},
+				}},
+				{Id: 12, Name: "Hidden old page", Hidden: 1, Modified: "2017-01-01 00:00:00"},
+			}},
+			{Id: 2, Name: "Stale", Owner: bob, Group: Group{Name: "Science"}, Modified: "", Updated: "2019-03-01 00:00:00", Pages: []*Page{
+				{Id: 20, Name: "Home", Modified: "2019-03-01 00:00:00"},
+			}},
+			// A newer Updated doesn't hide an old Modified, nor do assets on hidden pages
+			{Id: 3, Name: "Also stale", Owner: alice, Group: Group{Name: "Humanities"}, Modified: "2015-03-01 00:00:00", Updated: "2021-06-01 00:00:00", Pages: []*Page{
+				{Id: 30, Name: "Hidden draft", Hidden: 1, Modified: "2021-06-01 00:00:00", Boxes: []*Box{
+					{Assets: []*Asset{{Updated: "2021-02-02 00:00:00"}}},
+				}},
+			}},
+		},
+	}
+	now, _ := ParseTimestamp("2021-07-01 00:00:00")
+	cutoff := StaleCutoff(now, 18)
+	expectedString(t, "2020-01-01", cutoff.Format("2006-01-02"))
+
+	tbl := StaleReportTable(lg, cutoff, "stale", nil)
+	expectedInt(t, 4, len(tbl.Body.Rows))
+	if len(tbl.Body.Rows) == 4 {
+		row := tbl.Body.Rows[0]
+		expectedString(t, "Page", row[0])
+		expectedString(t, "11", row[1])
+		expectedString(t, "2020-05-05 00:00:00", row[9])
+		row = tbl.Body.Rows[1]
+		expectedString(t, "Guide", row[0])
+		expectedString(t, "2019-03-01 00:00:00", row[7])
+		expectedString(t, "1", row[8])
+		expectedString(t, "Bob Jones <bob@example.edu>", row[4])
+		row = tbl.Body.Rows[3]
+		expectedString(t, "Guide,3,2015-03-01 00:00:00,0,", row[0]+","+row[1]+","+row[7]+","+row[8]+","+row[9])
+	}
+	expectedInt(t, 5, len(StaleReportTable(lg, cutoff, "stale", &Options{Hidden: HiddenInclude}).Body.Rows))
+
+	tbl, err := StaleRollupTable(lg, cutoff, "owner", "rollup", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 2, len(tbl.Body.Rows))
+	expectedString(t, "Alice Smith <alice@example.edu>,2,1,1,2015-03-01 00:00:00", joinRow(tbl.Body.Rows[0]))
+	tbl, _ = StaleRollupTable(lg, cutoff, "owner", "rollup", &Options{Hidden: HiddenOnly})
+	expectedString(t, "Alice Smith <alice@example.edu>,0,0,1,2017-01-01 00:00:00", joinRow(tbl.Body.Rows[0]))
+	tbl, _ = StaleRollupTable(lg, cutoff, "Group", "rollup", nil)
+	expectedString(t, "Group", tbl.Head.Row[0])
+	expectedString(t, "Science,2,1,2,2018-01-01 00:00:00", joinRow(tbl.Body.Rows[1]))
+	if _, err := StaleRollupTable(lg, cutoff, "subject", "rollup", nil); err == nil {
+		t.Errorf("expected an error rolling up by subject")
+	}
+	if !isStale("", time.Now()) {
+		t.Errorf("expected content without a date to be stale")
+	}
+}
@@ -0,0 +1,467 @@
+// tableops.go provides sorting, filtering, projection and grouping
+// operations on the Table datastructure.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TableOptions holds the post-processing applied to a report
+// before it is written out. Empty fields are skipped.
+type TableOptions struct {
+	// Where is a filter expression, e.g. `"Object Type" == "Asset"`
+	Where string `json:"where,omitempty"`
+	// GroupBy lists the columns to group and count rows by
+	GroupBy []string `json:"group_by,omitempty"`
+	// Sort lists the columns to sort by, prefix with "-" for descending
+	Sort []string `json:"sort,omitempty"`
+	// Columns lists the columns to keep and their order
+	Columns []string `json:"columns,omitempty"`
+}
+
+// SplitColumns takes a comma delimited list of column names (e.g.
+// from a command line option) and returns a list of trimmed names.
+func SplitColumns(s string) []string {
+	columns := []string{}
+	for _, col := range strings.Split(s, ",") {
+		if col = strings.TrimSpace(col); col != "" {
+			columns = append(columns, col)
+		}
+	}
+	return columns
+}
+
+// Apply runs the options against a table in the order where, group by,
+// sort and then columns. Returns the resulting table and an error.
+// The table passed in may be modified.
+func (o *TableOptions) Apply(t *Table) (*Table, error) {
+	if o == nil {
+		return t, nil
+	}
+	if o.Where != "" {
+		if err := t.Where(o.Where); err != nil {
+			return nil, err
+		}
+	}
+	if len(o.GroupBy) > 0 {
+		grouped, err := t.GroupBy(o.GroupBy...)
+		if err != nil {
+			return nil, err
+		}
+		t = grouped
+	}
+	if len(o.Sort) > 0 {
+		if err := t.SortBy(o.Sort...); err != nil {
+			return nil, err
+		}
+	}
+	if len(o.Columns) > 0 {
+		if err := t.Select(o.Columns...); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// ColumnIndex returns the position of the named column in the
+// table's headings. Returns -1 if the column is not found.
+func (t *Table) ColumnIndex(name string) int {
+	for i, heading := range t.Head.Row {
+		if heading == name {
+			return i
+		}
+	}
+	// Fallback to a case insensitive match
+	for i, heading := range t.Head.Row {
+		if strings.EqualFold(heading, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *Table) columnIndexes(names []string) ([]int, error) {
+	indexes := make([]int, len(names))
+	for i, name := range names {
+		if indexes[i] = t.ColumnIndex(name); indexes[i] < 0 {
+			return nil, fmt.Errorf("%q is not a column", name)
+		}
+	}
+	return indexes, nil
+}
+
+// cell returns the value of column i in row, missing cells are
+// treated as empty strings.
+func cell(row []string, i int) string {
+	if i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+// compareCells compares two cell values numerically when both are
+// integers otherwise as strings. Returns -1, 0 or 1.
+func compareCells(a, b string) int {
+	if x, err := strconv.Atoi(a); err == nil {
+		if y, err := strconv.Atoi(b); err == nil {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// SortBy sorts the table rows by one or more columns. A column name
+// prefixed with "-" is sorted in descending order. The sort is stable
+// so rows with equal values keep their relative order.
+func (t *Table) SortBy(columns ...string) error {
+	names := make([]string, len(columns))
+	descending := make([]bool, len(columns))
+	for i, col := range columns {
+		if strings.HasPrefix(col, "-") {
+			descending[i] = true
+			col = col[1:]
+		}
+		names[i] = col
+	}
+	indexes, err := t.columnIndexes(names)
+	if err != nil {
+		return err
+	}
+	rows := t.Body.Rows
+	sort.SliceStable(rows, func(i, j int) bool {
+		for k, col := range indexes {
+			c := compareCells(cell(rows[i], col), cell(rows[j], col))
+			if c == 0 {
+				continue
+			}
+			if descending[k] {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	return nil
+}
+
+// Filter keeps the rows where keep returns true.
+func (t *Table) Filter(keep func(row []string) bool) {
+	rows := [][]string{}
+	for _, row := range t.Body.Rows {
+		if keep(row) {
+			rows = append(rows, row)
+		}
+	}
+	t.Body.Rows = rows
+}
+
+// Where filters the table rows with a simple expression. A comparison
+// is a column name, an operator and a value, e.g.
+//
+//	"Object Type" == "Asset"
+//
+// Supported operators are ==, !=, <, <=, >, >=, =~ and !~ (the last
+// two match a regular expression). Comparisons can be combined with
+// && (and), || (or), ! (not) and parenthesis.
+func (t *Table) Where(expr string) error {
+	keep, err := compileWhere(t, expr)
+	if err != nil {
+		return err
+	}
+	t.Filter(keep)
+	return nil
+}
+
+// Select keeps only the named columns, in the order given.
+func (t *Table) Select(columns ...string) error {
+	indexes, err := t.columnIndexes(columns)
+	if err != nil {
+		return err
+	}
+	project := func(row []string) []string {
+		cells := make([]string, len(indexes))
+		for i, col := range indexes {
+			cells[i] = cell(row, col)
+		}
+		return cells
+	}
+	t.Head.Row = project(t.Head.Row)
+	for i, row := range t.Body.Rows {
+		t.Body.Rows[i] = project(row)
+	}
+	return nil
+}
+
+// GroupBy returns a new table with one row per distinct combination
+// of the named columns and a "Count" column holding the number of
+// rows in each group. Groups are listed in the order first seen.
+func (t *Table) GroupBy(columns ...string) (*Table, error) {
+	indexes, err := t.columnIndexes(columns)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	groups := map[string][]string{}
+	counts := map[string]int{}
+	for _, row := range t.Body.Rows {
+		cells := make([]string, len(indexes))
+		for i, col := range indexes {
+			cells[i] = cell(row, col)
+		}
+		key := strings.Join(cells, "\x00")
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+			groups[key] = cells
+		}
+		counts[key]++
+	}
+	grouped := new(Table)
+	grouped.SetCaption(t.Caption)
+	for _, col := range indexes {
+		grouped.AppendHeadings(t.Head.Row[col])
+	}
+	grouped.AppendHeadings("Count")
+	for _, key := range keys {
+		grouped.AppendRow(append(groups[key], strInt(counts[key]))...)
+	}
+	return grouped, nil
+}
+
+//
+// Where expression parsing
+//
+
+type whereParser struct {
+	tbl    *Table
+	tokens []string
+	pos    int
+}
+
+// whereOperators are the operators of a where expression, two
+// character operators first so the longest match is taken.
+var whereOperators = []string{"==", "!=", "<>", "<=", ">=", "=~", "!~", "&&", "||", "=", "<", ">", "!"}
+
+// tokenizeWhere splits an expression into quoted strings, operators,
+// parenthesis and bare words. Quoted strings keep their leading quote
+// so they can be told apart from operators.
+func tokenizeWhere(expr string) ([]string, error) {
+	tokens := []string{}
+	src := []rune(expr)
+	for i := 0; i < len(src); {
+		r := src[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			j := i + 1
+			sb := []rune{r}
+			for ; j < len(src) && src[j] != r; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				sb = append(sb, src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string in %q", expr)
+			}
+			tokens = append(tokens, string(sb))
+			i = j + 1
+		case r == '(' || r == ')':
+			tokens = append(tokens, string(r))
+			i++
+		case strings.ContainsRune("=!<>&|~", r):
+			// An unknown operator character is a token of its own
+			// left for the parser to report
+			op, rest := string(r), string(src[i:])
+			for _, candidate := range whereOperators {
+				if strings.HasPrefix(rest, candidate) {
+					op = candidate
+					break
+				}
+			}
+			tokens = append(tokens, op)
+			i += len([]rune(op))
+		default:
+			j := i
+			for j < len(src) && !unicode.IsSpace(src[j]) && !strings.ContainsRune("()=!<>&|~\"'", src[j]) {
+				j++
+			}
+			tokens = append(tokens, string(src[i:j]))
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+func compileWhere(t *Table, expr string) (func([]string) bool, error) {
+	tokens, err := tokenizeWhere(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &whereParser{tbl: t, tokens: tokens}
+	fn, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in %q", p.tokens[p.pos], expr)
+	}
+	return fn, nil
+}
+
+func (p *whereParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *whereParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *whereParser) parseOr() (func([]string) bool, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok == "||" || strings.EqualFold(tok, "or"); tok = p.peek() {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l, r := left, right
+		left = func(row []string) bool { return l(row) || r(row) }
+	}
+	return left, nil
+}
+
+func (p *whereParser) parseAnd() (func([]string) bool, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok == "&&" || strings.EqualFold(tok, "and"); tok = p.peek() {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l, r := left, right
+		left = func(row []string) bool { return l(row) && r(row) }
+	}
+	return left, nil
+}
+
+func (p *whereParser) parseUnary() (func([]string) bool, error) {
+	switch tok := p.peek(); {
+	case tok == "!" || strings.EqualFold(tok, "not"):
+		p.next()
+		fn, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(row []string) bool { return !fn(row) }, nil
+	case tok == "(":
+		p.next()
+		fn, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		return fn, nil
+	}
+	return p.parseComparison()
+}
+
+// unquote removes the leading quote kept by tokenizeWhere.
+func unquote(tok string) string {
+	if strings.HasPrefix(tok, `"`) || strings.HasPrefix(tok, `'`) {
+		return tok[1:]
+	}
+	return tok
+}
+
+func (p *whereParser) parseComparison() (func([]string) bool, error) {
+	name, op, val := p.next(), p.next(), p.next()
+	if name == "" || op == "" || val == "" {
+		return nil, fmt.Errorf("incomplete comparison, expected COLUMN OPERATOR VALUE")
+	}
+	col := p.tbl.ColumnIndex(unquote(name))
+	if col < 0 {
+		return nil, fmt.Errorf("%q is not a column", unquote(name))
+	}
+	val = unquote(val)
+	switch op {
+	case "==", "=":
+		return func(row []string) bool { return cell(row, col) == val }, nil
+	case "!=", "<>":
+		return func(row []string) bool { return cell(row, col) != val }, nil
+	case "<":
+		return func(row []string) bool { return compareCells(cell(row, col), val) < 0 }, nil
+	case "<=":
+		return func(row []string) bool { return compareCells(cell(row, col), val) <= 0 }, nil
+	case ">":
+		return func(row []string) bool { return compareCells(cell(row, col), val) > 0 }, nil
+	case ">=":
+		return func(row []string) bool { return compareCells(cell(row, col), val) >= 0 }, nil
+	case "=~", "!~":
+		re, err := regexp.Compile(val)
+		if err != nil {
+			return nil, err
+		}
+		if op == "!~" {
+			return func(row []string) bool { return !re.MatchString(cell(row, col)) }, nil
+		}
+		return func(row []string) bool { return re.MatchString(cell(row, col)) }, nil
+	}
+	return nil, fmt.Errorf("%q is not a supported operator", op)
+}
@@ -0,0 +1,158 @@
+// tableops_test.go provides tests for tableops.go
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"testing"
+)
+
+func sampleTable() *Table {
+	tbl := new(Table)
+	tbl.SetCaption("Sample links")
+	tbl.AppendHeadings("URL", "Owner", "Object Type", "Id")
+	tbl.AppendRow("https://a.example.edu", "Bob", "Asset", "10")
+	tbl.AppendRow("https://b.example.edu", "Alice", "Page", "2")
+	tbl.AppendRow("https://c.example.edu", "Alice", "Asset", "9")
+	tbl.AppendRow("https://d.example.edu", "Carol", "Asset/Description", "1 of 1")
+	return tbl
+}
+
+func TestTableSortBy(t *testing.T) {
+	tbl := sampleTable()
+	if err := tbl.SortBy("Owner", "-URL"); err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, "https://c.example.edu", tbl.Body.Rows[0][0])
+	expectedString(t, "https://b.example.edu", tbl.Body.Rows[1][0])
+	expectedString(t, "Bob", tbl.Body.Rows[2][1])
+	expectedString(t, "Carol", tbl.Body.Rows[3][1])
+
+	// Integers should compare numerically
+	tbl = sampleTable()
+	tbl.Body.Rows = tbl.Body.Rows[0:3]
+	if err := tbl.SortBy("Id"); err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, "2", tbl.Body.Rows[0][3])
+	expectedString(t, "9", tbl.Body.Rows[1][3])
+	expectedString(t, "10", tbl.Body.Rows[2][3])
+
+	if err := tbl.SortBy("Nope"); err == nil {
+		t.Errorf("expected an error sorting by a missing column")
+	}
+}
+
+func TestTableWhere(t *testing.T) {
+	tbl := sampleTable()
+	if err := tbl.Where(`"Object Type" == "Asset"`); err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 2, len(tbl.Body.Rows))
+
+	tbl = sampleTable()
+	if err := tbl.Where(`Owner == 'Alice' && ("Object Type" =~ "^Asset" || Id > 5)`); err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 1, len(tbl.Body.Rows))
+	expectedString(t, "https://c.example.edu", tbl.Body.Rows[0][0])
+
+	tbl = sampleTable()
+	if err := tbl.Where(`! Owner != "Carol"`); err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 1, len(tbl.Body.Rows))
+
+	// Operators need no spaces between them
+	tbl = sampleTable()
+	if err := tbl.Where(`Owner=="Alice"&&!Id<=5`); err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 1, len(tbl.Body.Rows))
+	tokens, err := tokenizeWhere(`a=="x"&&!b`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, `a,==,"x,&&,!,b`, joinRow(tokens))
+
+	for _, expr := range []string{`Owner ==`, `Owner &&& "x"`, `"Owner == "Bob"`, `Missing == "x"`, `Owner ?? "x"`, `(Owner == "Bob"`} {
+		tbl = sampleTable()
+		if err := tbl.Where(expr); err == nil {
+			t.Errorf("expected an error for %s", expr)
+		}
+	}
+}
+
+func TestTableSelect(t *testing.T) {
+	tbl := sampleTable()
+	if err := tbl.Select("Id", "url"); err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 2, len(tbl.Head.Row))
+	expectedString(t, "Id", tbl.Head.Row[0])
+	expectedString(t, "URL", tbl.Head.Row[1])
+	expectedString(t, "10", tbl.Body.Rows[0][0])
+	expectedString(t, "https://a.example.edu", tbl.Body.Rows[0][1])
+}
+
+func TestTableGroupBy(t *testing.T) {
+	tbl := sampleTable()
+	grouped, err := tbl.GroupBy("Owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, "Count", grouped.Head.Row[1])
+	expectedInt(t, 3, len(grouped.Body.Rows))
+	expectedString(t, "Bob", grouped.Body.Rows[0][0])
+	expectedString(t, "1", grouped.Body.Rows[0][1])
+	expectedString(t, "Alice", grouped.Body.Rows[1][0])
+	expectedString(t, "2", grouped.Body.Rows[1][1])
+}
+
+func TestTableOptions(t *testing.T) {
+	opts := &TableOptions{
+		Where:   `"Object Type" != "Page"`,
+		GroupBy: SplitColumns("Owner"),
+		Sort:    SplitColumns("-Count, Owner"),
+		Columns: SplitColumns("Owner"),
+	}
+	tbl, err := opts.Apply(sampleTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 1, len(tbl.Head.Row))
+	expectedInt(t, 3, len(tbl.Body.Rows))
+	expectedString(t, "Alice", tbl.Body.Rows[0][0])
+	expectedString(t, "Bob", tbl.Body.Rows[1][0])
+	expectedString(t, "Carol", tbl.Body.Rows[2][0])
+}
@@ -34,11 +34,11 @@
 package springytools
 
 import (
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"encoding/xml"
-	"io/ioutil"
-	"os"
+	"fmt"
 )
 
 type THead struct {
@@ -51,6 +51,40 @@ type TBody struct {
 	Rows    [][]string `xml:"tr>td" json:"rows,omitempty"`
 }
 
+// MarshalXML renders each row of the body as a tr element holding
+// td elements.
+func (b TBody) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "tbody"}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	tr := xml.StartElement{Name: xml.Name{Local: "tr"}}
+	for _, row := range b.Rows {
+		if err := e.EncodeElement(struct {
+			Cells []string `xml:"td"`
+		}{row}, tr); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// UnmarshalXML reads the tr elements of a tbody back into rows.
+func (b *TBody) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	body := struct {
+		Rows []struct {
+			Cells []string `xml:"td"`
+		} `xml:"tr"`
+	}{}
+	if err := d.DecodeElement(&body, &start); err != nil {
+		return err
+	}
+	for _, row := range body.Rows {
+		b.Rows = append(b.Rows, row.Cells)
+	}
+	return nil
+}
+
 type Table struct {
 	XMLName xml.Name `xml:"table" json:"-"`
 	Caption string   `xml:"caption" json:"caption,omitempty"`
@@ -78,51 +112,88 @@ func (t *Table) ToJSON() ([]byte, error) {
 	return json.MarshalIndent(t, "", "\t")
 }
 
-// ToXMLFile will creates an XML (HTML) version of Table, it is a destructive write.
-// A file with the same name will be replaced. Accepts the filename and Returns an error
-// if one is encountered.
-func (t *Table) ToXMLFile(destName string) error {
-	src, err := t.ToXML()
+// ToCSV renders the table as CSV. If header is true and the table's
+// header is populated it will render a header row at the start of the
+// CSV output. Returns the CSV and an error if one is encountered.
+func (t *Table) ToCSV(header bool) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	if header {
+		if (t.Head.Row != nil) && (len(t.Head.Row) > 0) {
+			if err := w.Write(t.Head.Row); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, row := range t.Body.Rows {
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ToFormat renders the table in the named format (i.e. csv, json,
+// xml, see ReportFormat). CSV output includes a header row.
+func (t *Table) ToFormat(format string) ([]byte, error) {
+	rptFmt, err := ReportFormat(format, "")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	switch rptFmt {
+	case "csv":
+		return t.ToCSV(true)
+	case "xml":
+		return t.ToXML()
+	case "json":
+		return t.ToJSON()
 	}
-	return ioutil.WriteFile(destName, src, 0777)
+	return nil, fmt.Errorf("%q is not a supported format", format)
 }
 
-// ToJSONFile will creates a JSON version of Table, it is a destructive write.
-// A file with the same name will be replaced. Accepts the filename and Returns an error
-// if one is encountered.
-func (t *Table) ToJSONFile(destName string) error {
-	src, err := t.ToJSON()
+// ToFile writes the table to destName in format. If format is an empty
+// string it is guessed from destName's extension (see ReportFormat).
+// A destName of "-" writes to standard output. Files are replaced using
+// DefaultWriteOptions (see WriteDestination). Returns an error if one
+// is encountered.
+func (t *Table) ToFile(destName string, format string) error {
+	rptFmt, err := ReportFormat(format, destName)
+	if err != nil {
+		return err
+	}
+	src, err := t.ToFormat(rptFmt)
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(destName, src, 0777)
+	return WriteDestination(destName, src)
+}
+
+// ToXMLFile will creates an XML (HTML) version of Table. A file with the same
+// name is replaced once the write succeeds (see WriteDestination). Accepts the
+// filename and Returns an error if one is encountered.
+func (t *Table) ToXMLFile(destName string) error {
+	return t.ToFile(destName, "xml")
+}
+
+// ToJSONFile will creates a JSON version of Table. A file with the same
+// name is replaced once the write succeeds (see WriteDestination). Accepts the
+// filename and Returns an error if one is encountered.
+func (t *Table) ToJSONFile(destName string) error {
+	return t.ToFile(destName, "json")
 }
 
-// ToCSVFile will create a CSV version of Table, it is a destructive write.
-// A file with the same name will be replaced. Accepts the filename and header boolean.
+// ToCSVFile will create a CSV version of Table. A file with the same name is
+// replaced once the write succeeds (see WriteDestination). Accepts the filename and header boolean.
 // if header is true and the table's header is populated it will render a header row at
 // start of the CSV output. Returns an error if one is encountered.
 func (t *Table) ToCSVFile(destName string, header bool) error {
-	fp, err := os.Create(destName)
+	src, err := t.ToCSV(header)
 	if err != nil {
 		return err
 	}
-	w := csv.NewWriter(fp)
-	if header {
-		if (t.Head.Row != nil) && (len(t.Head.Row) > 0) {
-			if err = w.Write(t.Head.Row); err != nil {
-				return err
-			}
-		}
-	}
-	for _, row := range t.Body.Rows {
-		if err = w.Write(row); err != nil {
-			return err
-		}
-	}
-	w.Flush()
-	err = w.Error()
-	return nil
+	return WriteDestination(destName, src)
 }
@@ -35,8 +35,11 @@ package springytools
 
 import (
 	"bufio"
+	"encoding/json"
 	"encoding/xml"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -109,7 +112,52 @@ func TestTableWriting(t *testing.T) {
 			i++
 		}
 	}
-	//FIXME: Need to test xml (HTML) output and JSON output
-	t.Errorf("Testing for XML (HTML) output not implemented.")
-	t.Errorf("Testing for JSON output not implemented.")
+
+	// Read back the XML (HTML) and JSON output and compare to the table.
+	checkTable := func(got *Table) {
+		expectedString(t, tbl.Caption, got.Caption)
+		expectedInt(t, len(tbl.Head.Row), len(got.Head.Row))
+		expectedInt(t, len(tbl.Body.Rows), len(got.Body.Rows))
+		for i, heading := range tbl.Head.Row {
+			if i < len(got.Head.Row) {
+				expectedString(t, heading, got.Head.Row[i])
+			}
+		}
+		for i, row := range tbl.Body.Rows {
+			if i < len(got.Body.Rows) {
+				expectedString(t, strings.Join(row, ","), strings.Join(got.Body.Rows[i], ","))
+			}
+		}
+	}
+	fName = "testout/table.html"
+	if err := tbl.ToXMLFile(fName); err != nil {
+		t.Errorf("Write fail for %q: %s", fName, err)
+	}
+	if src, err := ioutil.ReadFile(fName); err != nil {
+		t.Errorf("Failed to read %q: %s", fName, err)
+	} else {
+		got := new(Table)
+		if err := xml.Unmarshal(src, got); err != nil {
+			t.Errorf("Failed to parse %q: %s", fName, err)
+		}
+		checkTable(got)
+	}
+	fName = "testout/table.json"
+	if err := tbl.ToJSONFile(fName); err != nil {
+		t.Errorf("Write fail for %q: %s", fName, err)
+	}
+	if src, err := ioutil.ReadFile(fName); err != nil {
+		t.Errorf("Failed to read %q: %s", fName, err)
+	} else {
+		got := new(Table)
+		if err := json.Unmarshal(src, got); err != nil {
+			t.Errorf("Failed to parse %q: %s", fName, err)
+		}
+		checkTable(got)
+	}
+	if info, err := os.Stat(fName); err == nil {
+		if info.Mode().Perm() != 0644 {
+			t.Errorf("expected mode 0644 for %q, got %o", fName, info.Mode().Perm())
+		}
+	}
 }
@@ -0,0 +1,291 @@
+// text.go extracts the plain text of the HTML descriptions in a LibGuides export.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	// 3rd Party Packages
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	// reHiddenHTML matches comments (including Word's conditional
+	// comments) and the content of script and style elements
+	reHiddenHTML = regexp.MustCompile(`(?is)<!--.*?-->|<script\b.*?</script\s*>|<style\b.*?</style\s*>`)
+	// reHTMLTag matches an HTML tag
+	reHTMLTag = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// decodeDescription returns the HTML of a description, decoding the
+// markup when the description has been encoded twice.
+func decodeDescription(s string) string {
+	if !strings.Contains(s, "<") && strings.Contains(s, "&lt;") {
+		return html.UnescapeString(s)
+	}
+	return s
+}
+
+// parseDescription parses the HTML of a description as the content of
+// a body element.
+func parseDescription(s string) ([]*xhtml.Node, error) {
+	body := &xhtml.Node{Type: xhtml.ElementNode, Data: "body", DataAtom: atom.Body}
+	return xhtml.ParseFragment(strings.NewReader(decodeDescription(s)), body)
+}
+
+// textSkipped are the elements whose content isn't text
+var textSkipped = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Head: true, atom.Title: true,
+	atom.Meta: true, atom.Link: true, atom.Noscript: true, atom.Template: true,
+}
+
+// textBlocks are the elements starting a new paragraph
+var textBlocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.Ul: true, atom.Ol: true,
+	atom.Dl: true, atom.Table: true, atom.Blockquote: true, atom.Pre: true,
+	atom.Section: true, atom.Article: true, atom.Header: true, atom.Footer: true,
+	atom.Nav: true, atom.Aside: true, atom.Main: true, atom.Figure: true,
+	atom.Address: true, atom.Form: true, atom.Fieldset: true, atom.Hr: true,
+}
+
+// textWriter renders HTML nodes as plain text, collapsing white space
+// and keeping paragraph and line breaks.
+type textWriter struct {
+	sb        strings.Builder
+	space     bool
+	breaks    int
+	footnotes bool
+	links     []string
+	linkIndex map[string]int
+}
+
+// lineBreak requests n newlines before the next text.
+func (w *textWriter) lineBreak(n int) {
+	if n > w.breaks {
+		w.breaks = n
+	}
+}
+
+// write adds text after any pending line break or space.
+func (w *textWriter) write(s string) {
+	if w.sb.Len() > 0 {
+		if w.breaks > 0 {
+			w.sb.WriteString(strings.Repeat("\n", w.breaks))
+		} else if w.space {
+			w.sb.WriteString(" ")
+		}
+	}
+	w.breaks, w.space = 0, false
+	w.sb.WriteString(s)
+}
+
+// text adds the words of a text node.
+func (w *textWriter) text(s string) {
+	for i := 0; i < 3 && strings.Contains(s, "&"); i++ {
+		unescaped := html.UnescapeString(s)
+		if unescaped == s {
+			break
+		}
+		s = unescaped
+	}
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		w.space = w.space || s != ""
+		return
+	}
+	if strings.TrimLeftFunc(s[:1], unicode.IsSpace) == "" {
+		w.space = true
+	}
+	w.write(strings.Join(words, " "))
+	last, _ := utf8.DecodeLastRuneInString(s)
+	w.space = unicode.IsSpace(last)
+}
+
+// footnote returns the footnote number of a link, 0 if the link
+// doesn't get one.
+func (w *textWriter) footnote(href string) int {
+	href = strings.TrimSpace(href)
+	lower := strings.ToLower(href)
+	if !w.footnotes || href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(lower, "javascript:") {
+		return 0
+	}
+	if n, ok := w.linkIndex[href]; ok {
+		return n
+	}
+	w.links = append(w.links, href)
+	w.linkIndex[href] = len(w.links)
+	return len(w.links)
+}
+
+func (w *textWriter) walk(n *xhtml.Node) {
+	switch n.Type {
+	case xhtml.TextNode:
+		w.text(n.Data)
+		return
+	case xhtml.ElementNode:
+	default:
+		return
+	}
+	// Word's <xml> islands aren't known to the parser
+	if textSkipped[n.DataAtom] || strings.EqualFold(n.Data, "xml") {
+		return
+	}
+	switch {
+	case n.DataAtom == atom.Br:
+		w.lineBreak(1)
+		return
+	case n.DataAtom == atom.Img:
+		if alt, _ := attr(n, "alt"); strings.TrimSpace(alt) != "" {
+			w.text(" " + alt + " ")
+		}
+		return
+	case n.DataAtom == atom.Li:
+		w.lineBreak(1)
+		w.write("- ")
+	case n.DataAtom == atom.Tr || n.DataAtom == atom.Dt || n.DataAtom == atom.Dd:
+		w.lineBreak(1)
+	case n.DataAtom == atom.Td || n.DataAtom == atom.Th:
+		w.space = true
+	case textBlocks[n.DataAtom]:
+		w.lineBreak(2)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+	switch {
+	case n.DataAtom == atom.A:
+		href, _ := attr(n, "href")
+		if strings.TrimSpace(nodeText(n)) == strings.TrimSpace(href) {
+			break
+		}
+		if i := w.footnote(href); i > 0 {
+			space := w.space
+			w.write(fmt.Sprintf("[%d]", i))
+			w.space = space
+		}
+	case textBlocks[n.DataAtom]:
+		w.lineBreak(2)
+	}
+}
+
+// DescriptionText returns the readable plain text of an HTML
+// description. Descriptions may have been encoded twice so escaped
+// markup is decoded and entities are decoded until none remain.
+// Scripts, styles, comments and Word's Office markup are removed,
+// paragraphs are separated by a blank line, line breaks, list items
+// and table rows start a new line. Links are followed by a footnote
+// number, e.g. "Web of Science[1]", and the footnotes are listed at the
+// end ("[1] https://...").
+func DescriptionText(s string) string {
+	return plainText(s, true)
+}
+
+// plainText renders a description as text, with or without link
+// footnotes.
+func plainText(s string, footnotes bool) string {
+	if strings.TrimSpace(s) == "" {
+		return ""
+	}
+	nodes, err := parseDescription(s)
+	if err != nil {
+		return strippedText(s)
+	}
+	w := &textWriter{footnotes: footnotes, linkIndex: map[string]int{}}
+	for _, n := range nodes {
+		w.walk(n)
+	}
+	if len(w.links) > 0 {
+		w.lineBreak(2)
+		for i, link := range w.links {
+			w.write(fmt.Sprintf("[%d] %s", i+1, link))
+			w.lineBreak(1)
+		}
+	}
+	return w.sb.String()
+}
+
+// descriptionText returns the plain text of an HTML description on a
+// single line, see DescriptionText.
+func descriptionText(s string) string {
+	return strings.Join(strings.Fields(plainText(s, false)), " ")
+}
+
+// strippedText removes the tags of a description which can't be
+// parsed, decoding entities and collapsing white space.
+func strippedText(s string) string {
+	s = decodeDescription(s)
+	s = reHiddenHTML.ReplaceAllString(s, " ")
+	s = reHTMLTag.ReplaceAllString(s, " ")
+	for i := 0; i < 3 && strings.Contains(s, "&"); i++ {
+		unescaped := html.UnescapeString(s)
+		if unescaped == s {
+			break
+		}
+		s = unescaped
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// SetDescriptionText sets the DescriptionText of the groups, guides,
+// pages and assets to the plain text of their descriptions (see
+// DescriptionText) so it is included in the JSON.
+func (lg *LibGuides) SetDescriptionText() {
+	for _, group := range lg.Groups {
+		group.DescriptionText = DescriptionText(group.Description)
+	}
+	for _, guide := range lg.Guides {
+		guide.DescriptionText = DescriptionText(guide.Description)
+		guide.Group.DescriptionText = DescriptionText(guide.Group.Description)
+		for _, page := range guide.Pages {
+			page.DescriptionText = DescriptionText(page.Description)
+			for _, box := range page.Boxes {
+				for _, asset := range box.Assets {
+					asset.DescriptionText = DescriptionText(asset.Description)
+				}
+				for _, pane := range box.Panes {
+					for _, asset := range pane.Assets {
+						asset.DescriptionText = DescriptionText(asset.Description)
+					}
+				}
+			}
+		}
+	}
+}
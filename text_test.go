@@ -0,0 +1,103 @@
+// text_test.go tests extracting plain text from descriptions.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDescriptionText(t *testing.T) {
+	for _, test := range []struct {
+		src, expected string
+	}{
+		{"", ""},
+		{"<p>Hello <b>World</b></p>", "Hello World"},
+		{"&lt;p&gt;Fish &amp;amp; Chips&lt;/p&gt;", "Fish & Chips"},
+		{"<!--[if gte mso 9]><xml>junk</xml><![endif]--><p>Word\n\n text</p>", "Word text"},
+		{"<style>p { color: red; }</style><script>alert(1)</script><p>Safe</p>", "Safe"},
+		{"<p>Caf&eacute; &amp;lt;menu&amp;gt;</p>", "Café <menu>"},
+	} {
+		expectedString(t, test.expected, descriptionText(test.src))
+	}
+}
+
+func TestPublicDescriptionText(t *testing.T) {
+	for _, test := range []struct {
+		src, expected string
+	}{
+		{"", ""},
+		{"&lt;p&gt;Start with &lt;a href=&quot;https://www.webofscience.com&quot;&gt;Web of Science&lt;/a&gt;.&lt;/p&gt;&lt;p&gt;Then ask.&lt;/p&gt;",
+			"Start with Web of Science[1].\n\nThen ask.\n\n[1] https://www.webofscience.com"},
+		{`<p class="MsoNormal">One<o:p></o:p></p><p>Two<br>lines <a href="https://example.org/">https://example.org/</a></p>`,
+			"One\n\nTwo\nlines https://example.org/"},
+		{`<ul><li><a href="https://a.example.org">A</a></li><li><a href="https://a.example.org">A again</a> and <a href="#top">top</a></li></ul>`,
+			"- A[1]\n- A again[1] and top\n\n[1] https://a.example.org"},
+		{`<style>.x { color: red }</style><table><tr><th>Name</th><th>Phone</th></tr><tr><td>Desk</td><td>x1234</td></tr></table>`,
+			"Name Phone\nDesk x1234"},
+		{`<p>Fish &amp;amp; Chips <img src="x.png" alt="logo"></p>`, "Fish & Chips logo"},
+	} {
+		expectedString(t, test.expected, DescriptionText(test.src))
+	}
+}
+
+func TestSetDescriptionText(t *testing.T) {
+	lg := &LibGuides{
+		Guides: []*Guide{{Id: 1, Description: "&lt;p&gt;Guide&lt;/p&gt;", Pages: []*Page{{Id: 2, Boxes: []*Box{{
+			Panes: []*Pane{{Assets: []*Asset{{Id: 3, Description: `<p>See <a href="https://example.org">this</a></p>`}}}},
+		}}}}}},
+	}
+	src, _ := lg.ToJSON()
+	if strings.Contains(string(src), "description_text") {
+		t.Errorf("expected no description_text before SetDescriptionText")
+	}
+	lg.SetDescriptionText()
+	expectedString(t, "Guide", lg.Guides[0].DescriptionText)
+	expectedString(t, "See this[1]\n\n[1] https://example.org", lg.Guides[0].Pages[0].Boxes[0].Panes[0].Assets[0].DescriptionText)
+	src, _ = lg.ToJSON()
+	if !strings.Contains(string(src), `"description_text": "Guide"`) {
+		t.Errorf("expected description_text in JSON, got %s", src)
+	}
+	files, err := lg.ToJSONLines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(files["assets"]), `"description_text":"See this[1]\n\n[1] https://example.org"`) {
+		t.Errorf("expected description_text in assets.jsonl, got %s", files["assets"])
+	}
+	src, _ = lg.ToXML()
+	if strings.Contains(string(src), "See this[1]") {
+		t.Errorf("expected no description text in XML, got %s", src)
+	}
+}
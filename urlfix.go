@@ -0,0 +1,568 @@
+// urlfix.go replaces old URLs with new ones in assets, planning the changes
+// as a dry run and applying them through the LibGuides API.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultAuditLog is the file the fixurls command appends the
+	// changes made through the API to
+	DefaultAuditLog = "fixurls-audit.jsonl"
+
+	// URLFixPlanned is the status of a change not yet applied
+	URLFixPlanned = "planned"
+	// URLFixUpdated is the status of a change applied through the API
+	URLFixUpdated = "updated"
+	// URLFixSkipped is the status of a change to an asset which was
+	// changed by someone else since the change was planned
+	URLFixSkipped = "skipped, changed since planned"
+	// URLFixFailed prefixes the status of a change the API refused
+	URLFixFailed = "failed"
+)
+
+// URLFix is a URL and its replacement.
+type URLFix struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// urlFixColumns are the headings of the old and new URL columns of a
+// mapping, e.g. a link report with a suggested URL column.
+var urlFixColumns = map[string][]string{
+	"old": {"old url", "old", "url"},
+	"new": {"new url", "new", "suggested url", "redirect url", "redirect"},
+}
+
+// looksLikeURL returns true if s could be a URL rather than a heading.
+func looksLikeURL(s string) bool {
+	return strings.Contains(s, "://") || strings.HasPrefix(strings.ToLower(s), "mailto:") || strings.HasPrefix(s, `\\`)
+}
+
+// ParseURLFixes reads a CSV mapping old URLs to new URLs. The first
+// column is the old URL and the second the new one. A header row is
+// skipped when its first column doesn't look like a URL, it may name
+// the columns instead, "Old URL" (or "URL") and "New URL" (or
+// "Suggested URL" or "Redirect"). Rows without a new URL are skipped.
+// An old URL mapped to different new URLs is an error.
+func ParseURLFixes(src []byte) ([]*URLFix, error) {
+	r := csv.NewReader(bytes.NewReader(src))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	fixes := []*URLFix{}
+	seen := map[string]*URLFix{}
+	oldCol, newCol := 0, 1
+	for i := 0; ; i++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 && len(row) > 0 && !looksLikeURL(row[0]) {
+			columns := map[string]int{}
+			for j, heading := range row {
+				columns[strings.ToLower(strings.TrimSpace(heading))] = j
+			}
+			for name, headings := range urlFixColumns {
+				for _, heading := range headings {
+					if j, ok := columns[heading]; ok {
+						if name == "old" {
+							oldCol = j
+						} else {
+							newCol = j
+						}
+						break
+					}
+				}
+			}
+			continue
+		}
+		if len(row) <= oldCol || len(row) <= newCol {
+			continue
+		}
+		fix := &URLFix{Old: strings.TrimSpace(row[oldCol]), New: strings.TrimSpace(row[newCol])}
+		if fix.Old == "" || fix.New == "" || fix.Old == fix.New {
+			continue
+		}
+		if prev, ok := seen[fix.Old]; ok {
+			if prev.New != fix.New {
+				return nil, fmt.Errorf("line %d, %q is mapped to both %q and %q", i+1, fix.Old, prev.New, fix.New)
+			}
+			continue
+		}
+		seen[fix.Old] = fix
+		fixes = append(fixes, fix)
+	}
+	return fixes, nil
+}
+
+// ReadURLFixes reads a CSV mapping old URLs to new URLs (see
+// ParseURLFixes) from a file, "-" reads standard input.
+func ReadURLFixes(srcName string) ([]*URLFix, error) {
+	src, err := ReadSource(srcName)
+	if err != nil {
+		return nil, err
+	}
+	fixes, err := ParseURLFixes(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s, %s", srcName, err)
+	}
+	return fixes, nil
+}
+
+// isURLByte returns true if c may appear in a URL.
+func isURLByte(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+		strings.IndexByte("-._~:/?#[]@!$'()*+,;=%&", c) >= 0
+}
+
+// urlEndsAt returns true if a URL found in s can end at end, i.e. it
+// isn't followed by more of a longer URL. Trailing punctuation and
+// entities such as "&quot;" (possibly encoded again) end a URL.
+func urlEndsAt(s string, end int) bool {
+	for ; end < len(s); end++ {
+		c := s[end]
+		switch {
+		case c == '&':
+			rest := s[end+1:]
+			for strings.HasPrefix(rest, "amp;") {
+				rest = rest[4:]
+			}
+			for _, entity := range []string{"quot;", "lt;", "gt;", "apos;", "nbsp;", "#"} {
+				if strings.HasPrefix(rest, entity) {
+					return true
+				}
+			}
+			return false
+		case strings.IndexByte(".,;:!?)'", c) >= 0:
+			// Punctuation ends a URL when nothing more of a URL follows
+			continue
+		default:
+			return !isURLByte(c)
+		}
+	}
+	return true
+}
+
+// replaceURLs replaces the old URLs of fixes in s with the new ones,
+// including HTML encoded ("&" as "&amp;", possibly twice) URLs. When s
+// is HTML (isHTML) and an old URL has no "&" to match the encoding by,
+// the new URL's "&" is encoded as the description is. The longest
+// match wins and replacements aren't replaced again. Returns the new
+// string and the fixes applied.
+func replaceURLs(s string, fixes []*URLFix, isHTML bool) (string, []*URLFix) {
+	type variant struct {
+		old, new string
+		fix      *URLFix
+	}
+	variants := []*variant{}
+	for _, fix := range fixes {
+		old, new := fix.Old, fix.New
+		if !strings.Contains(old, "&") {
+			if isHTML {
+				new = strings.ReplaceAll(new, "&", "&amp;")
+				if decodeDescription(s) != s {
+					new = strings.ReplaceAll(new, "&", "&amp;")
+				}
+			}
+			variants = append(variants, &variant{old, new, fix})
+			continue
+		}
+		for i := 0; i < 3; i++ {
+			variants = append(variants, &variant{old, new, fix})
+			old, new = strings.ReplaceAll(old, "&", "&amp;"), strings.ReplaceAll(new, "&", "&amp;")
+		}
+	}
+	sort.SliceStable(variants, func(i, j int) bool {
+		return len(variants[i].old) > len(variants[j].old)
+	})
+	sb := new(strings.Builder)
+	applied := []*URLFix{}
+	used := map[*URLFix]bool{}
+	for i := 0; i < len(s); {
+		matched := false
+		for _, v := range variants {
+			if strings.HasPrefix(s[i:], v.old) && urlEndsAt(s, i+len(v.old)) {
+				sb.WriteString(v.new)
+				i += len(v.old)
+				if !used[v.fix] {
+					used[v.fix] = true
+					applied = append(applied, v.fix)
+				}
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			sb.WriteByte(s[i])
+			i++
+		}
+	}
+	return sb.String(), applied
+}
+
+// URLFixUpdate is a change to an asset's url or description field
+// replacing old URLs with new ones.
+type URLFixUpdate struct {
+	AssetId  int       `json:"asset_id"`
+	Name     string    `json:"name"`
+	Field    string    `json:"field"`
+	Fixes    []*URLFix `json:"fixes"`
+	Before   string    `json:"before"`
+	After    string    `json:"after"`
+	GuideIds []int     `json:"guide_ids"`
+	Status   string    `json:"status,omitempty"`
+}
+
+// assetField returns the value of an asset's url or description.
+func assetField(asset *Asset, field string) string {
+	if field == "url" {
+		return asset.Url
+	}
+	return asset.Description
+}
+
+// PlanURLFixes returns the changes to the url and description of the
+// assets needed to replace the old URLs with the new ones, one per
+// asset and field however often the asset is reused. Content is
+// included according to the hidden policy in opts.
+func PlanURLFixes(lg *LibGuides, fixes []*URLFix, opts *Options) []*URLFixUpdate {
+	hidden := HiddenSkip
+	if opts != nil {
+		hidden = opts.Hidden
+	}
+	byOld := map[string]*URLFix{}
+	for _, fix := range fixes {
+		byOld[fix.Old] = fix
+	}
+	updates := []*URLFixUpdate{}
+	planned := map[int][]*URLFixUpdate{}
+	seen := map[int]bool{}
+	addAsset := func(guide *Guide, asset *Asset) {
+		if seen[asset.Id] {
+			for _, u := range planned[asset.Id] {
+				if u.GuideIds[len(u.GuideIds)-1] != guide.Id {
+					u.GuideIds = append(u.GuideIds, guide.Id)
+				}
+			}
+			return
+		}
+		seen[asset.Id] = true
+		if fix, ok := byOld[strings.TrimSpace(asset.Url)]; ok {
+			planned[asset.Id] = append(planned[asset.Id], &URLFixUpdate{
+				AssetId: asset.Id, Name: asset.Name, Field: "url", Fixes: []*URLFix{fix},
+				Before: asset.Url, After: fix.New, GuideIds: []int{guide.Id},
+			})
+		}
+		if description, applied := replaceURLs(asset.Description, fixes, true); len(applied) > 0 {
+			planned[asset.Id] = append(planned[asset.Id], &URLFixUpdate{
+				AssetId: asset.Id, Name: asset.Name, Field: "description", Fixes: applied,
+				Before: asset.Description, After: description, GuideIds: []int{guide.Id},
+			})
+		}
+		updates = append(updates, planned[asset.Id]...)
+	}
+	for _, guide := range lg.Guides {
+		for _, page := range guide.Pages {
+			for _, box := range page.Boxes {
+				if !hidden.Allows(page.Hidden != 0 || box.Hidden != 0) {
+					continue
+				}
+				for _, asset := range box.Assets {
+					addAsset(guide, asset)
+				}
+				for _, pane := range box.Panes {
+					for _, asset := range pane.Assets {
+						addAsset(guide, asset)
+					}
+				}
+			}
+		}
+	}
+	return updates
+}
+
+// URLFixTable returns the changes as a table with the columns "Asset
+// Id", "Name", "Field", "Old URL", "New URL", "Guide Ids", "Status",
+// "Before" and "After".
+func URLFixTable(updates []*URLFixUpdate, caption string) *Table {
+	tbl := new(Table)
+	tbl.SetCaption(caption)
+	tbl.AppendHeadings("Asset Id", "Name", "Field", "Old URL", "New URL", "Guide Ids", "Status", "Before", "After")
+	for _, u := range updates {
+		olds, news, guideIds := []string{}, []string{}, []string{}
+		for _, fix := range u.Fixes {
+			olds = append(olds, fix.Old)
+			news = append(news, fix.New)
+		}
+		for _, id := range u.GuideIds {
+			guideIds = append(guideIds, strInt(id))
+		}
+		status := u.Status
+		if status == "" {
+			status = URLFixPlanned
+		}
+		tbl.AppendRow(strInt(u.AssetId), u.Name, u.Field, strings.Join(olds, " "), strings.Join(news, " "),
+			strings.Join(guideIds, " "), status, u.Before, u.After)
+	}
+	return tbl
+}
+
+// URLFixDiff renders the changes as a diff, each change headed by the
+// asset, field and guides followed by the lines removed ("-") and
+// added ("+").
+func URLFixDiff(updates []*URLFixUpdate) []byte {
+	buf := new(bytes.Buffer)
+	for _, u := range updates {
+		guideIds := []string{}
+		for _, id := range u.GuideIds {
+			guideIds = append(guideIds, strInt(id))
+		}
+		status := u.Status
+		if status == "" {
+			status = URLFixPlanned
+		}
+		fmt.Fprintf(buf, "@@ asset %d %q %s, guides %s, %s\n", u.AssetId, u.Name, u.Field, strings.Join(guideIds, " "), status)
+		// Replacing URLs doesn't add or remove lines
+		before, after := strings.Split(u.Before, "\n"), strings.Split(u.After, "\n")
+		for i := 0; i < len(before) && i < len(after); i++ {
+			if before[i] != after[i] {
+				fmt.Fprintf(buf, "-%s\n+%s\n", before[i], after[i])
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// isDiffFormat returns true if the format (or destName's extension
+// when format is empty) asks for a diff.
+func isDiffFormat(format string, destName string) bool {
+	return formatName(format, destName) == "diff"
+}
+
+// URLFixAudit is an entry of the audit log of the changes made through
+// the API.
+type URLFixAudit struct {
+	Time    string `json:"time"`
+	Action  string `json:"action"`
+	AssetId int    `json:"asset_id"`
+	Field   string `json:"field"`
+	Before  string `json:"before"`
+	After   string `json:"after"`
+	Status  string `json:"status"`
+}
+
+// ApplyURLFixes makes the changes through the API, writing an entry to
+// audit for each with the action, e.g. "apply" or "rollback". A change
+// is skipped when the asset's field no longer has the value it was
+// planned from. The Status of each change is set and the changes made
+// are returned. After each change save, when not nil, is called with
+// the changes made so far so a rollback file is kept up to date even
+// if the run stops part way. Errors from the API are recorded in the
+// Status, the error returned is from save or writing the audit log.
+func ApplyURLFixes(c *APIClient, updates []*URLFixUpdate, audit io.Writer, action string, save func([]*URLFixUpdate) error) ([]*URLFixUpdate, error) {
+	applied := []*URLFixUpdate{}
+	enc := json.NewEncoder(audit)
+	enc.SetEscapeHTML(false)
+	for _, u := range updates {
+		asset, err := c.Asset(u.AssetId)
+		switch {
+		case err != nil:
+			u.Status = fmt.Sprintf("%s, %s", URLFixFailed, err)
+		case assetField(asset, u.Field) != u.Before:
+			u.Status = URLFixSkipped
+		default:
+			if err := c.UpdateAsset(u.AssetId, map[string]string{u.Field: u.After}); err != nil {
+				u.Status = fmt.Sprintf("%s, %s", URLFixFailed, err)
+			} else {
+				u.Status = URLFixUpdated
+				applied = append(applied, u)
+				if save != nil {
+					if err := save(applied); err != nil {
+						return applied, err
+					}
+				}
+			}
+		}
+		c.logf("asset %d %s: %s", u.AssetId, u.Field, u.Status)
+		entry := &URLFixAudit{
+			Time: time.Now().UTC().Format(time.RFC3339), Action: action,
+			AssetId: u.AssetId, Field: u.Field, Before: u.Before, After: u.After, Status: u.Status,
+		}
+		if err := enc.Encode(entry); err != nil {
+			return applied, err
+		}
+	}
+	return applied, nil
+}
+
+// ReverseURLFixes returns the changes undoing updates, e.g. those
+// read from a rollback file.
+func ReverseURLFixes(updates []*URLFixUpdate) []*URLFixUpdate {
+	reversed := []*URLFixUpdate{}
+	for _, u := range updates {
+		r := &URLFixUpdate{
+			AssetId: u.AssetId, Name: u.Name, Field: u.Field,
+			Before: u.After, After: u.Before, GuideIds: u.GuideIds,
+		}
+		for _, fix := range u.Fixes {
+			r.Fixes = append(r.Fixes, &URLFix{Old: fix.New, New: fix.Old})
+		}
+		reversed = append(reversed, r)
+	}
+	return reversed
+}
+
+// ReadURLFixRollback reads a rollback file, the JSON list of changes
+// made by ApplyURLFixes. Use ReverseURLFixes to undo them.
+func ReadURLFixRollback(srcName string) ([]*URLFixUpdate, error) {
+	src, err := ReadSource(srcName)
+	if err != nil {
+		return nil, err
+	}
+	updates := []*URLFixUpdate{}
+	if err := json.Unmarshal(src, &updates); err != nil {
+		return nil, fmt.Errorf("%s, %s", srcName, err)
+	}
+	return updates, nil
+}
+
+// FixURLsReport plans the changes replacing the URLs mapped in the CSV
+// opts.URLMap (see ParseURLFixes) in the assets of a LibGuides export
+// or, with opts.Source "api", the live site. With opts.Undo the
+// changes in that rollback file are undone instead. The changes are
+// written to destName as a table, or as a diff when the format is
+// "diff". With opts.Apply the changes are made through the API,
+// appending to the audit log opts.AuditLog and writing the changes
+// made to the rollback file opts.RollbackFile.
+func FixURLsReport(srcName string, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	if opts.Apply && !opts.FromAPI() {
+		return fmt.Errorf("changes are applied through the API, use -source api")
+	}
+	var (
+		updates []*URLFixUpdate
+		caption string
+	)
+	action := "apply"
+	if opts.Undo != "" {
+		applied, err := ReadURLFixRollback(opts.Undo)
+		if err != nil {
+			return err
+		}
+		updates, caption, action = ReverseURLFixes(applied), fmt.Sprintf("Rollback of %q", opts.Undo), "rollback"
+	} else {
+		if opts.URLMap == "" {
+			return fmt.Errorf("missing -map, a CSV of old and new URLs")
+		}
+		fixes, err := ReadURLFixes(opts.URLMap)
+		if err != nil {
+			return err
+		}
+		lg, err := opts.ReadLibGuides(srcName)
+		if err != nil {
+			return err
+		}
+		opts.Logf("read %d guides from %q", len(lg.Guides), srcName)
+		updates, caption = PlanURLFixes(lg, fixes, opts), fmt.Sprintf("URL fixes for %q", srcName)
+	}
+	opts.Logf("planned %d changes", len(updates))
+	if opts.Apply {
+		c, err := opts.APIClient()
+		if err != nil {
+			return err
+		}
+		auditLog := opts.AuditLog
+		if auditLog == "" {
+			auditLog = DefaultAuditLog
+		}
+		audit, err := os.OpenFile(auditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0664)
+		if err != nil {
+			return err
+		}
+		// The rollback file is rewritten after each change so the
+		// changes made have a rollback however the run ends
+		var save func([]*URLFixUpdate) error
+		rollback, rollbackOpts := opts.RollbackFile, opts.WriteOptions
+		if action == "apply" {
+			if rollback == "" {
+				rollback = fmt.Sprintf("fixurls-rollback-%s.json", time.Now().Format("20060102150405"))
+			}
+			save = func(applied []*URLFixUpdate) error {
+				src, err := json.MarshalIndent(applied, "", "    ")
+				if err != nil {
+					return err
+				}
+				if err := WriteDestinationWithOptions(rollback, src, &rollbackOpts); err != nil {
+					return err
+				}
+				// Later saves replace the file written by this run
+				rollbackOpts.NoClobber, rollbackOpts.Backup = false, false
+				return nil
+			}
+		}
+		applied, err := ApplyURLFixes(c, updates, audit, action, save)
+		if cerr := audit.Close(); err == nil {
+			err = cerr
+		}
+		if len(applied) > 0 && save != nil {
+			opts.Logf("wrote rollback file %q", rollback)
+		}
+		if err != nil {
+			return err
+		}
+		opts.Logf("made %d of %d changes, see %q", len(applied), len(updates), auditLog)
+	}
+	if isDiffFormat(opts.Format, destName) {
+		return WriteDestinationWithOptions(destName, URLFixDiff(updates), &opts.WriteOptions)
+	}
+	return opts.WriteTable(URLFixTable(updates, caption), destName)
+}
@@ -0,0 +1,271 @@
+// urlfix_test.go tests planning, applying and undoing URL fixes in urlfix.go.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseURLFixes(t *testing.T) {
+	fixes, err := ParseURLFixes([]byte(`Owner,URL,Suggested URL
+jdoe,http://ppubs.uspto.gov/pubwebapp/,https://ppubs.uspto.gov/pubwebapp/
+jdoe,https://example.edu/same,https://example.edu/same
+ssmith,https://example.edu/dead,
+jdoe,http://ppubs.uspto.gov/pubwebapp/,https://ppubs.uspto.gov/pubwebapp/
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 1, len(fixes))
+	if len(fixes) == 1 {
+		expectedString(t, "http://ppubs.uspto.gov/pubwebapp/", fixes[0].Old)
+		expectedString(t, "https://ppubs.uspto.gov/pubwebapp/", fixes[0].New)
+	}
+	fixes, err = ParseURLFixes([]byte("https://a.example.edu/,https://b.example.edu/\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 1, len(fixes))
+	if _, err := ParseURLFixes([]byte("https://a.example.edu/,https://b.example.edu/\nhttps://a.example.edu/,https://c.example.edu/\n")); err == nil {
+		t.Errorf("expected an old URL mapped twice to be an error")
+	}
+}
+
+// failingWriter fails after n writes.
+type failingWriter struct {
+	strings.Builder
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, fmt.Errorf("disk full")
+	}
+	w.n--
+	return w.Builder.Write(p)
+}
+
+func TestReplaceURLs(t *testing.T) {
+	fixes := []*URLFix{
+		{Old: "https://a.example.edu/x", New: "https://b.example.edu/x"},
+		{Old: "https://a.example.edu/x?id=1&q=2", New: "https://b.example.edu/y"},
+		{Old: "https://b.example.edu/x", New: "https://c.example.edu/x"},
+	}
+	for _, test := range []struct {
+		src, expected string
+		applied       int
+	}{
+		{"See https://a.example.edu/x.", "See https://b.example.edu/x.", 1},
+		{"See https://a.example.edu/xyz and https://a.example.edu/x/1", "See https://a.example.edu/xyz and https://a.example.edu/x/1", 0},
+		{`<a href="https://a.example.edu/x">x</a>`, `<a href="https://b.example.edu/x">x</a>`, 1},
+		{"&lt;a href=&quot;https://a.example.edu/x&quot;&gt;", "&lt;a href=&quot;https://b.example.edu/x&quot;&gt;", 1},
+		{"&lt;a href=&amp;quot;https://a.example.edu/x?id=1&amp;amp;q=2&amp;quot;&gt;", "&lt;a href=&amp;quot;https://b.example.edu/y&amp;quot;&gt;", 1},
+		{"https://a.example.edu/x?id=1&q=3", "https://a.example.edu/x?id=1&q=3", 0},
+		// Replacements aren't replaced again
+		{"https://a.example.edu/x https://b.example.edu/x", "https://b.example.edu/x https://c.example.edu/x", 2},
+	} {
+		got, applied := replaceURLs(test.src, fixes, false)
+		expectedString(t, test.expected, got)
+		expectedInt(t, test.applied, len(applied))
+	}
+	// A new URL with an "&" replacing one without is encoded as the
+	// HTML is
+	fixes = []*URLFix{{Old: "https://a.example.edu/z", New: "https://b.example.edu/z?a=1&b=2"}}
+	for _, test := range []struct {
+		src, expected string
+		isHTML        bool
+	}{
+		{"https://a.example.edu/z", "https://b.example.edu/z?a=1&b=2", false},
+		{`<a href="https://a.example.edu/z">z</a>`, `<a href="https://b.example.edu/z?a=1&amp;b=2">z</a>`, true},
+		{"&lt;a href=&quot;https://a.example.edu/z&quot;&gt;", "&lt;a href=&quot;https://b.example.edu/z?a=1&amp;amp;b=2&quot;&gt;", true},
+	} {
+		got, applied := replaceURLs(test.src, fixes, test.isHTML)
+		expectedString(t, test.expected, got)
+		expectedInt(t, 1, len(applied))
+	}
+}
+
+func TestPlanURLFixes(t *testing.T) {
+	lg, err := ReadLibGuides(filepath.Join("testinput", "api", "LibGuides_export_api.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixes := []*URLFix{
+		{Old: "https://patents.google.com/", New: "https://www.google.com/patents/"},
+		{Old: "http://ppubs.uspto.gov/pubwebapp/", New: "https://ppubs.uspto.gov/pubwebapp/"},
+	}
+	updates := PlanURLFixes(lg, fixes, nil)
+	tbl := URLFixTable(updates, "fixes")
+	expectedInt(t, 3, len(tbl.Body.Rows))
+	if len(tbl.Body.Rows) != 3 {
+		t.FailNow()
+	}
+	expectedString(t, "21001,Google Patents,url,https://patents.google.com/,https://www.google.com/patents/,512671 512672,planned", joinRow(tbl.Body.Rows[0][0:7]))
+	expectedString(t, "21003,url,https://ppubs.uspto.gov/pubwebapp/", tbl.Body.Rows[1][0]+","+tbl.Body.Rows[1][2]+","+tbl.Body.Rows[1][8])
+	expectedString(t, "21004,description,512671", tbl.Body.Rows[2][0]+","+tbl.Body.Rows[2][2]+","+tbl.Body.Rows[2][5])
+	expectedString(t, "&lt;p&gt;Start with &lt;a href=&quot;https://www.google.com/patents/&quot;&gt;Google Patents&lt;/a&gt;.&lt;/p&gt;", tbl.Body.Rows[2][8])
+
+	diff := string(URLFixDiff(updates[0:1]))
+	expectedString(t, "@@ asset 21001 \"Google Patents\" url, guides 512671 512672, planned\n-https://patents.google.com/\n+https://www.google.com/patents/\n", diff)
+	expectedInt(t, 1, len(PlanURLFixes(lg, fixes[1:], &Options{Hidden: HiddenSkip})))
+}
+
+func TestFixURLsReport(t *testing.T) {
+	fake, err := NewFakeAPI(filepath.Join("testinput", "api"), "test-client", "test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	mapName := filepath.Join("testout", "fixurls-map.csv")
+	auditLog := filepath.Join("testout", "fixurls-audit.jsonl")
+	rollback := filepath.Join("testout", "fixurls-rollback.json")
+	os.Remove(auditLog)
+	if err := ioutil.WriteFile(mapName, []byte("Old URL,New URL\nhttps://patents.google.com/,https://www.google.com/patents/\nhttp://ppubs.uspto.gov/pubwebapp/,https://ppubs.uspto.gov/pubwebapp/\n"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	opts := &Options{
+		Source: SourceAPI, APIBase: ts.URL, ClientId: "test-client", ClientSecret: "test-secret",
+		URLMap: mapName, AuditLog: auditLog, RollbackFile: rollback,
+	}
+
+	// A dry run doesn't change anything
+	if err := FixURLsReport("", filepath.Join("testout", "fixurls-dry-run.diff"), opts); err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 0, fake.RequestCount("PUT /assets/21001"))
+	if _, err := os.Stat(auditLog); err == nil {
+		t.Errorf("expected a dry run not to write %q", auditLog)
+	}
+	if err := FixURLsReport("", StdIO, &Options{URLMap: mapName, Apply: true}); err == nil {
+		t.Errorf("expected -apply to need -source api")
+	}
+
+	// Someone else fixes asset 21003 after the plan is made
+	c, err := opts.APIClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lg, err := AssembleLibGuides(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixes, err := ReadURLFixes(mapName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates := PlanURLFixes(lg, fixes, opts)
+	if err := c.UpdateAsset(21003, map[string]string{"url": "https://ppubs.uspto.gov/"}); err != nil {
+		t.Fatal(err)
+	}
+	// The audit log fails on the last entry, the changes made are
+	// still saved for the rollback
+	audit := &failingWriter{n: 2}
+	saved := 0
+	applied, err := ApplyURLFixes(c, updates, audit, "apply", func(applied []*URLFixUpdate) error {
+		saved = len(applied)
+		return nil
+	})
+	if err == nil {
+		t.Errorf("expected the audit log error")
+	}
+	expectedInt(t, 2, len(applied))
+	expectedInt(t, 2, saved)
+	expectedString(t, URLFixSkipped, updates[1].Status)
+	expectedInt(t, 2, strings.Count(audit.String(), "\n"))
+	asset, err := c.Asset(21001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, "https://www.google.com/patents/", asset.Url)
+	// Reused assets are changed once and the guides follow
+	expectedInt(t, 1, fake.RequestCount("PUT /assets/21001"))
+	guide, err := c.Guide(512672)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, "https://www.google.com/patents/", guide.Pages[0].Boxes[0].Assets[1].Url)
+
+	// Undo them through the command's report, with an audit log and
+	// rollback file
+	src, err := json.Marshal(applied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(rollback, src, 0664); err != nil {
+		t.Fatal(err)
+	}
+	opts.Undo, opts.Apply = rollback, true
+	if err := FixURLsReport("", filepath.Join("testout", "fixurls-undo.csv"), opts); err != nil {
+		t.Fatal(err)
+	}
+	asset, err = c.Asset(21004)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedString(t, "&lt;p&gt;Start with &lt;a href=&quot;https://patents.google.com/&quot;&gt;Google Patents&lt;/a&gt;.&lt;/p&gt;", asset.Description)
+
+	// And apply the map again
+	opts.Undo = ""
+	if err := FixURLsReport("", filepath.Join("testout", "fixurls-applied.json"), opts); err != nil {
+		t.Fatal(err)
+	}
+	applied, err = ReadURLFixRollback(rollback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 2, len(applied))
+	f, err := os.Open(auditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	actions := []string{}
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		entry := new(URLFixAudit)
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, entry.Action+" "+strInt(entry.AssetId)+" "+entry.Status)
+	}
+	expectedString(t, "rollback 21001 updated,rollback 21004 updated,apply 21001 updated,apply 21004 updated", strings.Join(actions, ","))
+}
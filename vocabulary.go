@@ -0,0 +1,676 @@
+// vocabulary.go reports the use of the subjects and tags, clusters likely
+// duplicates and merges them using a mapping file.
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Vocabularies and the status of their terms in the vocabulary report
+const (
+	VocabularySubject = "Subject"
+	VocabularyTag     = "Tag"
+
+	TermUsed    = "used"
+	TermUnused  = "unused"
+	TermMissing = "missing"
+)
+
+// minAbbreviation is the shortest word treated as an abbreviation of
+// a longer word it starts, e.g. "chem" for "chemistry"
+const minAbbreviation = 4
+
+// vocabTerm is a subject or tag with what it's compared by.
+type vocabTerm struct {
+	vocabulary string
+	id         int
+	name       string
+	guides     int
+	status     string
+	compact    string
+	stems      []string
+	// pos is the term's position in the report
+	pos int
+}
+
+// foldTerm returns the lower case words of a term with "&" spelled
+// "and" and punctuation removed.
+func foldTerm(name string) []string {
+	name = strings.ReplaceAll(strings.ToLower(name), "&", " and ")
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// stemSuffixes are removed by stemWord, longest first
+var stemSuffixes = []string{"ations", "ation", "ical", "ies", "ics", "ers", "ing", "al", "ed", "er", "es", "ic", "s", "y"}
+
+// stemWord is a light English stemmer, enough to match plurals and
+// forms like "aeronautics" and "aeronautical". It removes one suffix,
+// keeping at least three letters, and a final "e".
+func stemWord(word string) string {
+	for _, suffix := range stemSuffixes {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= 3 {
+			word = word[0 : len(word)-len(suffix)]
+			break
+		}
+	}
+	if len(word) > 3 && strings.HasSuffix(word, "e") {
+		word = word[0 : len(word)-1]
+	}
+	return word
+}
+
+// editDistance returns the Levenshtein distance of two strings.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(t)]
+}
+
+// maxEdits is the edit distance up to which terms of n letters are
+// likely the same, short terms must match exactly
+func maxEdits(n int) int {
+	switch {
+	case n < 5:
+		return 0
+	case n < 9:
+		return 1
+	}
+	return 2
+}
+
+// termMatch returns why two terms are likely duplicates, the lower
+// the rank the stronger the match, or "" if they aren't.
+func termMatch(a, b *vocabTerm) (string, int) {
+	if a.compact == "" || b.compact == "" {
+		return "", 0
+	}
+	if a.compact == b.compact {
+		return "same name", 1
+	}
+	if strings.Join(a.stems, " ") == strings.Join(b.stems, " ") {
+		return "same stem", 2
+	}
+	if len(a.stems) == 1 && len(b.stems) == 1 {
+		short, long := a.compact, b.compact
+		if len(short) > len(long) {
+			short, long = long, short
+		}
+		if len(short) >= minAbbreviation && strings.HasPrefix(long, short) {
+			return "abbreviation", 3
+		}
+	}
+	n := len([]rune(a.compact))
+	if m := len([]rune(b.compact)); m < n {
+		n = m
+	}
+	if edits := maxEdits(n); edits > 0 {
+		if d := editDistance(a.compact, b.compact); d <= edits {
+			return fmt.Sprintf("edit distance %d", d), 3 + d
+		}
+	}
+	return "", 0
+}
+
+// vocabularyTerms collects the subjects and tags, listed or only
+// referenced by guides, with the number of guides using them.
+func vocabularyTerms(lg *LibGuides) []*vocabTerm {
+	terms := []*vocabTerm{}
+	byKey := map[string]*vocabTerm{}
+	add := func(vocabulary string, id int, name string, status string) *vocabTerm {
+		key := fmt.Sprintf("%s %d", vocabulary, id)
+		if id == 0 {
+			key = vocabulary + " " + strings.ToLower(strings.TrimSpace(name))
+		}
+		if term, ok := byKey[key]; ok {
+			return term
+		}
+		words := foldTerm(name)
+		stems := make([]string, len(words))
+		for i, word := range words {
+			stems[i] = stemWord(word)
+		}
+		term := &vocabTerm{
+			vocabulary: vocabulary,
+			id:         id,
+			name:       name,
+			status:     status,
+			compact:    strings.Join(words, ""),
+			stems:      stems,
+			pos:        len(terms),
+		}
+		byKey[key] = term
+		terms = append(terms, term)
+		return term
+	}
+	for _, subject := range lg.Subjects {
+		add(VocabularySubject, subject.Id, subject.Name, TermUnused)
+	}
+	for _, tag := range lg.Tags {
+		add(VocabularyTag, tag.Id, tag.Name, TermUnused)
+	}
+	for _, guide := range lg.Guides {
+		used := map[*vocabTerm]bool{}
+		for _, subject := range guide.Subjects {
+			used[add(VocabularySubject, subject.Id, subject.Name, TermMissing)] = true
+		}
+		for _, tag := range guide.Tags {
+			used[add(VocabularyTag, tag.Id, tag.Name, TermMissing)] = true
+		}
+		for term := range used {
+			term.guides++
+			if term.status == TermUnused {
+				term.status = TermUsed
+			}
+		}
+	}
+	return terms
+}
+
+// VocabularyTable reports the subjects and tags of a LibGuides object,
+// listed or only referenced by guides ("missing"), with the number of
+// guides using them and clusters of likely duplicates across both
+// vocabularies. Terms are alike when they have the same name ignoring
+// case, spacing and punctuation ("same name"), the same stems ("same
+// stem"), one is the start of the other, e.g. "Chem" and "chemistry"
+// ("abbreviation"), or they are a small edit distance apart. In each
+// cluster the most used term of a vocabulary, or the longest on a tie,
+// is suggested as its preferred term. The columns are "Vocabulary",
+// "Id", "Name", "Guides", "Status", "Cluster", "Match" and "Preferred",
+// the report can be used as a mapping file for MergeVocabulary.
+func VocabularyTable(lg *LibGuides, caption string) *Table {
+	tbl := new(Table)
+	tbl.SetCaption(caption)
+	tbl.AppendHeadings("Vocabulary", "Id", "Name", "Guides", "Status", "Cluster", "Match", "Preferred")
+	terms := vocabularyTerms(lg)
+
+	// Cluster the likely duplicates, keeping each term's strongest match
+	uf := newUnionFind(len(terms))
+	match := make([]string, len(terms))
+	rank := make([]int, len(terms))
+	for i, a := range terms {
+		for j := i + 1; j < len(terms); j++ {
+			b := terms[j]
+			reason, r := termMatch(a, b)
+			if reason == "" {
+				continue
+			}
+			uf.union(i, j)
+			if rank[i] == 0 || r < rank[i] {
+				match[i], rank[i] = fmt.Sprintf("%s as %q", reason, b.name), r
+			}
+			if rank[j] == 0 || r < rank[j] {
+				match[j], rank[j] = fmt.Sprintf("%s as %q", reason, a.name), r
+			}
+		}
+	}
+	clusters := map[int]int{}
+	preferred := map[string]*vocabTerm{}
+	for i, term := range terms {
+		if rank[i] == 0 {
+			continue
+		}
+		root := uf.find(i)
+		if _, ok := clusters[root]; !ok {
+			clusters[root] = len(clusters) + 1
+		}
+		key := fmt.Sprintf("%d %s", root, term.vocabulary)
+		if best, ok := preferred[key]; !ok || term.guides > best.guides ||
+			(term.guides == best.guides && len(term.compact) > len(best.compact)) {
+			preferred[key] = term
+		}
+	}
+	for i, term := range terms {
+		cluster, suggestion := "", ""
+		if rank[i] != 0 {
+			root := uf.find(i)
+			cluster = strInt(clusters[root])
+			if best := preferred[fmt.Sprintf("%d %s", root, term.vocabulary)]; best != term {
+				suggestion = best.name
+			}
+		}
+		id := ""
+		if term.id != 0 {
+			id = strInt(term.id)
+		}
+		tbl.AppendRow(term.vocabulary, id, term.name, strInt(term.guides), term.status, cluster, match[i], suggestion)
+	}
+	return tbl
+}
+
+// VocabularyMapping maps a subject or tag to its preferred term.
+// Vocabulary is VocabularySubject, VocabularyTag or empty for both,
+// the term is found by Id or, if zero, by Name ignoring case.
+type VocabularyMapping struct {
+	Vocabulary string
+	Id         int
+	Name       string
+	Preferred  string
+}
+
+// parseVocabulary returns VocabularySubject or VocabularyTag for the
+// names of the vocabularies, e.g. "subjects", or "" for both.
+func parseVocabulary(s string) (string, error) {
+	switch strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "s") {
+	case "":
+		return "", nil
+	case "subject":
+		return VocabularySubject, nil
+	case "tag":
+		return VocabularyTag, nil
+	}
+	return "", fmt.Errorf("unknown vocabulary %q, expected subject or tag", s)
+}
+
+// ParseVocabularyMap reads a CSV mapping subjects and tags to their
+// preferred terms. With a heading row the columns are found by the
+// headings "Vocabulary", "Id", "Name" (or "Term") and "Preferred", other
+// columns are ignored so an edited vocabulary report can be used.
+// Without headings the columns are the name and the preferred term.
+// Rows without a preferred term, or mapping a term to itself, are
+// skipped.
+func ParseVocabularyMap(src []byte) ([]*VocabularyMapping, error) {
+	r := csv.NewReader(bytes.NewReader(src))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	mappings := []*VocabularyMapping{}
+	columns := map[string]int{"vocabulary": -1, "id": -1, "name": 0, "preferred": 1}
+	cell := func(row []string, name string) string {
+		if j := columns[name]; j >= 0 && j < len(row) {
+			return strings.TrimSpace(row[j])
+		}
+		return ""
+	}
+	for i := 0; ; i++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			headings := map[string]int{}
+			for j, heading := range row {
+				headings[strings.ToLower(strings.TrimSpace(heading))] = j
+			}
+			if j, ok := headings["preferred"]; ok {
+				columns = map[string]int{"vocabulary": -1, "id": -1, "name": -1, "preferred": j}
+				for _, name := range []string{"vocabulary", "id", "name", "term"} {
+					if j, ok := headings[name]; ok {
+						if name == "term" {
+							name = "name"
+						}
+						columns[name] = j
+					}
+				}
+				continue
+			}
+		}
+		mapping := &VocabularyMapping{Name: cell(row, "name"), Preferred: cell(row, "preferred")}
+		if mapping.Vocabulary, err = parseVocabulary(cell(row, "vocabulary")); err != nil {
+			return nil, fmt.Errorf("line %d, %s", i+1, err)
+		}
+		if id := cell(row, "id"); id != "" {
+			if mapping.Id, err = strconv.Atoi(id); err != nil {
+				return nil, fmt.Errorf("line %d, invalid id %q", i+1, id)
+			}
+		}
+		if mapping.Preferred == "" || (mapping.Id == 0 && mapping.Name == "") || mapping.Name == mapping.Preferred {
+			continue
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+// ReadVocabularyMap reads a vocabulary mapping (see ParseVocabularyMap)
+// from a file, "-" reads standard input.
+func ReadVocabularyMap(srcName string) ([]*VocabularyMapping, error) {
+	src, err := ReadSource(srcName)
+	if err != nil {
+		return nil, err
+	}
+	mappings, err := ParseVocabularyMap(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s, %s", srcName, err)
+	}
+	return mappings, nil
+}
+
+// VocabularyChange records a term merged into another or renamed.
+type VocabularyChange struct {
+	Vocabulary  string
+	Id          int
+	Name        string
+	Preferred   string
+	PreferredId int
+	// Merged is false when the term was renamed
+	Merged bool
+}
+
+// vocabEntry is a subject or tag being merged.
+type vocabEntry struct {
+	Id     int
+	Name   string
+	Url    string
+	listed bool
+}
+
+// mergeTerms applies the mappings to the entries of a vocabulary,
+// renaming entries in place. It returns the entries merged into
+// others by id and the mappings applied.
+func mergeTerms(vocabulary string, entries []*vocabEntry, mappings []*VocabularyMapping) (map[int]*vocabEntry, []*VocabularyChange, map[*VocabularyMapping]bool, error) {
+	merged := map[int]*vocabEntry{}
+	changes := []*VocabularyChange{}
+	applied := map[*VocabularyMapping]bool{}
+	// follow returns the entry an entry is merged into
+	follow := func(e *vocabEntry) *vocabEntry {
+		for merged[e.Id] != nil && merged[e.Id] != e {
+			e = merged[e.Id]
+		}
+		return e
+	}
+	// resolve follows the mappings of preferred terms
+	resolve := func(name string) (string, error) {
+		seen := map[string]bool{}
+		for {
+			if seen[name] {
+				return "", fmt.Errorf("%q is mapped in a cycle", name)
+			}
+			seen[name] = true
+			next := ""
+			for _, m := range mappings {
+				if m.Id == 0 && (m.Vocabulary == "" || m.Vocabulary == vocabulary) && strings.EqualFold(m.Name, name) && m.Preferred != name {
+					next = m.Preferred
+					break
+				}
+			}
+			if next == "" {
+				return name, nil
+			}
+			name = next
+		}
+	}
+	for _, m := range mappings {
+		if m.Vocabulary != "" && m.Vocabulary != vocabulary {
+			continue
+		}
+		preferred, err := resolve(m.Preferred)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		// Names match exactly if possible, else ignoring case
+		exactName := false
+		for _, e := range entries {
+			exactName = exactName || (merged[e.Id] == nil && e.Name == m.Name)
+		}
+		for _, e := range entries {
+			if merged[e.Id] != nil {
+				continue
+			}
+			if m.Id != 0 && e.Id != m.Id {
+				continue
+			}
+			if m.Id == 0 && !((exactName && e.Name == m.Name) || (!exactName && strings.EqualFold(e.Name, m.Name))) {
+				continue
+			}
+			applied[m] = true
+			// The target is the entry named preferred, exactly if possible
+			var target *vocabEntry
+			for _, exact := range []bool{true, false} {
+				for _, other := range entries {
+					if other != e && merged[other.Id] == nil && target == nil &&
+						((exact && other.Name == preferred) || (!exact && strings.EqualFold(other.Name, preferred))) {
+						target = other
+					}
+				}
+			}
+			change := &VocabularyChange{Vocabulary: vocabulary, Id: e.Id, Name: e.Name, Preferred: preferred}
+			if target == nil {
+				if e.Name == preferred {
+					continue
+				}
+				e.Name = preferred
+				change.PreferredId = e.Id
+			} else {
+				target = follow(target)
+				if target.Name != preferred {
+					changes = append(changes, &VocabularyChange{Vocabulary: vocabulary, Id: target.Id, Name: target.Name, Preferred: preferred, PreferredId: target.Id})
+					target.Name = preferred
+				}
+				merged[e.Id] = target
+				change.PreferredId, change.Merged = target.Id, true
+			}
+			changes = append(changes, change)
+		}
+	}
+	return merged, changes, applied, nil
+}
+
+// MergeVocabulary applies a vocabulary mapping to a LibGuides object.
+// A term is merged into the term named by its preferred term, guides
+// using it then use the preferred term and the term is removed from
+// the list, if there is no such term it is renamed. Mappings of
+// preferred terms are followed, e.g. "Chem" to "chemistry" and
+// "chemistry" to "Chemistry" merges both into "Chemistry". It is an
+// error if a mapped term is not found.
+func MergeVocabulary(lg *LibGuides, mappings []*VocabularyMapping) ([]*VocabularyChange, error) {
+	// Collect the entries of each vocabulary, listed or referenced
+	collect := func(listed []*vocabEntry, refs [][]*vocabEntry) []*vocabEntry {
+		entries := append([]*vocabEntry{}, listed...)
+		byId := map[int]bool{}
+		for _, e := range listed {
+			byId[e.Id] = true
+		}
+		for _, guideRefs := range refs {
+			for _, ref := range guideRefs {
+				if !byId[ref.Id] {
+					byId[ref.Id] = true
+					entries = append(entries, &vocabEntry{Id: ref.Id, Name: ref.Name, Url: ref.Url})
+				}
+			}
+		}
+		return entries
+	}
+	subjects, subjectRefs := []*vocabEntry{}, make([][]*vocabEntry, len(lg.Guides))
+	for _, subject := range lg.Subjects {
+		subjects = append(subjects, &vocabEntry{Id: subject.Id, Name: subject.Name, Url: subject.Url, listed: true})
+	}
+	tags, tagRefs := []*vocabEntry{}, make([][]*vocabEntry, len(lg.Guides))
+	for _, tag := range lg.Tags {
+		tags = append(tags, &vocabEntry{Id: tag.Id, Name: tag.Name, listed: true})
+	}
+	for i, guide := range lg.Guides {
+		for _, subject := range guide.Subjects {
+			subjectRefs[i] = append(subjectRefs[i], &vocabEntry{Id: subject.Id, Name: subject.Name, Url: subject.Url})
+		}
+		for _, tag := range guide.Tags {
+			tagRefs[i] = append(tagRefs[i], &vocabEntry{Id: tag.Id, Name: tag.Name})
+		}
+	}
+	subjects, tags = collect(subjects, subjectRefs), collect(tags, tagRefs)
+
+	changes := []*VocabularyChange{}
+	applied := map[*VocabularyMapping]bool{}
+	results := map[string]map[int]*vocabEntry{}
+	entries := map[string]map[int]*vocabEntry{}
+	for _, v := range []struct {
+		name    string
+		entries []*vocabEntry
+	}{{VocabularySubject, subjects}, {VocabularyTag, tags}} {
+		merged, vChanges, vApplied, err := mergeTerms(v.name, v.entries, mappings)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, vChanges...)
+		for m := range vApplied {
+			applied[m] = true
+		}
+		results[v.name] = merged
+		entries[v.name] = map[int]*vocabEntry{}
+		for _, e := range v.entries {
+			entries[v.name][e.Id] = e
+		}
+	}
+	for _, m := range mappings {
+		if !applied[m] {
+			vocabulary := m.Vocabulary
+			if vocabulary == "" {
+				vocabulary = "subject or tag"
+			}
+			if m.Id != 0 {
+				return nil, fmt.Errorf("no %s with id %d", strings.ToLower(vocabulary), m.Id)
+			}
+			return nil, fmt.Errorf("no %s named %q", strings.ToLower(vocabulary), m.Name)
+		}
+	}
+
+	// final returns the entry a term ends up as in a vocabulary
+	final := func(vocabulary string, id int) *vocabEntry {
+		e := entries[vocabulary][id]
+		for e != nil && results[vocabulary][e.Id] != nil {
+			e = results[vocabulary][e.Id]
+		}
+		return e
+	}
+	lgSubjects := []*Subject{}
+	for _, subject := range lg.Subjects {
+		if results[VocabularySubject][subject.Id] == nil {
+			subject.Name = final(VocabularySubject, subject.Id).Name
+			lgSubjects = append(lgSubjects, subject)
+		}
+	}
+	lg.Subjects = lgSubjects
+	lgTags := []*Tag{}
+	for _, tag := range lg.Tags {
+		if results[VocabularyTag][tag.Id] == nil {
+			tag.Name = final(VocabularyTag, tag.Id).Name
+			lgTags = append(lgTags, tag)
+		}
+	}
+	lg.Tags = lgTags
+	for _, guide := range lg.Guides {
+		guideSubjects, seen := []*Subject{}, map[int]bool{}
+		for _, subject := range guide.Subjects {
+			if e := final(VocabularySubject, subject.Id); e != nil && !seen[e.Id] {
+				seen[e.Id] = true
+				guideSubjects = append(guideSubjects, &Subject{Id: e.Id, Name: e.Name, Url: e.Url})
+			}
+		}
+		guide.Subjects = guideSubjects
+		guideTags, seen := []*Tag{}, map[int]bool{}
+		for _, tag := range guide.Tags {
+			if e := final(VocabularyTag, tag.Id); e != nil && !seen[e.Id] {
+				seen[e.Id] = true
+				guideTags = append(guideTags, &Tag{Id: e.Id, Name: e.Name})
+			}
+		}
+		guide.Tags = guideTags
+	}
+	return changes, nil
+}
+
+// VocabularyReport reads a LibGuides export and writes the vocabulary
+// report to destName or, when opts.VocabularyMap is set, applies the
+// mapping and writes the merged XML export.
+func VocabularyReport(srcName string, destName string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	var mappings []*VocabularyMapping
+	if opts.VocabularyMap != "" {
+		var err error
+		if mappings, err = ReadVocabularyMap(opts.VocabularyMap); err != nil {
+			return err
+		}
+	}
+	lg, err := opts.ReadLibGuides(srcName)
+	if err != nil {
+		return err
+	}
+	if opts.VocabularyMap == "" {
+		tbl := VocabularyTable(lg, fmt.Sprintf("Subjects and tags of %q", srcName))
+		opts.Logf("found %d subjects and %d tags", len(lg.Subjects), len(lg.Tags))
+		return opts.WriteTable(tbl, destName)
+	}
+	changes, err := MergeVocabulary(lg, mappings)
+	if err != nil {
+		return err
+	}
+	merged := 0
+	for _, change := range changes {
+		if change.Merged {
+			merged++
+		}
+	}
+	opts.Logf("merged %d terms and renamed %d", merged, len(changes)-merged)
+	src, err := lg.ToXML()
+	if err != nil {
+		return err
+	}
+	return WriteDestinationWithOptions(destName, src, &opts.WriteOptions)
+}
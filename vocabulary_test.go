@@ -0,0 +1,181 @@
+// vocabulary_test.go provides tests for vocabulary.go
+//
+// Author: R. S. Doiel <rsdoiel@caltech.edu>
+//
+// Copyright (c) 2021, Caltech
+// All rights not granted herein are expressly reserved by Caltech.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are met:
+//
+// 1. Redistributions of source code must retain the above copyright notice,
+// this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright notice,
+// this list of conditions and the following disclaimer in the documentation
+// and/or other materials provided with the distribution.
+//
+// 3. Neither the name of the copyright holder nor the names of its contributors
+// may be used to endorse or promote products derived from this software without
+// specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+// AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+// IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+// ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+// LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+// CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+// SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+// INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+// CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+// ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package springytools
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func vocabularyFixture() *LibGuides {
+	chemistry := &Subject{Id: 71, Name: "Chemistry", Url: "https://libguides.example.edu/sb.php?subject_id=71"}
+	return &LibGuides{
+		Subjects: []*Subject{chemistry, {Id: 72, Name: "Engineering"}, {Id: 73, Name: "Physics"}},
+		Tags: []*Tag{
+			{Id: 501, Name: "databases"}, {Id: 502, Name: "Databases"}, {Id: 503, Name: "chemistry"},
+			{Id: 504, Name: "Chem"}, {Id: 505, Name: "aeronautics"}, {Id: 506, Name: "aeronautical"},
+			{Id: 507, Name: "patents"}, {Id: 508, Name: "cybersecurity"}, {Id: 509, Name: "cyber-security"},
+			{Id: 510, Name: "bibliograpy"}, {Id: 511, Name: "bibliography"},
+		},
+		Guides: []*Guide{
+			{Id: 1, Subjects: []*Subject{chemistry}, Tags: []*Tag{{Id: 503, Name: "chemistry"}, {Id: 501, Name: "databases"}}},
+			{Id: 2, Subjects: []*Subject{chemistry, {Id: 74, Name: "Astronomy"}}, Tags: []*Tag{{Id: 504, Name: "Chem"}, {Id: 503, Name: "chemistry"}, {Id: 502, Name: "Databases"}}},
+			{Id: 3, Subjects: []*Subject{{Id: 72, Name: "Engineering"}}, Tags: []*Tag{{Id: 505, Name: "aeronautics"}, {Id: 501, Name: "databases"}}},
+		},
+	}
+}
+
+func TestVocabularyTable(t *testing.T) {
+	tbl := VocabularyTable(vocabularyFixture(), "vocabulary")
+	rows := map[string][]string{}
+	for _, row := range tbl.Body.Rows {
+		rows[row[0]+":"+row[2]] = row
+	}
+	expectedInt(t, 15, len(tbl.Body.Rows))
+	for key, expected := range map[string]string{
+		"Subject:Chemistry":  "Subject,71,Chemistry,2,used,1,same name as \"chemistry\",",
+		"Subject:Physics":    "Subject,73,Physics,0,unused,,,",
+		"Subject:Astronomy":  "Subject,74,Astronomy,1,missing,,,",
+		"Tag:chemistry":      "Tag,503,chemistry,2,used,1,same name as \"Chemistry\",",
+		"Tag:Chem":           "Tag,504,Chem,1,used,1,abbreviation as \"Chemistry\",chemistry",
+		"Tag:databases":      "Tag,501,databases,2,used,2,same name as \"Databases\",",
+		"Tag:Databases":      "Tag,502,Databases,1,used,2,same name as \"databases\",databases",
+		"Tag:aeronautical":   "Tag,506,aeronautical,0,unused,3,same stem as \"aeronautics\",aeronautics",
+		"Tag:cyber-security": "Tag,509,cyber-security,0,unused,4,same name as \"cybersecurity\",cybersecurity",
+		"Tag:bibliograpy":    "Tag,510,bibliograpy,0,unused,5,edit distance 1 as \"bibliography\",bibliography",
+		"Tag:patents":        "Tag,507,patents,0,unused,,,",
+	} {
+		expectedString(t, expected, joinRow(rows[key]))
+	}
+}
+
+func TestParseVocabularyMap(t *testing.T) {
+	mappings, err := ParseVocabularyMap([]byte(`Vocabulary,Id,Name,Guides,Preferred
+Tag,504,Chem,1,chemistry
+Tag,503,chemistry,2,
+Subjects,,Astronomy,1,Physics
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 2, len(mappings))
+	if len(mappings) == 2 {
+		expectedString(t, "Tag 504 Chem chemistry", strings.Join([]string{mappings[0].Vocabulary, strInt(mappings[0].Id), mappings[0].Name, mappings[0].Preferred}, " "))
+		expectedString(t, "Subject 0 Astronomy Physics", strings.Join([]string{mappings[1].Vocabulary, strInt(mappings[1].Id), mappings[1].Name, mappings[1].Preferred}, " "))
+	}
+	// Without headings the columns are the name and preferred term
+	mappings, err = ParseVocabularyMap([]byte("Chem,chemistry\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 1, len(mappings))
+	if _, err := ParseVocabularyMap([]byte("Vocabulary,Name,Preferred\nkeyword,Chem,chemistry\n")); err == nil {
+		t.Errorf("expected an error for an unknown vocabulary")
+	}
+}
+
+func TestMergeVocabulary(t *testing.T) {
+	lg := vocabularyFixture()
+	mappings, err := ParseVocabularyMap([]byte(`Chem,chemistry
+chemistry,Chemistry
+Databases,databases
+aeronautical,Aeronautics
+Astronomy,Physics
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := MergeVocabulary(lg, mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := []string{}
+	for _, change := range changes {
+		summary = append(summary, strings.Join([]string{change.Vocabulary, change.Name, change.Preferred, strInt(change.PreferredId)}, ":"))
+	}
+	expectedString(t, "Subject:Astronomy:Physics:73,Tag:chemistry:Chemistry:503,Tag:Chem:Chemistry:503,Tag:Databases:databases:501,Tag:aeronautics:Aeronautics:505,Tag:aeronautical:Aeronautics:505", strings.Join(summary, ","))
+	names := []string{}
+	for _, tag := range lg.Tags {
+		names = append(names, tag.Name)
+	}
+	expectedString(t, "databases,Chemistry,Aeronautics,patents,cybersecurity,cyber-security,bibliograpy,bibliography", strings.Join(names, ","))
+	guide := lg.Guides[1]
+	expectedInt(t, 2, len(guide.Subjects))
+	expectedString(t, "Physics", guide.Subjects[1].Name)
+	expectedInt(t, 2, len(guide.Tags))
+	expectedString(t, "503 Chemistry", strInt(guide.Tags[0].Id)+" "+guide.Tags[0].Name)
+	expectedString(t, "501 databases", strInt(guide.Tags[1].Id)+" "+guide.Tags[1].Name)
+	expectedString(t, "Aeronautics", lg.Guides[2].Tags[0].Name)
+
+	for _, src := range []string{"Chemistry,Chem\nChem,Chemistry\n", "Vocabulary,Name,Preferred\nTag,Physics,Astronomy\n", "Vocabulary,Id,Preferred\nSubject,999,Physics\n"} {
+		mappings, err := ParseVocabularyMap([]byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := MergeVocabulary(vocabularyFixture(), mappings); err == nil {
+			t.Errorf("expected an error merging %q", src)
+		}
+	}
+}
+
+func TestVocabularyReport(t *testing.T) {
+	srcName := filepath.Join("testinput", "api", "LibGuides_export_api.xml")
+	reportName := filepath.Join("testout", "vocabulary.csv")
+	if err := VocabularyReport(srcName, reportName, nil); err != nil {
+		t.Fatal(err)
+	}
+	// The report is a mapping file, here renaming the tag "chemistry"
+	// after its subject
+	src, err := ioutil.ReadFile(reportName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src = []byte(strings.Replace(string(src), `""Chemistry""",`+"\n", `""Chemistry""",Chemistry`+"\n", 1))
+	if err := ioutil.WriteFile(reportName, src, 0666); err != nil {
+		t.Fatal(err)
+	}
+	destName := filepath.Join("testout", "LibGuides_export_merged.xml")
+	if err := VocabularyReport(srcName, destName, &Options{VocabularyMap: reportName}); err != nil {
+		t.Fatal(err)
+	}
+	lg, err := ReadLibGuides(destName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedInt(t, 4, len(lg.Tags))
+	expectedString(t, "Chemistry", lg.Tags[3].Name)
+	expectedString(t, "Chemistry", lg.Guides[1].Tags[1].Name)
+}